@@ -8,14 +8,21 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// time zones of the chat quiet hours, the image has no tzdata
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/testit-tms/webhook-bot/internal/config"
 	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"github.com/testit-tms/webhook-bot/internal/lib/ratelimit"
 	"github.com/testit-tms/webhook-bot/internal/storage/postgres/chat"
 	"github.com/testit-tms/webhook-bot/internal/storage/postgres/company"
+	"github.com/testit-tms/webhook-bot/internal/storage/postgres/outbox"
 	"github.com/testit-tms/webhook-bot/internal/storage/postgres/owner"
+	"github.com/testit-tms/webhook-bot/internal/storage/postgres/route"
+	"github.com/testit-tms/webhook-bot/internal/storage/postgres/template"
+	"github.com/testit-tms/webhook-bot/internal/transport/rest/messages"
 	"github.com/testit-tms/webhook-bot/internal/transport/rest/send"
 	"github.com/testit-tms/webhook-bot/internal/transport/telegram"
 	"github.com/testit-tms/webhook-bot/internal/transport/telegram/commands"
@@ -43,6 +50,9 @@ func main() {
 	ownerStorage := owner.New(db)
 	companyStorage := company.New(db)
 	chatStorage := chat.New(db)
+	outboxStorage := outbox.New(db)
+	templateStorage := template.New(db)
+	routeStorage := route.New(db)
 
 	regUsecases := registration.New(ownerStorage, companyStorage)
 	registrator := commands.NewRegistrator(logger, regUsecases)
@@ -53,13 +63,43 @@ func main() {
 	chatUsesaces := usecases.NewChatUsecases(chatStorage, companyStorage)
 	chatCommands := commands.NewChatCommands(chatUsesaces, companyUsesaces)
 
-	bot, err := telegram.New(logger, cfg.TelegramBot.Token, registrator, companyCommands, chatCommands)
+	templateUsecases := usecases.NewTemplateUsecases(templateStorage, companyStorage)
+	templateCommands := commands.NewTemplateCommands(templateUsecases)
+
+	routeUsecases := usecases.NewRouteUsecases(routeStorage, chatStorage, companyStorage)
+	routeCommands := commands.NewRouteCommands(routeUsecases)
+
+	limiter := ratelimit.New(ratelimit.Limits{
+		GlobalPerSecond: cfg.TelegramBot.GlobalPerSecond,
+		ChatPerSecond:   cfg.TelegramBot.ChatPerSecond,
+		GroupPerMinute:  cfg.TelegramBot.GroupPerMinute,
+	})
+
+	bot, err := telegram.New(logger, cfg.TelegramBot.Token, limiter, registrator, companyCommands, chatCommands, templateCommands, routeCommands)
 	if err != nil {
 		logger.Error("cannot create telegram bot", err)
 	}
 
-	sendUsecases := usecases.NewSendMessageUsecases(logger, chatStorage, bot)
-	handler := send.New(logger, sendUsecases)
+	deliveryUsecases := usecases.NewDeliveryUsecases(logger, outboxStorage, chatStorage, bot, usecases.DeliveryOptions{
+		Workers:        cfg.Outbox.Workers,
+		BatchSize:      cfg.Outbox.BatchSize,
+		PollInterval:   cfg.Outbox.PollInterval,
+		Lease:          cfg.Outbox.Lease,
+		MaxAttempts:    cfg.Outbox.MaxAttempts,
+		RetryBaseDelay: cfg.Outbox.RetryBaseDelay,
+		RetryMaxDelay:  cfg.Outbox.RetryMaxDelay,
+		ThreadKeyTTL:   cfg.Outbox.ThreadKeyTTL,
+		Retention:      cfg.Outbox.Retention,
+	})
+
+	sendUsecases := usecases.NewSendMessageUsecases(logger, chatStorage, templateStorage, routeStorage, outboxStorage, deliveryUsecases, usecases.SendOptions{
+		Timeout:        cfg.HTTPServer.SendTimeout,
+		IdempotencyTTL: cfg.Idempotency.TTL,
+		Workers:        cfg.HTTPServer.SendWorkers,
+	})
+	handler := send.New(logger, sendUsecases, cfg.Idempotency.HashRequests)
+
+	messageUsecases := usecases.NewMessageUsecases(outboxStorage, outboxStorage, bot, cfg.HTTPServer.SendTimeout)
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -73,6 +113,10 @@ func main() {
 
 	router.Route("/telegram", func(r chi.Router) {
 		r.Post("/", handler)
+		r.Post("/testit", send.NewTestIT(logger, sendUsecases, cfg.Idempotency.HashRequests))
+		r.Get("/messages/{id}", messages.NewGet(logger, messageUsecases))
+		r.Delete("/messages/{id}", messages.NewDelete(logger, messageUsecases))
+		r.Delete("/messages", messages.NewDeleteBulk(logger, messageUsecases))
 	})
 
 	srv := &http.Server{
@@ -97,8 +141,22 @@ func main() {
 
 	logger.Info("telegram bot is running")
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	go func() {
+		deliveryUsecases.Run(workersCtx)
+		close(workersDone)
+	}()
+
+	logger.Info("delivery workers are running")
+
 	<-done
 
+	logger.Info("stopping delivery workers")
+
+	stopWorkers()
+	<-workersDone
+
 	logger.Info("stopping server")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -13,6 +13,8 @@ type Config struct {
 	HTTPServer  `yaml:"http_server"`
 	Database    `yaml:"database"`
 	TelegramBot `yaml:"telegram_bot"`
+	Outbox      `yaml:"outbox"`
+	Idempotency `yaml:"idempotency"`
 	LogLevel    string `yaml:"log_level" env-default:"Info" env:"LOG_LEVEL"`
 }
 
@@ -21,6 +23,8 @@ type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s" env:"TIMEOUT"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s" env:"IDLE_TIMEOUT"`
+	SendTimeout time.Duration `yaml:"send_timeout" env-default:"3s" env:"SEND_TIMEOUT"`
+	SendWorkers int           `yaml:"send_workers" env-default:"8" env:"SEND_WORKERS"`
 }
 
 // Database represents the configuration for the PostgreSQL database.
@@ -34,7 +38,29 @@ type Database struct {
 
 // TelegramBot represents the configuration for the Telegram bot.
 type TelegramBot struct {
-	Token string `yaml:"token"  env-required:"true" env:"BOT_TOKEN"`
+	Token           string `yaml:"token"  env-required:"true" env:"BOT_TOKEN"`
+	GlobalPerSecond int    `yaml:"global_per_second" env-default:"30" env:"BOT_GLOBAL_PER_SECOND"`
+	ChatPerSecond   int    `yaml:"chat_per_second" env-default:"1" env:"BOT_CHAT_PER_SECOND"`
+	GroupPerMinute  int    `yaml:"group_per_minute" env-default:"20" env:"BOT_GROUP_PER_MINUTE"`
+}
+
+// Outbox represents the configuration for the outbox delivery workers.
+type Outbox struct {
+	Workers        int           `yaml:"workers" env-default:"4" env:"OUTBOX_WORKERS"`
+	BatchSize      int           `yaml:"batch_size" env-default:"10" env:"OUTBOX_BATCH_SIZE"`
+	PollInterval   time.Duration `yaml:"poll_interval" env-default:"1s" env:"OUTBOX_POLL_INTERVAL"`
+	Lease          time.Duration `yaml:"lease" env-default:"1m" env:"OUTBOX_LEASE"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"10" env:"OUTBOX_MAX_ATTEMPTS"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay" env-default:"2s" env:"OUTBOX_RETRY_BASE_DELAY"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay" env-default:"10m" env:"OUTBOX_RETRY_MAX_DELAY"`
+	ThreadKeyTTL   time.Duration `yaml:"thread_key_ttl" env-default:"24h" env:"OUTBOX_THREAD_KEY_TTL"`
+	Retention      time.Duration `yaml:"retention" env-default:"168h" env:"OUTBOX_RETENTION"`
+}
+
+// Idempotency represents the configuration for deduplication of repeated webhooks.
+type Idempotency struct {
+	TTL          time.Duration `yaml:"ttl" env-default:"24h" env:"IDEMPOTENCY_TTL"`
+	HashRequests bool          `yaml:"hash_requests" env-default:"false" env:"IDEMPOTENCY_HASH_REQUESTS"`
 }
 
 // MustLoad loads the configuration from the file specified in the CONFIG_PATH environment variable.
@@ -1,8 +1,76 @@
 package entities
 
+import (
+	"fmt"
+	"time"
+)
+
 // Chat represents a chat entity.
+// MessageThreadID is the forum topic of a supergroup the messages are sent to, 0 means the General topic.
+// DigestWindow is the number of seconds the messages to the chat are collected into one digest, 0 turns the digest off.
+// Alias is the name unique within the company the messages can be sent to the chat by instead of its Telegram ID.
+// Username is the public username of a channel added by it, without the @.
+// Active is unset when the bot was blocked or removed from the chat, messages are not sent to inactive chats.
 type Chat struct {
-	Id         int64 `db:"id"`
-	CompanyID  int64 `db:"company_id"`
-	TelegramID int64 `db:"telegram_id"`
+	Id              int64  `db:"id"`
+	CompanyID       int64  `db:"company_id"`
+	TelegramID      int64  `db:"telegram_id"`
+	MessageThreadID int    `db:"message_thread_id"`
+	DigestWindow    int    `db:"digest_window"`
+	Alias           string `db:"alias"`
+	Username        string `db:"username"`
+	Active          bool   `db:"active"`
+	QuietHours
+}
+
+// QuietHours represents the daily period when non-urgent messages are held and delivered after it ends.
+// Start and End are minutes after midnight in the time zone, the period may span midnight.
+// Quiet hours with the same start and end are turned off.
+type QuietHours struct {
+	Start    int    `db:"quiet_start" json:"start"`
+	End      int    `db:"quiet_end" json:"end"`
+	TimeZone string `db:"time_zone" json:"timeZone"`
+}
+
+// IsQuiet reports whether the quiet hours are turned on.
+func (q QuietHours) IsQuiet() bool {
+	return q.Start != q.End
+}
+
+// QuietUntil reports whether the time is in the quiet hours and returns the time they end.
+// An unknown time zone is treated as UTC.
+func (q QuietHours) QuietUntil(t time.Time) (time.Time, bool) {
+	if !q.IsQuiet() {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	if q.Start < q.End {
+		quiet = minute >= q.Start && minute < q.End
+	} else {
+		quiet = minute >= q.Start || minute < q.End
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), q.End/60, q.End%60, 0, 0, loc)
+	if minute >= q.End {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end, true
+}
+
+// String returns the quiet hours in the form 22:00-08:00 Europe/Berlin.
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", q.Start/60, q.Start%60, q.End/60, q.End%60, q.TimeZone)
 }
@@ -17,11 +17,13 @@ type CompanyRegistrationInfo struct {
 }
 
 // CompanyInfo represents the information about a company.
+// Usernames maps the chats added by their public usernames to the usernames.
 type CompanyInfo struct {
-	ID      int64
-	OwnerID int64
-	Token   string
-	Name    string
-	Email   string
-	ChatIds []int64
+	ID        int64
+	OwnerID   int64
+	Token     string
+	Name      string
+	Email     string
+	ChatIds   []int64
+	Usernames map[int64]string
 }
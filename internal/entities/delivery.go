@@ -0,0 +1,105 @@
+package entities
+
+import "time"
+
+// DeliveryStatus represents the state of a message delivery to a single chat.
+type DeliveryStatus string
+
+const (
+	// DeliveryQueued represents a delivery waiting to be sent.
+	DeliveryQueued DeliveryStatus = "queued"
+	// DeliverySending represents a delivery claimed by a worker.
+	DeliverySending DeliveryStatus = "sending"
+	// DeliveryDelivered represents a delivery successfully sent to the chat.
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed represents a delivery that will not be retried anymore.
+	DeliveryFailed DeliveryStatus = "failed"
+	// DeliveryDeleted represents a delivery retracted by the company, its messages are deleted from the chat
+	// or it was cancelled before it was sent.
+	DeliveryDeleted DeliveryStatus = "deleted"
+	// DeliverySuppressed represents a delivery that was not sent because it duplicates a message recently sent to the chat.
+	DeliverySuppressed DeliveryStatus = "suppressed"
+)
+
+// Delivery represents an outbox message queued for delivery to a single chat.
+// TelegramMessageIDs holds the IDs of all messages sent to the chat, TelegramMessageID is the first of them.
+// DigestID is set for the deliveries sent together in a digest, they share its Telegram messages.
+type Delivery struct {
+	ID                 int64          `db:"id"`
+	MessageID          int64          `db:"message_id"`
+	ChatID             int64          `db:"chat_id"`
+	Status             DeliveryStatus `db:"status"`
+	Attempts           int            `db:"attempts"`
+	LastError          string         `db:"last_error"`
+	TelegramMessageID  int            `db:"telegram_message_id"`
+	PlainText          bool           `db:"plain_text"`
+	DigestID           int64          `db:"digest_id"`
+	TelegramMessageIDs []int          `db:"-"`
+	Message            Message        `db:"-"`
+}
+
+// SendStatus represents the outcome of an attempt to send a message to a single chat.
+type SendStatus string
+
+const (
+	// SendStatusSent represents a message accepted by Telegram.
+	SendStatusSent SendStatus = "sent"
+	// SendStatusForbidden represents a chat where the bot is not allowed to post, e.g. it was kicked.
+	SendStatusForbidden SendStatus = "forbidden"
+	// SendStatusNotFound represents a chat that does not exist or is unknown to the bot.
+	SendStatusNotFound SendStatus = "not_found"
+	// SendStatusRateLimited represents a message that was not sent in time because of rate limits.
+	SendStatusRateLimited SendStatus = "rate_limited"
+	// SendStatusFailed represents any other failure.
+	SendStatusFailed SendStatus = "failed"
+	// SendStatusQueued represents a message that is waiting for the delivery workers.
+	SendStatusQueued SendStatus = "queued"
+	// SendStatusSuppressed represents a message that duplicates a message recently sent to the chat.
+	SendStatusSuppressed SendStatus = "suppressed"
+)
+
+// Retryable reports whether a failed send can succeed on a later attempt.
+func (s SendStatus) Retryable() bool {
+	return s == SendStatusRateLimited || s == SendStatusFailed
+}
+
+// SendResult represents the result of sending a message to a single chat.
+// MessageIDs holds the IDs of all messages sent to the chat, because a message may be split into several of them,
+// MessageID is the first of them.
+// PlainText is set when Telegram could not parse the entities of the message and it was sent as plain text instead.
+// MigratedTo is the ID of the supergroup the group was upgraded to, the message was sent to it instead of the group.
+type SendResult struct {
+	ChatID     int64
+	Status     SendStatus
+	MessageID  int
+	MessageIDs []int
+	Error      string
+	PlainText  bool
+	MigratedTo int64
+}
+
+// SendReport represents the results of sending an outbox message to all of its chats.
+// Replayed is set when the report belongs to a message sent by an earlier request with the same idempotency key.
+type SendReport struct {
+	MessageID int64
+	Results   []SendResult
+	Replayed  bool
+}
+
+// MessageFilter represents the conditions of a search for the outbox messages of a company.
+// Empty fields do not restrict the search, From is inclusive and To is exclusive.
+type MessageFilter struct {
+	CorrelationID string
+	From          time.Time
+	To            time.Time
+}
+
+// DedupState represents the deduplication of a message in a chat of a company.
+// Window is the deduplication window of the company in seconds, 0 means the company does not suppress duplicates.
+// Duplicate is set if a message with the same key was sent to the chat within the window,
+// Suppressed is the number of duplicates suppressed in the chat since they were last reported.
+type DedupState struct {
+	Window     int  `db:"dedup_window"`
+	Duplicate  bool `db:"duplicate"`
+	Suppressed int  `db:"suppressed"`
+}
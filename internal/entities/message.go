@@ -1,13 +1,85 @@
 package entities
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // Message represents a message to be sent to one or more chats.
 type Message struct {
-	Text      string
-	ParseMode ParseMode
-	Token     string
-	ChatIds   []int64
+	Text        string
+	ParseMode   ParseMode
+	Token       string
+	CompanyID   int64
+	ChatIds     []int64
+	// ChatAliases are the aliases of the chats the message is sent to in addition to ChatIds.
+	ChatAliases []string
+	// ThreadIDs maps the chats that are forum supergroups to the topics the message is sent to.
+	ThreadIDs   map[int64]int
+	Idempotency Idempotency
+	Template    string
+	Data        map[string]interface{}
+	Attachments []Attachment
+	Buttons     [][]Button
+	// DisableNotification, ProtectContent, DisableWebPagePreview and ReplyToMessageID are the Telegram send options.
+	DisableNotification   bool
+	ProtectContent        bool
+	DisableWebPagePreview bool
+	ReplyToMessageID      int
+	// CorrelationID groups the messages of one process, a message with the ID of an earlier one replaces its text.
+	CorrelationID string
+	// EditMessageID is the ID of the Telegram message in the chat to edit instead of sending a new one.
+	EditMessageID int
+	// ThreadKey groups the messages of one process, messages with the key of an earlier one are replies to it.
+	ThreadKey string
+	// SendAt is the time the message is delivered at, the message is delivered right away if it is zero or in the past.
+	SendAt time.Time
+	// QuietHours maps the chats with quiet hours to them, non-urgent messages are held in these chats until the quiet hours end.
+	QuietHours map[int64]QuietHours
+	Urgent     bool
+	// Digests maps the chats in digest mode to their windows, the message is collected into a digest in these chats.
+	Digests map[int64]time.Duration
+	// DedupKey identifies duplicates of the message when the company suppresses them, the normalized text is used if it is empty.
+	DedupKey string
+	// Metadata describes the message for the routing rules of the company.
+	Metadata Metadata
+}
+
+// Digestible reports whether the message can be collected into a digest.
+// Urgent and scheduled messages, messages with files or buttons and messages tied to other messages are sent on their own.
+func (m Message) Digestible() bool {
+	return !m.Urgent && m.SendAt.IsZero() && len(m.Attachments) == 0 && len(m.Buttons) == 0 &&
+		m.CorrelationID == "" && m.ThreadKey == "" && m.ReplyToMessageID == 0
+}
+
+// Button represents an inline button under a message that opens the URL.
+type Button struct {
+	Text string
+	URL  string
+}
+
+// AttachmentType represents the way Telegram shows an attached file.
+type AttachmentType string
+
+const (
+	// AttachmentPhoto represents a file shown as a photo.
+	AttachmentPhoto AttachmentType = "photo"
+	// AttachmentDocument represents a file shown as a general file.
+	AttachmentDocument AttachmentType = "document"
+)
+
+// Attachment represents a file sent with a message, either uploaded with its data or downloaded by Telegram from the URL.
+type Attachment struct {
+	Type AttachmentType
+	Name string
+	URL  string
+	Data []byte
+}
+
+// Idempotency represents the key that makes repeated requests with the same message send it only once until the key expires.
+type Idempotency struct {
+	Key       string
+	ExpiresAt time.Time
 }
 
 // ParseMode represents the parsing mode for a message.
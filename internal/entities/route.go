@@ -0,0 +1,164 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route fields the conditions of routing rules match.
+// Conditions on the data fields start with RouteFieldData followed by the dot-separated path in the data of the message.
+const (
+	RouteFieldProject   = "project"
+	RouteFieldEventType = "eventType"
+	RouteFieldSeverity  = "severity"
+	RouteFieldTag       = "tag"
+	RouteFieldData      = "data."
+)
+
+// Metadata describes what a message is about, the routing rules of the company pick the chats of the message by it.
+type Metadata struct {
+	Project   string
+	EventType string
+	Severity  string
+	Tags      []string
+}
+
+// IsZero reports whether the metadata is empty.
+func (m Metadata) IsZero() bool {
+	return m.Project == "" && m.EventType == "" && m.Severity == "" && len(m.Tags) == 0
+}
+
+// Route represents a routing rule of a company that sends the messages matching all of its conditions to its chats.
+type Route struct {
+	ID         int64
+	CompanyID  int64
+	Name       string
+	Conditions []RouteCondition
+	ChatIds    []int64
+}
+
+// RouteCondition represents a condition of a routing rule.
+// The value is compared case-insensitively and may contain the * wildcard that matches any characters.
+// A tag condition matches if any tag of the message matches, a data condition matches if the field or any item of it matches.
+type RouteCondition struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// ValidField reports whether the condition is on a field the routing rules can match.
+func (c RouteCondition) ValidField() bool {
+	switch c.Field {
+	case RouteFieldProject, RouteFieldEventType, RouteFieldSeverity, RouteFieldTag:
+		return true
+	}
+
+	path := strings.TrimPrefix(c.Field, RouteFieldData)
+	if path == c.Field || path == "" {
+		return false
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the condition in the field=value form.
+func (c RouteCondition) String() string {
+	return c.Field + "=" + c.Value
+}
+
+// Match reports whether the message matches all conditions of the route.
+func (r Route) Match(msg Message) bool {
+	for _, c := range r.Conditions {
+		if !c.Match(msg) {
+			return false
+		}
+	}
+
+	return len(r.Conditions) > 0
+}
+
+// Match reports whether the message matches the condition.
+func (c RouteCondition) Match(msg Message) bool {
+	switch c.Field {
+	case RouteFieldProject:
+		return matchWildcard(c.Value, msg.Metadata.Project)
+	case RouteFieldEventType:
+		return matchWildcard(c.Value, msg.Metadata.EventType)
+	case RouteFieldSeverity:
+		return matchWildcard(c.Value, msg.Metadata.Severity)
+	case RouteFieldTag:
+		for _, tag := range msg.Metadata.Tags {
+			if matchWildcard(c.Value, tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	path := strings.TrimPrefix(c.Field, RouteFieldData)
+	if path == c.Field {
+		return false
+	}
+
+	var value interface{} = msg.Data
+	for _, key := range strings.Split(path, ".") {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if value, ok = fields[key]; !ok {
+			return false
+		}
+	}
+
+	return matchValue(c.Value, value)
+}
+
+// matchValue matches the JSON value, a list matches if any of its items matches.
+func matchValue(pattern string, value interface{}) bool {
+	switch v := value.(type) {
+	case nil, map[string]interface{}:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if matchValue(pattern, item) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return matchWildcard(pattern, v)
+	default:
+		return matchWildcard(pattern, fmt.Sprint(v))
+	}
+}
+
+// matchWildcard reports whether the string matches the pattern case-insensitively, * in the pattern matches any characters.
+func matchWildcard(pattern, s string) bool {
+	parts := strings.Split(strings.ToLower(pattern), "*")
+	s = strings.ToLower(s)
+
+	if len(parts) == 1 {
+		return s == parts[0]
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+
+	return strings.HasSuffix(s, last)
+}
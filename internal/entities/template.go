@@ -0,0 +1,10 @@
+package entities
+
+// Template represents a named message template of a company.
+type Template struct {
+	ID        int64     `db:"id"`
+	CompanyID int64     `db:"company_id"`
+	Name      string    `db:"name"`
+	ParseMode ParseMode `db:"parse_mode"`
+	Body      string    `db:"body"`
+}
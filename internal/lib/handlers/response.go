@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	val "github.com/testit-tms/webhook-bot/internal/lib/validator"
 )
 
 // ErrorResponse represents an error response returned by the API.
@@ -23,20 +25,68 @@ func NewErrorResponse(w http.ResponseWriter, status int, err string) {
 	})
 }
 
+// NewJSONResponse writes the value as a JSON response to the provided http.ResponseWriter with the given status code.
+func NewJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// nolint:errcheck
+	json.NewEncoder(w).Encode(v)
+}
+
 // ValidationError generates a string message from the provided validation errors.
 func ValidationError(errs validator.ValidationErrors) string {
 	var errMsgs []string
 
 	for _, err := range errs {
+		field := fieldPath(err)
+
 		switch err.ActualTag() {
 		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", field))
+		case "required_without":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required if field %s is empty", field, err.Param()))
+		case "required_without_all":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required if fields %s are empty", field, strings.Join(strings.Fields(err.Param()), ", ")))
+		case "excluded_with":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s can not be used with field %s", field, err.Param()))
+		case "excluded_without_all":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s can be used only with one of fields %s", field, strings.Join(strings.Fields(err.Param()), ", ")))
+		case "oneof":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must have one of following values: %s", field, strings.Join(strings.Fields(err.Param()), ", ")))
+		case "min":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must have at least %s %s", field, err.Param(), unit(err)))
+		case "max":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must have at most %s %s", field, err.Param(), unit(err)))
 		case "parse-mode":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be empty or have following value: markdownv2, markdown or html", err.Field()))
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be empty or have following value: markdownv2, markdown or html", field))
+		case "button-url":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be an http, https or tg URL", field))
+		case "keyboard-size":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must have at most %d buttons", field, val.MaxKeyboardButtons))
 		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", field))
 		}
 	}
 
 	return strings.Join(errMsgs, ", ")
 }
+
+// fieldPath returns the path of the field in the validated struct, like Buttons[0][1].Text.
+func fieldPath(err validator.FieldError) string {
+	_, path, found := strings.Cut(err.Namespace(), ".")
+	if !found {
+		return err.Field()
+	}
+
+	return path
+}
+
+// unit returns what the min and max limits of the field count.
+func unit(err validator.FieldError) string {
+	switch err.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	default:
+		return "characters"
+	}
+}
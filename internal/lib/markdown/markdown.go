@@ -0,0 +1,185 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	v2Replacer = newReplacer(`\_*[]()~` + "`" + `>#+-=|{}.!`)
+	// inside pre and code entities only ` and \ are reserved
+	v2CodeReplacer = newReplacer(`\` + "`")
+	// inside the URL of an inline link only ) and \ are reserved
+	v2LinkReplacer = newReplacer(`\)`)
+	legacyReplacer = newReplacer(`_*[` + "`")
+)
+
+// EscapeV2 escapes the characters reserved in MarkdownV2, so the value is shown as is in the message text.
+func EscapeV2(s string) string {
+	return v2Replacer.Replace(s)
+}
+
+// EscapeV2Code escapes the value to be put inside a MarkdownV2 pre or code entity.
+func EscapeV2Code(s string) string {
+	return v2CodeReplacer.Replace(s)
+}
+
+// EscapeV2Link escapes the URL to be put inside the parentheses of a MarkdownV2 inline link.
+func EscapeV2Link(s string) string {
+	return v2LinkReplacer.Replace(s)
+}
+
+// Escape escapes the characters reserved in the legacy Markdown parse mode.
+func Escape(s string) string {
+	return legacyReplacer.Replace(s)
+}
+
+// Strip removes the markup of the legacy Markdown parse mode, so the text can be sent without a parse mode.
+// Bold and italic markers without a closing marker, like in snake_case, are kept as they are.
+// Inline links are replaced with their text.
+func Strip(s string) string {
+	return strip(s, legacy)
+}
+
+// StripV2 removes the MarkdownV2 markup and the backslashes escaping characters,
+// so the text can be sent without a parse mode like in Strip.
+// Custom emoji are replaced with their emoji and block quotes lose their > marks.
+func StripV2(s string) string {
+	return strip(s, v2)
+}
+
+// dialect represents the markup rules of a Markdown parse mode.
+type dialect struct {
+	// markers open and close the entities besides code and links, longer markers go first
+	markers []string
+	// escapable reports whether a backslash escapes the character outside code
+	escapable func(c byte) bool
+	// codeEscapes is set if a backslash escapes ` and \ in code too
+	codeEscapes bool
+	// v2 is set for the entities only MarkdownV2 has: custom emoji and block quotes
+	v2 bool
+}
+
+var (
+	legacy = dialect{
+		markers: []string{"*", "_"},
+		escapable: func(c byte) bool {
+			return strings.IndexByte(`_*[`+"`", c) >= 0
+		},
+	}
+	v2 = dialect{
+		markers: []string{"||", "__", "*", "_", "~"},
+		// any character with the code between 1 and 126 can be escaped
+		escapable: func(c byte) bool {
+			return c >= 1 && c <= 126
+		},
+		codeEscapes: true,
+		v2:          true,
+	}
+)
+
+// strip removes the markup of the dialect from the text.
+func strip(s string, d dialect) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	open := make(map[string]bool)
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && d.escapable(rest[1]):
+			b.WriteByte(rest[1])
+			i += 2
+			continue
+		case strings.HasPrefix(rest, "```"):
+			if j := closing(rest[3:], "```", d.codeEscapes); j >= 0 {
+				body := rest[3 : 3+j]
+				// the language of the block is on the line of the opening fence
+				if k := strings.IndexByte(body, '\n'); k >= 0 && !strings.ContainsAny(body[:k], " ") {
+					body = body[k+1:]
+				}
+				b.WriteString(unescapeCode(body, d))
+				i += 3 + j + 3
+				continue
+			}
+		case rest[0] == '`':
+			if j := closing(rest[1:], "`", d.codeEscapes); j >= 0 {
+				b.WriteString(unescapeCode(rest[1:1+j], d))
+				i += 1 + j + 1
+				continue
+			}
+		case rest[0] == '[' || d.v2 && strings.HasPrefix(rest, "!["):
+			start := strings.IndexByte(rest, '[') + 1
+			if j := strings.Index(rest, "]("); j > 0 {
+				// MarkdownV2 escapes ) in the URL
+				if k := closing(rest[j+2:], ")", d.v2); k >= 0 {
+					b.WriteString(strip(rest[start:j], d))
+					i += j + 2 + k + 1
+					continue
+				}
+			}
+		case d.v2 && rest[0] == '>' && (i == 0 || s[i-1] == '\n'):
+			i++
+			continue
+		}
+
+		if m := marker(rest, d.markers); m != "" {
+			if open[m] || closing(rest[len(m):], m, true) >= 0 {
+				open[m] = !open[m]
+				i += len(m)
+				continue
+			}
+		}
+
+		_, n := utf8.DecodeRuneInString(rest)
+		b.WriteString(rest[:n])
+		i += n
+	}
+
+	return b.String()
+}
+
+// marker returns the marker the text starts with or an empty string.
+func marker(s string, markers []string) string {
+	for _, m := range markers {
+		if strings.HasPrefix(s, m) {
+			return m
+		}
+	}
+
+	return ""
+}
+
+// closing returns the position of the first marker in the text that is not escaped or -1 if there is none.
+func closing(s, m string, escapes bool) int {
+	for i := 0; i < len(s); i++ {
+		if escapes && s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], m) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// unescapeCode removes the backslashes escaping characters in code if the dialect escapes them.
+func unescapeCode(s string, d dialect) string {
+	if !d.codeEscapes {
+		return s
+	}
+
+	return strings.NewReplacer(`\\`, `\`, "\\`", "`").Replace(s)
+}
+
+func newReplacer(chars string) *strings.Replacer {
+	pairs := make([]string, 0, len(chars)*2)
+	for _, c := range chars {
+		pairs = append(pairs, string(c), `\`+string(c))
+	}
+
+	return strings.NewReplacer(pairs...)
+}
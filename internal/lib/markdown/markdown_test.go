@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeV2(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "without reserved characters",
+			s:    "Smoke tests",
+			want: "Smoke tests",
+		},
+		{
+			name: "with reserved characters",
+			s:    "Run #12 (v1.2-rc) failed!",
+			want: `Run \#12 \(v1\.2\-rc\) failed\!`,
+		},
+		{
+			name: "with backslash",
+			s:    `C:\tests\*_test.go`,
+			want: `C:\\tests\\\*\_test\.go`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, EscapeV2(tt.s))
+		})
+	}
+}
+
+func TestEscapeV2Code(t *testing.T) {
+	assert.Equal(t, "a.b() \\` \\\\", EscapeV2Code("a.b() ` \\"))
+}
+
+func TestEscapeV2Link(t *testing.T) {
+	assert.Equal(t, `https://testit.software/runs/(1\)`, EscapeV2Link("https://testit.software/runs/(1)"))
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "\\_a\\* \\[b] \\`c.d", Escape("_a* [b] `c.d"))
+}
+
+func TestStripV2(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "escaped text",
+			s:    EscapeV2("Run #12 (v1.2-rc) failed!"),
+			want: "Run #12 (v1.2-rc) failed!",
+		},
+		{
+			name: "escaped backslash",
+			s:    `a\\b`,
+			want: `a\b`,
+		},
+		{
+			name: "entities",
+			s:    "*Run* _#12_ __failed__ ~passed~ ||secret||",
+			want: "Run #12 failed passed secret",
+		},
+		{
+			name: "nested entities",
+			s:    "*bold _italic bold ~italic bold strikethrough ||spoiler||~ __underline___ bold*",
+			want: "bold italic bold italic bold strikethrough spoiler underline bold",
+		},
+		{
+			name: "code",
+			s:    "`a_\\`b` and\n```go\nfirst(*x)\n```",
+			want: "a_`b and\nfirst(*x)\n",
+		},
+		{
+			name: "links and custom emoji",
+			s:    "![👍](tg://emoji?id=5368324170671202286) [Run *12*](https://testit.software/runs/\\(12\\))",
+			want: "👍 Run 12",
+		},
+		{
+			name: "block quote",
+			s:    ">first\n>second \\> third",
+			want: "first\nsecond > third",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StripV2(tt.s))
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "bold and italic",
+			s:    "*Run #12* _failed_",
+			want: "Run #12 failed",
+		},
+		{
+			name: "unclosed markers",
+			s:    "*Run* my_test failed",
+			want: "Run my_test failed",
+		},
+		{
+			name: "escaped markers",
+			s:    Escape("_a* [b] `c"),
+			want: "_a* [b] `c",
+		},
+		{
+			name: "code",
+			s:    "Failed: `a_b`\n```go\nfirst(*x)\n```",
+			want: "Failed: a_b\nfirst(*x)\n",
+		},
+		{
+			name: "link",
+			s:    "[Run *12*](https://testit.software/runs/12) failed",
+			want: "Run 12 failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Strip(tt.s))
+		})
+	}
+}
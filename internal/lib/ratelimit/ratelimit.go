@@ -0,0 +1,221 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of chat buckets after which full buckets are dropped.
+const maxIdleBuckets = 10000
+
+// Limits represents the number of messages allowed globally, per chat and per group chat.
+type Limits struct {
+	GlobalPerSecond int
+	ChatPerSecond   int
+	GroupPerMinute  int
+}
+
+// Limiter spaces out messages to respect the Telegram bot limits.
+// Every message takes a token from the global bucket and from the bucket of its chat,
+// messages to group chats (negative IDs) also take a token from the group bucket.
+// When a bucket is empty the message is reserved for the moment the token is refilled.
+type Limiter struct {
+	mu     sync.Mutex
+	limits Limits
+	global *bucket
+	chats  map[int64]*bucket
+	groups map[int64]*bucket
+	now    func() time.Time
+}
+
+// New creates a new Limiter with the given limits.
+func New(limits Limits) *Limiter {
+	return &Limiter{
+		limits: limits,
+		global: newBucket(float64(limits.GlobalPerSecond), float64(limits.GlobalPerSecond)),
+		chats:  make(map[int64]*bucket),
+		groups: make(map[int64]*bucket),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a message can be sent to the chat.
+// It returns the context error if the context is done before that,
+// the tokens reserved for the message are returned then so they are not lost for the next messages.
+// If the message can not be sent before the context deadline, it fails right away.
+func (l *Limiter) Wait(ctx context.Context, chatID int64) error {
+	delay := l.reserve(chatID)
+	if err := ctx.Err(); err != nil {
+		l.cancel(chatID)
+		return err
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && delay > time.Until(deadline) {
+		l.cancel(chatID)
+		return fmt.Errorf("ratelimit: wait %s exceeds context deadline: %w", delay, context.DeadlineExceeded)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel(chatID)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause postpones all messages to the chat for the given duration,
+// it is used to wait out the retry_after returned by Telegram flood control.
+func (l *Limiter) Pause(chatID int64, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.chatBucket(chatID).pause(now, d)
+}
+
+func (l *Limiter) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	buckets := []*bucket{l.global, l.chatBucket(chatID)}
+	if chatID < 0 {
+		buckets = append(buckets, l.groupBucket(chatID))
+	}
+
+	var delay time.Duration
+	for _, b := range buckets {
+		if d := b.delay(now); d > delay {
+			delay = d
+		}
+	}
+
+	for _, b := range buckets {
+		b.take()
+	}
+
+	return delay
+}
+
+// cancel returns the tokens taken by reserve for a message that is not sent.
+func (l *Limiter) cancel(chatID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	buckets := []*bucket{l.global, l.chatBucket(chatID)}
+	if chatID < 0 {
+		buckets = append(buckets, l.groupBucket(chatID))
+	}
+
+	for _, b := range buckets {
+		b.giveBack(now)
+	}
+}
+
+func (l *Limiter) chatBucket(chatID int64) *bucket {
+	b, ok := l.chats[chatID]
+	if !ok {
+		l.cleanup(l.chats)
+		b = newBucket(float64(l.limits.ChatPerSecond), 1)
+		l.chats[chatID] = b
+	}
+
+	return b
+}
+
+func (l *Limiter) groupBucket(chatID int64) *bucket {
+	b, ok := l.groups[chatID]
+	if !ok {
+		l.cleanup(l.groups)
+		b = newBucket(float64(l.limits.GroupPerMinute)/60, float64(l.limits.GroupPerMinute))
+		l.groups[chatID] = b
+	}
+
+	return b
+}
+
+// cleanup drops the buckets that are full again, they are equal to the new ones.
+func (l *Limiter) cleanup(buckets map[int64]*bucket) {
+	if len(buckets) < maxIdleBuckets {
+		return
+	}
+
+	now := l.now()
+	for id, b := range buckets {
+		if b.full(now) {
+			delete(buckets, id)
+		}
+	}
+}
+
+// bucket is a token bucket, tokens can go negative to reserve messages in the future.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64) *bucket {
+	return &bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+func (b *bucket) advance(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+
+	if now.After(b.last) {
+		b.last = now
+	}
+}
+
+func (b *bucket) delay(now time.Time) time.Duration {
+	b.advance(now)
+	if b.tokens >= 1 || b.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *bucket) take() {
+	b.tokens--
+}
+
+func (b *bucket) giveBack(now time.Time) {
+	b.advance(now)
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *bucket) pause(now time.Time, d time.Duration) {
+	b.advance(now)
+	if tokens := 1 - d.Seconds()*b.rate; tokens < b.tokens {
+		b.tokens = tokens
+	}
+}
+
+func (b *bucket) full(now time.Time) bool {
+	b.advance(now)
+	return b.tokens >= b.burst
+}
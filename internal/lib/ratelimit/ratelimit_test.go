@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := New(Limits{
+		GlobalPerSecond: 30,
+		ChatPerSecond:   1,
+		GroupPerMinute:  20,
+	})
+	l.now = func() time.Time { return *now }
+
+	return l
+}
+
+func TestLimiter_reserve(t *testing.T) {
+	t.Run("one message per second in chat", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		assert.Equal(t, time.Duration(0), l.reserve(123))
+		assert.Equal(t, time.Second, l.reserve(123))
+		assert.Equal(t, 2*time.Second, l.reserve(123))
+		assert.Equal(t, time.Duration(0), l.reserve(456))
+	})
+
+	t.Run("tokens are refilled", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		assert.Equal(t, time.Duration(0), l.reserve(123))
+		now = now.Add(time.Second)
+		assert.Equal(t, time.Duration(0), l.reserve(123))
+	})
+
+	t.Run("global limit", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		for i := int64(1); i <= 30; i++ {
+			assert.Equal(t, time.Duration(0), l.reserve(i))
+		}
+		assert.InDelta(t, float64(time.Second/30), float64(l.reserve(31)), float64(time.Millisecond))
+	})
+
+	t.Run("twenty messages per minute in group", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		for i := 0; i < 29; i++ {
+			assert.Equal(t, time.Duration(0), l.reserve(-100))
+			now = now.Add(time.Second)
+		}
+		// 29 messages are sent in 29 seconds while only 20 + 29/3 tokens are available,
+		// so the group bucket has 2/3 of a token left and the next one is ready in a second
+		assert.InDelta(t, float64(time.Second), float64(l.reserve(-100)), float64(time.Millisecond))
+	})
+
+	t.Run("pause after retry after", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		l.Pause(123, 5*time.Second)
+		assert.Equal(t, 5*time.Second, l.reserve(123))
+		assert.Equal(t, time.Duration(0), l.reserve(456))
+	})
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	t.Run("context canceled", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+		l.Pause(123, time.Hour)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, l.Wait(ctx, 123), context.Canceled)
+		// the tokens of the canceled message are returned
+		assert.Equal(t, time.Hour, l.reserve(123))
+	})
+
+	t.Run("delay exceeds deadline", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+		l.Pause(-100, 5*time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, l.Wait(ctx, -100), context.DeadlineExceeded)
+		}
+		// refused messages do not push the next message further out
+		assert.Equal(t, 5*time.Second, l.reserve(-100))
+		assert.Equal(t, time.Duration(0), l.reserve(456))
+	})
+
+	t.Run("without delay", func(t *testing.T) {
+		now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		l := newTestLimiter(&now)
+
+		assert.NoError(t, l.Wait(context.Background(), 123))
+	})
+}
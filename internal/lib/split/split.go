@@ -0,0 +1,280 @@
+package split
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the maximum length of the text of a Telegram message.
+const MaxMessageLength = 4096
+
+// MaxCaptionLength is the maximum length of the caption of a Telegram photo or document.
+const MaxCaptionLength = 1024
+
+// maxEntityLength is the maximum length of an HTML entity like &quot; including & and ;.
+const maxEntityLength = 10
+
+const fence = "```"
+
+type syntax int
+
+const (
+	plain syntax = iota
+	html
+	markdown
+	markdownV2
+)
+
+// markers are the entity markers of the Markdown syntaxes besides code blocks,
+// longer markers go first so they are not taken for shorter ones.
+var markers = map[syntax][]string{
+	markdown:   {"*", "_", "`"},
+	markdownV2: {"||", "__", "*", "_", "~", "`"},
+}
+
+type tag struct {
+	name  string
+	open  string
+	close string
+}
+
+type breakPoint struct {
+	pos     int
+	stack   []tag
+	visible bool
+}
+
+// Text splits the plain text into ordered parts of at most limit characters.
+// It cuts on line boundaries when possible, then on spaces, and only then inside a word.
+func Text(text string, limit int) []string {
+	return split(text, limit, plain)
+}
+
+// HTML splits the text with Telegram HTML markup into ordered parts of at most limit visible characters.
+// Tags do not count towards the limit and are never cut, entities like &lt; count as one character.
+// Tags open at a cut are closed at the end of the part and opened again at the start of the next one,
+// so every part is valid markup on its own.
+func HTML(text string, limit int) []string {
+	return split(text, limit, html)
+}
+
+// Markdown splits the text with Telegram Markdown markup into ordered parts of at most limit characters.
+// Escaped characters are never cut from their backslash. Entities open at a cut like bold, italic, inline code
+// and code blocks are closed at the end of the part and opened again at the start of the next one,
+// code blocks with the same language.
+func Markdown(text string, limit int) []string {
+	return split(text, limit, markdown)
+}
+
+// MarkdownV2 splits the text with Telegram MarkdownV2 markup into ordered parts of at most limit characters
+// like Markdown, underline, strikethrough and spoiler entities are balanced too.
+func MarkdownV2(text string, limit int) []string {
+	return split(text, limit, markdownV2)
+}
+
+func split(text string, limit int, syn syntax) []string {
+	if limit <= 0 {
+		return []string{text}
+	}
+
+	parts := []string{}
+	var stack []tag
+	for pos := 0; pos < len(text); {
+		var (
+			part    string
+			visible bool
+		)
+		part, visible, pos, stack = cut(text, pos, stack, limit, syn)
+		if visible {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return []string{text}
+	}
+
+	return parts
+}
+
+// cut returns the part of the text that starts at pos with the given open tags and fits the limit.
+// It reports whether the part has any visible characters and returns the position and the open tags the next part starts with.
+func cut(text string, pos int, stack []tag, limit int, syn syntax) (string, bool, int, []tag) {
+	open := clone(stack)
+	size := 0
+	visible := false
+	line := breakPoint{pos: pos}
+	space := breakPoint{pos: pos}
+
+	i := pos
+	for i < len(text) {
+		n, width, markup := next(text[i:], open, syn)
+		if size+width > limit && i > pos {
+			break
+		}
+
+		atom := text[i : i+n]
+		if markup {
+			open = apply(open, atom, syn)
+		} else if strings.TrimFunc(atom, unicode.IsSpace) != "" {
+			visible = true
+		}
+
+		size += width
+		i += n
+
+		switch atom {
+		case "\n":
+			line = breakPoint{pos: i, stack: clone(open), visible: visible}
+		case " ":
+			space = breakPoint{pos: i, stack: clone(open), visible: visible}
+		}
+	}
+
+	if i >= len(text) {
+		return openTags(stack) + text[pos:], visible, len(text), open
+	}
+
+	end := breakPoint{pos: i, stack: open, visible: visible}
+	switch {
+	case line.pos > pos:
+		end = line
+	case space.pos > pos:
+		end = space
+	}
+
+	return openTags(stack) + text[pos:end.pos] + closeTags(end.stack), end.visible, end.pos, end.stack
+}
+
+// next returns the length in bytes of the atom at the start of s, its width in the limit and whether it is markup.
+func next(s string, stack []tag, syn syntax) (int, int, bool) {
+	switch syn {
+	case html:
+		switch s[0] {
+		case '<':
+			if j := strings.IndexByte(s, '>'); j > 0 {
+				return j + 1, 0, true
+			}
+		case '&':
+			if j := strings.IndexByte(s, ';'); j > 1 && j < maxEntityLength {
+				return j + 1, 1, false
+			}
+		}
+	case markdown, markdownV2:
+		// MarkdownV2 escapes characters in code too, Markdown does not
+		if s[0] == '\\' && len(s) > 1 && (syn == markdownV2 || code(stack) == "") {
+			_, n := utf8.DecodeRuneInString(s[1:])
+			return n + 1, 1, false
+		}
+		// entities are not parsed in code, only the end of it
+		if c := code(stack); c != "" {
+			if strings.HasPrefix(s, c) {
+				return len(c), 0, true
+			}
+			break
+		}
+		if strings.HasPrefix(s, fence) {
+			// the opening fence takes the language and the line break after it
+			if j := strings.IndexByte(s, '\n'); j >= len(fence) && !strings.ContainsAny(s[len(fence):j], " `") {
+				return j + 1, 0, true
+			}
+			return len(fence), 0, true
+		}
+		for _, m := range markers[syn] {
+			if strings.HasPrefix(s, m) {
+				return len(m), 0, true
+			}
+		}
+	}
+
+	r, n := utf8.DecodeRuneInString(s)
+	// Telegram counts the length in UTF-16 code units
+	if r > 0xFFFF {
+		return n, 2, false
+	}
+
+	return n, 1, false
+}
+
+// apply returns the open tags after the given markup.
+func apply(stack []tag, raw string, syn syntax) []tag {
+	if syn != html {
+		// the same marker opens and closes an entity
+		name := raw
+		if strings.HasPrefix(raw, fence) {
+			name = fence
+		}
+		if closed, ok := remove(stack, name); ok {
+			return closed
+		}
+
+		open := raw
+		if name == fence && !strings.HasSuffix(open, "\n") {
+			open += "\n"
+		}
+
+		return append(stack, tag{name: name, open: open, close: name})
+	}
+
+	inner := strings.TrimSpace(raw[1 : len(raw)-1])
+
+	if strings.HasPrefix(inner, "/") {
+		closed, _ := remove(stack, strings.ToLower(strings.TrimSpace(inner[1:])))
+		return closed
+	}
+
+	name := inner
+	if j := strings.IndexFunc(inner, unicode.IsSpace); j > 0 {
+		name = inner[:j]
+	}
+	name = strings.ToLower(name)
+
+	return append(stack, tag{name: name, open: raw, close: "</" + name + ">"})
+}
+
+// remove returns the open tags without the last tag with the given name and whether there was such a tag.
+func remove(stack []tag, name string) ([]tag, bool) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].name == name {
+			return append(stack[:i:i], stack[i+1:]...), true
+		}
+	}
+
+	return stack, false
+}
+
+// code returns the closing marker of the Markdown code entity the text is in or an empty string if it is not in code.
+func code(stack []tag) string {
+	if len(stack) == 0 {
+		return ""
+	}
+
+	if top := stack[len(stack)-1]; top.name == fence || top.name == "`" {
+		return top.close
+	}
+
+	return ""
+}
+
+func openTags(stack []tag) string {
+	var b strings.Builder
+	for _, t := range stack {
+		b.WriteString(t.open)
+	}
+
+	return b.String()
+}
+
+func closeTags(stack []tag) string {
+	var b strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString(stack[i].close)
+	}
+
+	return b.String()
+}
+
+func clone(stack []tag) []tag {
+	return append([]tag(nil), stack...)
+}
@@ -0,0 +1,238 @@
+package split
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "short text",
+			text:  "short text",
+			limit: 20,
+			want:  []string{"short text"},
+		},
+		{
+			name:  "empty text",
+			text:  "",
+			limit: 20,
+			want:  []string{""},
+		},
+		{
+			name:  "on line boundaries",
+			text:  "first line\nsecond line\nthird line",
+			limit: 24,
+			want:  []string{"first line\nsecond line\n", "third line"},
+		},
+		{
+			name:  "on spaces",
+			text:  "first second third",
+			limit: 13,
+			want:  []string{"first second ", "third"},
+		},
+		{
+			name:  "inside a word",
+			text:  "abcdefghij",
+			limit: 4,
+			want:  []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:  "unicode",
+			text:  "абвгд",
+			limit: 2,
+			want:  []string{"аб", "вг", "д"},
+		},
+		{
+			name:  "characters outside of the basic plane count twice",
+			text:  "😀😀😀",
+			limit: 4,
+			want:  []string{"😀😀", "😀"},
+		},
+		{
+			name:  "without limit",
+			text:  "text",
+			limit: 0,
+			want:  []string{"text"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Text(tt.text, tt.limit))
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "tags do not count",
+			text:  "<b>bold</b> text",
+			limit: 9,
+			want:  []string{"<b>bold</b> text"},
+		},
+		{
+			name:  "tags are reopened",
+			text:  "<b>first line\nsecond line</b>",
+			limit: 15,
+			want:  []string{"<b>first line\n</b>", "<b>second line</b>"},
+		},
+		{
+			name:  "nested tags with attributes",
+			text:  "<a href=\"https://testit.software\"><i>first second</i></a> end",
+			limit: 7,
+			want: []string{
+				"<a href=\"https://testit.software\"><i>first </i></a>",
+				"<a href=\"https://testit.software\"><i>second</i></a> ",
+				"end",
+			},
+		},
+		{
+			name:  "pre block",
+			text:  "<pre><code class=\"language-go\">a := 1\nb := 2\n</code></pre>",
+			limit: 8,
+			want: []string{
+				"<pre><code class=\"language-go\">a := 1\n</code></pre>",
+				"<pre><code class=\"language-go\">b := 2\n</code></pre>",
+			},
+		},
+		{
+			name:  "entities are not cut",
+			text:  "a&lt;b&amp;c",
+			limit: 2,
+			want:  []string{"a&lt;", "b&amp;", "c"},
+		},
+		{
+			name:  "parts without text are dropped",
+			text:  "<b>first</b>\n\n<i>second</i>",
+			limit: 6,
+			want:  []string{"<b>first</b>\n", "<i>second</i>"},
+		},
+		{
+			name:  "long failed tests list",
+			text:  "<b>Failed:</b>\n" + strings.Repeat("<code>test</code>\n", 3),
+			limit: 12,
+			want: []string{
+				"<b>Failed:</b>\n",
+				"<code>test</code>\n<code>test</code>\n",
+				"<code>test</code>\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HTML(tt.text, tt.limit))
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "short text",
+			text:  "*bold* text",
+			limit: 20,
+			want:  []string{"*bold* text"},
+		},
+		{
+			name:  "escaped characters are not cut",
+			text:  "a\\.b\\.c",
+			limit: 2,
+			want:  []string{"a\\.", "b\\.", "c"},
+		},
+		{
+			name:  "code block is reopened",
+			text:  "Failed:\n```go\nfirst()\nsecond()\n```\nend",
+			limit: 16,
+			want: []string{
+				"Failed:\n```go\nfirst()\n```",
+				"```go\nsecond()\n```\nend",
+			},
+		},
+		{
+			name:  "bold is reopened",
+			text:  "*failed test one*",
+			limit: 10,
+			want:  []string{"*failed *", "*test one*"},
+		},
+		{
+			name:  "nested entities are reopened",
+			text:  "*bold _italic text_*",
+			limit: 10,
+			want:  []string{"*bold *", "*_italic _*", "*_text_*"},
+		},
+		{
+			name:  "markers in code are not entities",
+			text:  "`a_b c_d`",
+			limit: 4,
+			want:  []string{"`a_b `", "`c_d`"},
+		},
+		{
+			name:  "escaped markers are not entities",
+			text:  "a\\_b c d",
+			limit: 4,
+			want:  []string{"a\\_b ", "c d"},
+		},
+		{
+			name:  "strikethrough is not an entity",
+			text:  "~a b ~c",
+			limit: 3,
+			want:  []string{"~a ", "b ", "~c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Markdown(tt.text, tt.limit))
+		})
+	}
+}
+
+func TestMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "spoiler and strikethrough are reopened",
+			text:  "||~old value~ hidden||",
+			limit: 12,
+			want:  []string{"||~old value~ ||", "||hidden||"},
+		},
+		{
+			name:  "underline is not taken for italic",
+			text:  "__a b__ _c d_",
+			limit: 3,
+			want:  []string{"__a __", "__b__ ", "_c d_"},
+		},
+		{
+			name:  "escaped characters in code are not cut",
+			text:  "`a\\`b c`",
+			limit: 4,
+			want:  []string{"`a\\`b `", "`c`"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MarkdownV2(tt.text, tt.limit))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package tmpl
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/markdown"
+)
+
+// MaxOutputLength limits the size of a rendered template, longer messages are split by the sender anyway.
+const MaxOutputLength = 64 * 1024
+
+const escapeFunc = "escape"
+
+// safe is a value that is already escaped for the parse mode and is printed as is.
+type safe string
+
+// Parse parses the template body for the parse mode.
+// The output of every action is escaped for the parse mode, so values from the data can not break the markup.
+// The raw function prints a value without escaping, code and link escape it for a code entity and a link URL.
+func Parse(name, body string, parseMode entities.ParseMode) (*template.Template, error) {
+	t, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(funcs(parseMode)).
+		Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeActions(tt.Tree, tt.Tree.Root)
+		}
+	}
+
+	return t, nil
+}
+
+// Render parses the template body for the parse mode and executes it with the data.
+func Render(name, body string, parseMode entities.ParseMode, data interface{}) (string, error) {
+	t, err := Parse(name, body, parseMode)
+	if err != nil {
+		return "", err
+	}
+
+	var b limitedBuilder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func funcs(parseMode entities.ParseMode) template.FuncMap {
+	escape := func(s string) string { return s }
+	code := escape
+	link := escape
+
+	switch parseMode {
+	case entities.HTML:
+		escape = html.EscapeString
+		code = html.EscapeString
+		link = html.EscapeString
+	case entities.MarkdownV2:
+		escape = markdown.EscapeV2
+		code = markdown.EscapeV2Code
+		link = markdown.EscapeV2Link
+	case entities.Markdown:
+		escape = markdown.Escape
+	}
+
+	return template.FuncMap{
+		escapeFunc: func(v interface{}) string {
+			if s, ok := v.(safe); ok {
+				return string(s)
+			}
+
+			return escape(toString(v))
+		},
+		"raw":  func(v interface{}) safe { return safe(toString(v)) },
+		"code": func(v interface{}) safe { return safe(code(toString(v))) },
+		"link": func(v interface{}) safe { return safe(link(toString(v))) },
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case safe:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeActions appends the escape function to the pipeline of every action that prints a value.
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(tree, child)
+		}
+	case *parse.ActionNode:
+		// declarations and assignments print nothing
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
+
+// limitedBuilder is a strings.Builder that fails when the output grows over MaxOutputLength.
+type limitedBuilder struct {
+	strings.Builder
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > MaxOutputLength {
+		return 0, fmt.Errorf("output is longer than %d bytes", MaxOutputLength)
+	}
+
+	return b.Builder.Write(p)
+}
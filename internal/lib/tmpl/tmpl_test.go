@@ -0,0 +1,106 @@
+package tmpl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]interface{}{
+		"project": "Web <UI> & API",
+		"passed":  float64(12),
+		"failed":  float64(1),
+		"url":     "https://testit.software/runs/(1)?a=b&c=d",
+		"tests":   []interface{}{"login_test.go", "cart*_test"},
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		parseMode entities.ParseMode
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "html escaping",
+			body:      "<b>{{.project}}</b>: {{.passed}} passed",
+			parseMode: entities.HTML,
+			want:      "<b>Web &lt;UI&gt; &amp; API</b>: 12 passed",
+		},
+		{
+			name:      "html link",
+			body:      `<a href="{{link .url}}">open</a>`,
+			parseMode: entities.HTML,
+			want:      `<a href="https://testit.software/runs/(1)?a=b&amp;c=d">open</a>`,
+		},
+		{
+			name:      "markdownv2 escaping",
+			body:      "*{{.project}}*{{if gt .failed 0.0}} failed: {{.failed}}{{end}}",
+			parseMode: entities.MarkdownV2,
+			want:      `*Web <UI\> & API* failed: 1`,
+		},
+		{
+			name:      "markdownv2 range and code",
+			body:      "{{range .tests}}`{{code .}}` {{.}}\n{{end}}",
+			parseMode: entities.MarkdownV2,
+			want:      "`login_test.go` login\\_test\\.go\n`cart*_test` cart\\*\\_test\n",
+		},
+		{
+			name:      "markdownv2 link",
+			body:      "[run]({{link .url}})",
+			parseMode: entities.MarkdownV2,
+			want:      `[run](https://testit.software/runs/(1\)?a=b&c=d)`,
+		},
+		{
+			name:      "raw value",
+			body:      "{{raw .project}}",
+			parseMode: entities.HTML,
+			want:      "Web <UI> & API",
+		},
+		{
+			name:      "variables",
+			body:      "{{$p := .project}}{{$p}}",
+			parseMode: entities.Markdown,
+			want:      "Web <UI> & API",
+		},
+		{
+			name:      "missing value",
+			body:      "[{{.unknown}}]",
+			parseMode: entities.HTML,
+			want:      "[]",
+		},
+		{
+			name:      "plain text",
+			body:      "{{.project}}",
+			parseMode: entities.Undefined,
+			want:      "Web <UI> & API",
+		},
+		{
+			name:      "invalid template",
+			body:      "{{.project",
+			parseMode: entities.HTML,
+			wantErr:   true,
+		},
+		{
+			name:      "too long output",
+			body:      `{{range .tests}}` + strings.Repeat("x", MaxOutputLength/2+1) + `{{end}}`,
+			parseMode: entities.HTML,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render("test", tt.body, tt.parseMode, data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
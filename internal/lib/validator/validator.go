@@ -1,13 +1,20 @@
 package validator
 
 import (
+	"net/url"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// MaxKeyboardButtons is the maximum number of buttons in a Telegram inline keyboard.
+const MaxKeyboardButtons = 100
+
 var (
-	parseMode = []string{"html"} //"markdownv2", "markdown",
+	parseMode = []string{"markdownv2", "markdown", "html"}
+
+	buttonURLSchemes = []string{"http", "https", "tg"}
 )
 
 // ValidateParseMode checks if the provided parse mode is valid.
@@ -27,3 +34,38 @@ func ValidateParseMode(fl validator.FieldLevel) bool {
 
 	return false
 }
+
+// ValidateButtonURL checks if the provided URL can be opened by a Telegram inline button.
+// Returns true if it is an absolute http, https or tg URL, false otherwise.
+func ValidateButtonURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, s := range buttonURLSchemes {
+		if s == strings.ToLower(u.Scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateKeyboardSize checks if the provided rows of buttons fit a Telegram inline keyboard.
+// Returns true if the rows have at most MaxKeyboardButtons buttons in total, false otherwise.
+func ValidateKeyboardSize(fl validator.FieldLevel) bool {
+	rows := fl.Field()
+	if rows.Kind() != reflect.Slice {
+		return false
+	}
+
+	total := 0
+	for i := 0; i < rows.Len(); i++ {
+		if row := rows.Index(i); row.Kind() == reflect.Slice {
+			total += row.Len()
+		}
+	}
+
+	return total <= MaxKeyboardButtons
+}
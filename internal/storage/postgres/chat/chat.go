@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/testit-tms/webhook-bot/internal/entities"
 	"github.com/testit-tms/webhook-bot/internal/storage"
 )
@@ -24,11 +25,40 @@ func New(db *sqlx.DB) *ChatStorage {
 }
 
 const (
-	getChatsByCompanyId    = "SELECT id, company_id, telegram_id FROM chats WHERE company_id=$1"
-	getChatsByCompanyToken = "SELECT id, company_id, telegram_id FROM chats WHERE company_id=(SELECT id FROM companies WHERE token=$1)"
-	addChat                = "INSERT INTO chats (company_id, telegram_id) VALUES ($1, $2) RETURNING id, company_id, telegram_id"
-	deleteChatById         = "DELETE FROM chats WHERE id=$1"
-	deleteChatByCompanyId  = "DELETE FROM chats WHERE company_id=$1"
+	getChatsByCompanyId    = "SELECT id, company_id, telegram_id, message_thread_id, digest_window, alias, username, active, quiet_start, quiet_end, time_zone FROM chats WHERE company_id=$1"
+	getChatsByCompanyToken = `SELECT id, company_id, telegram_id, message_thread_id, digest_window, alias, username, active, quiet_start, quiet_end, time_zone FROM chats
+	WHERE company_id=(SELECT id FROM companies WHERE token=$1) AND active`
+	addChat               = "INSERT INTO chats (company_id, telegram_id, message_thread_id, username) VALUES ($1, $2, $3, $4) RETURNING id, company_id, telegram_id, message_thread_id, username, active"
+	setQuietHours         = "UPDATE chats SET quiet_start=$2, quiet_end=$3, time_zone=$4 WHERE id=$1"
+	setDigestWindow       = "UPDATE chats SET digest_window=$2 WHERE id=$1"
+	setAlias              = "UPDATE chats SET alias=$2 WHERE id=$1"
+	activateChat          = "UPDATE chats SET active=true WHERE id=$1"
+	deleteOldChats        = "DELETE FROM chats AS c WHERE c.telegram_id=$1 AND EXISTS (SELECT 1 FROM chats AS x WHERE x.company_id=c.company_id AND x.telegram_id=$2)"
+	migrateChat           = "UPDATE chats SET telegram_id=$2 WHERE telegram_id=$1"
+	migrateRoutes         = "UPDATE routes SET chat_ids=CASE WHEN $2=ANY(chat_ids) THEN array_remove(chat_ids, $1) ELSE array_replace(chat_ids, $1, $2) END WHERE $1=ANY(chat_ids)"
+	migrateCorrelations   = "UPDATE correlations SET chat_id=$2 WHERE chat_id=$1"
+	migrateThreadKeys     = "UPDATE thread_keys SET chat_id=$2 WHERE chat_id=$1"
+	migrateDedupKeys      = "UPDATE dedup_keys SET chat_id=$2 WHERE chat_id=$1"
+	migrateSuppressed     = "UPDATE dedup_suppressed SET chat_id=$2 WHERE chat_id=$1"
+	deleteChatById        = "DELETE FROM chats WHERE id=$1"
+	deleteChatByCompanyId = "DELETE FROM chats WHERE company_id=$1"
+	deactivateChat        = `UPDATE chats SET active=false FROM companies, owners
+	WHERE chats.telegram_id=$1 AND chats.active AND companies.id=chats.company_id AND owners.id=companies.owner_id
+	RETURNING owners.telegram_id`
+	deleteOldCorrelations = `DELETE FROM correlations AS c WHERE c.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM correlations AS x WHERE x.company_id=c.company_id AND x.correlation_id=c.correlation_id AND x.chat_id=$2
+	)`
+	deleteOldThreadKeys = `DELETE FROM thread_keys AS t WHERE t.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM thread_keys AS x WHERE x.company_id=t.company_id AND x.thread_key=t.thread_key AND x.chat_id=$2
+	)`
+	deleteOldDedupKeys = `DELETE FROM dedup_keys AS k WHERE k.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM dedup_keys AS x WHERE x.company_id=k.company_id AND x.dedup_key=k.dedup_key AND x.chat_id=$2
+	)`
+	mergeSuppressed = `UPDATE dedup_suppressed AS n SET suppressed=n.suppressed+o.suppressed FROM dedup_suppressed AS o
+	WHERE o.chat_id=$1 AND n.company_id=o.company_id AND n.chat_id=$2`
+	deleteOldSuppressed = `DELETE FROM dedup_suppressed AS s WHERE s.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM dedup_suppressed AS x WHERE x.company_id=s.company_id AND x.chat_id=$2
+	)`
 )
 
 // GetChatsByCompanyId returns a slice of entities.Chat that belong to the company with the given ID.
@@ -48,7 +78,7 @@ func (s *ChatStorage) GetChatsByCompanyId(ctx context.Context, id int64) ([]enti
 	return chats, nil
 }
 
-// GetChatsByCompanyToken returns a slice of entities.Chat that belong to the company with the given token.
+// GetChatsByCompanyToken returns a slice of active entities.Chat that belong to the company with the given token.
 func (s *ChatStorage) GetChatsByCompanyToken(ctx context.Context, t string) ([]entities.Chat, error) {
 	const op = "storage.postgres.GetChatsByCompanyToken"
 
@@ -71,7 +101,7 @@ func (r *ChatStorage) AddChat(ctx context.Context, chat entities.Chat) (entities
 
 	newChat := entities.Chat{}
 
-	err := r.db.QueryRowxContext(ctx, addChat, chat.CompanyID, chat.TelegramID).StructScan(&newChat)
+	err := r.db.QueryRowxContext(ctx, addChat, chat.CompanyID, chat.TelegramID, chat.MessageThreadID, chat.Username).StructScan(&newChat)
 	if err != nil {
 		return newChat, fmt.Errorf("%s: execute query: %w", op, err)
 	}
@@ -79,6 +109,122 @@ func (r *ChatStorage) AddChat(ctx context.Context, chat entities.Chat) (entities
 	return newChat, nil
 }
 
+// SetQuietHours sets the quiet hours of the chat with the given ID.
+func (r *ChatStorage) SetQuietHours(ctx context.Context, id int64, q entities.QuietHours) error {
+	const op = "storage.postgres.SetQuietHours"
+
+	_, err := r.db.ExecContext(ctx, setQuietHours, id, q.Start, q.End, q.TimeZone)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetDigestWindow sets the digest window of the chat with the given ID in seconds, 0 turns the digest off.
+func (r *ChatStorage) SetDigestWindow(ctx context.Context, id int64, seconds int) error {
+	const op = "storage.postgres.SetDigestWindow"
+
+	_, err := r.db.ExecContext(ctx, setDigestWindow, id, seconds)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAlias sets the alias of the chat with the given ID, an empty alias removes it.
+// If another chat of the company has the alias, ErrAlreadyExists is returned.
+func (r *ChatStorage) SetAlias(ctx context.Context, id int64, alias string) error {
+	const op = "storage.postgres.SetAlias"
+
+	_, err := r.db.ExecContext(ctx, setAlias, id, alias)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return storage.ErrAlreadyExists
+		}
+
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// ActivateChat turns the chat with the given ID on again.
+func (r *ChatStorage) ActivateChat(ctx context.Context, id int64) error {
+	const op = "storage.postgres.ActivateChat"
+
+	_, err := r.db.ExecContext(ctx, activateChat, id)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// MigrateChat replaces the Telegram ID of the chats of all companies, when a group is upgraded to a supergroup it gets a new ID.
+// The ID is replaced in the routes, correlations, thread keys and deduplication state of the chat too.
+// If a company already has the supergroup, the rows of the group that would duplicate its rows are deleted,
+// only the suppressed duplicates of both chats are added up.
+func (r *ChatStorage) MigrateChat(ctx context.Context, from, to int64) (err error) {
+	const op = "storage.postgres.MigrateChat"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%s: rollback transaction: %w", op, rollbackErr)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{name: "chats", query: deleteOldChats},
+		{name: "chats", query: migrateChat},
+		{name: "routes", query: migrateRoutes},
+		{name: "correlations", query: deleteOldCorrelations},
+		{name: "correlations", query: migrateCorrelations},
+		{name: "thread keys", query: deleteOldThreadKeys},
+		{name: "thread keys", query: migrateThreadKeys},
+		{name: "dedup keys", query: deleteOldDedupKeys},
+		{name: "dedup keys", query: migrateDedupKeys},
+		{name: "dedup suppressed", query: mergeSuppressed},
+		{name: "dedup suppressed", query: deleteOldSuppressed},
+		{name: "dedup suppressed", query: migrateSuppressed},
+	}
+	for _, q := range queries {
+		_, err = tx.ExecContext(ctx, q.query, from, to)
+		if err != nil {
+			return fmt.Errorf("%s: migrate %s: %w", op, q.name, err)
+		}
+	}
+
+	return nil
+}
+
+// DeactivateChat turns off the active chats of all companies with the given Telegram ID
+// and returns the Telegram IDs of the owners of the companies, chats that are turned off already are skipped.
+func (r *ChatStorage) DeactivateChat(ctx context.Context, telegramID int64) ([]int64, error) {
+	const op = "storage.postgres.DeactivateChat"
+
+	owners := []int64{}
+
+	if err := r.db.SelectContext(ctx, &owners, deactivateChat, telegramID); err != nil {
+		return owners, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return owners, nil
+}
+
 // DeleteChatById deletes a chat from the database by its ID.
 func (r *ChatStorage) DeleteChatById(ctx context.Context, id int64) error {
 	const op = "storage.postgres.DeleteChatById"
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/testit-tms/webhook-bot/internal/entities"
 	"github.com/testit-tms/webhook-bot/internal/storage"
@@ -27,19 +28,25 @@ func TestChatStorage_GetChatsByCompanyId(t *testing.T) {
 				Id:         12,
 				CompanyID:  id,
 				TelegramID: 123456,
+				Active:     true,
+				QuietHours: entities.QuietHours{TimeZone: "UTC"},
 			},
 			{
-				Id:         13,
-				CompanyID:  id,
-				TelegramID: 654321,
+				Id:           13,
+				CompanyID:    id,
+				TelegramID:   654321,
+				DigestWindow: 300,
+				Alias:        "qa-team",
+				Username:     "our_qa_channel",
+				QuietHours:   entities.QuietHours{Start: 1320, End: 480, TimeZone: "Europe/Berlin"},
 			},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id"}).
-			AddRow("12", "21", "123456").
-			AddRow("13", "21", "654321")
+		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id", "message_thread_id", "digest_window", "alias", "username", "active", "quiet_start", "quiet_end", "time_zone"}).
+			AddRow("12", "21", "123456", "0", "0", "", "", "true", "0", "0", "UTC").
+			AddRow("13", "21", "654321", "0", "300", "qa-team", "our_qa_channel", "false", "1320", "480", "Europe/Berlin")
 
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=$1")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id, message_thread_id, digest_window, alias, username, active, quiet_start, quiet_end, time_zone FROM chats WHERE company_id=$1")).
 			WithArgs(id).
 			WillReturnRows(rows)
 		repo := New(f.DB)
@@ -59,7 +66,7 @@ func TestChatStorage_GetChatsByCompanyId(t *testing.T) {
 		defer f.Teardown()
 
 		var id int64 = 21
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=$1")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id, message_thread_id, digest_window, alias, username, active, quiet_start, quiet_end, time_zone FROM chats WHERE company_id=$1")).
 			WithArgs(id).
 			WillReturnError(sql.ErrNoRows)
 		repo := New(f.DB)
@@ -81,7 +88,7 @@ func TestChatStorage_GetChatsByCompanyId(t *testing.T) {
 		expectErr := errors.New("test error")
 
 		var id int64 = 21
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=$1")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id, message_thread_id, digest_window, alias, username, active, quiet_start, quiet_end, time_zone FROM chats WHERE company_id=$1")).
 			WithArgs(id).
 			WillReturnError(expectErr)
 		repo := New(f.DB)
@@ -108,19 +115,26 @@ func TestChatStorage_GetChatsByCompanyToken(t *testing.T) {
 				Id:         12,
 				CompanyID:  21,
 				TelegramID: 123456,
+				Active:     true,
+				QuietHours: entities.QuietHours{TimeZone: "UTC"},
 			},
 			{
-				Id:         13,
-				CompanyID:  21,
-				TelegramID: 654321,
+				Id:           13,
+				CompanyID:    21,
+				TelegramID:   654321,
+				DigestWindow: 300,
+				Alias:        "qa-team",
+				Username:     "our_qa_channel",
+				Active:       true,
+				QuietHours:   entities.QuietHours{Start: 1320, End: 480, TimeZone: "Europe/Berlin"},
 			},
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id"}).
-			AddRow("12", "21", "123456").
-			AddRow("13", "21", "654321")
+		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id", "message_thread_id", "digest_window", "alias", "username", "active", "quiet_start", "quiet_end", "time_zone"}).
+			AddRow("12", "21", "123456", "0", "0", "", "", "true", "0", "0", "UTC").
+			AddRow("13", "21", "654321", "0", "300", "qa-team", "our_qa_channel", "true", "1320", "480", "Europe/Berlin")
 
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=(SELECT id FROM companies WHERE token=$1)")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getChatsByCompanyToken)).
 			WithArgs(token).
 			WillReturnRows(rows)
 		repo := New(f.DB)
@@ -140,7 +154,7 @@ func TestChatStorage_GetChatsByCompanyToken(t *testing.T) {
 		defer f.Teardown()
 
 		token := "123"
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=(SELECT id FROM companies WHERE token=$1)")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getChatsByCompanyToken)).
 			WithArgs(token).
 			WillReturnError(sql.ErrNoRows)
 		repo := New(f.DB)
@@ -162,7 +176,7 @@ func TestChatStorage_GetChatsByCompanyToken(t *testing.T) {
 		expectErr := errors.New("test error")
 
 		token := "123"
-		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, telegram_id FROM chats WHERE company_id=(SELECT id FROM companies WHERE token=$1)")).
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getChatsByCompanyToken)).
 			WithArgs(token).
 			WillReturnError(expectErr)
 		repo := New(f.DB)
@@ -183,15 +197,18 @@ func TestChatStorage_AddChat(t *testing.T) {
 		f := database.NewFixture(t)
 		defer f.Teardown()
 		expectedChat := entities.Chat{
-			Id:         12,
-			CompanyID:  21,
-			TelegramID: 123456,
+			Id:              12,
+			CompanyID:       21,
+			TelegramID:      123456,
+			MessageThreadID: 77,
+			Username:        "our_qa_channel",
+			Active:          true,
 		}
-		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id"}).
-			AddRow(12, 21, "123456")
+		rows := sqlmock.NewRows([]string{"id", "company_id", "telegram_id", "message_thread_id", "username", "active"}).
+			AddRow(12, 21, "123456", "77", "our_qa_channel", "true")
 
-		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO chats (company_id, telegram_id) VALUES ($1, $2) RETURNING id, company_id, telegram_id")).
-			WithArgs(expectedChat.CompanyID, expectedChat.TelegramID).
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO chats (company_id, telegram_id, message_thread_id, username) VALUES ($1, $2, $3, $4) RETURNING id, company_id, telegram_id, message_thread_id, username, active")).
+			WithArgs(expectedChat.CompanyID, expectedChat.TelegramID, expectedChat.MessageThreadID, expectedChat.Username).
 			WillReturnRows(rows)
 
 		repo := New(f.DB)
@@ -217,8 +234,8 @@ func TestChatStorage_AddChat(t *testing.T) {
 			TelegramID: 123456,
 		}
 
-		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO chats (company_id, telegram_id) VALUES ($1, $2) RETURNING id, company_id, telegram_id")).
-			WithArgs(expectedChat.CompanyID, expectedChat.TelegramID).
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO chats (company_id, telegram_id, message_thread_id, username) VALUES ($1, $2, $3, $4) RETURNING id, company_id, telegram_id, message_thread_id, username, active")).
+			WithArgs(expectedChat.CompanyID, expectedChat.TelegramID, expectedChat.MessageThreadID, expectedChat.Username).
 			WillReturnError(expectErr)
 
 		repo := New(f.DB)
@@ -319,3 +336,445 @@ func TestChatStorage_DeleteChatByCompanyId(t *testing.T) {
 		assert.ErrorIs(t, err, expectErr)
 	})
 }
+
+func TestChatStorage_SetQuietHours(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE chats SET quiet_start=$2, quiet_end=$3, time_zone=$4 WHERE id=$1")).
+			WithArgs(int64(12), 1320, 480, "Europe/Berlin").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetQuietHours(context.Background(), 12, entities.QuietHours{Start: 1320, End: 480, TimeZone: "Europe/Berlin"})
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setQuietHours)).
+			WithArgs(int64(12), 0, 0, "UTC").
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetQuietHours(context.Background(), 12, entities.QuietHours{TimeZone: "UTC"})
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestChatStorage_SetDigestWindow(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE chats SET digest_window=$2 WHERE id=$1")).
+			WithArgs(int64(12), 300).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetDigestWindow(context.Background(), 12, 300)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setDigestWindow)).
+			WithArgs(int64(12), 0).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetDigestWindow(context.Background(), 12, 0)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestChatStorage_SetAlias(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE chats SET alias=$2 WHERE id=$1")).
+			WithArgs(int64(12), "qa-team").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetAlias(context.Background(), 12, "qa-team")
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("alias of other chat", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setAlias)).
+			WithArgs(int64(12), "qa-team").
+			WillReturnError(&pq.Error{Code: "23505"})
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetAlias(context.Background(), 12, "qa-team")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setAlias)).
+			WithArgs(int64(12), "").
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetAlias(context.Background(), 12, "")
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestChatStorage_ActivateChat(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE chats SET active=true WHERE id=$1")).
+			WithArgs(int64(12)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.ActivateChat(context.Background(), 12)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(activateChat)).
+			WithArgs(int64(12)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.ActivateChat(context.Background(), 12)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestChatStorage_MigrateChat(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM chats AS c WHERE c.telegram_id=$1 AND EXISTS (SELECT 1 FROM chats AS x WHERE x.company_id=c.company_id AND x.telegram_id=$2)")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE chats SET telegram_id=$2 WHERE telegram_id=$1")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE routes SET chat_ids=CASE WHEN $2=ANY(chat_ids) THEN array_remove(chat_ids, $1) ELSE array_replace(chat_ids, $1, $2) END WHERE $1=ANY(chat_ids)")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM correlations AS c WHERE c.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM correlations AS x WHERE x.company_id=c.company_id AND x.correlation_id=c.correlation_id AND x.chat_id=$2
+	)`)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE correlations SET chat_id=$2 WHERE chat_id=$1")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM thread_keys AS t WHERE t.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM thread_keys AS x WHERE x.company_id=t.company_id AND x.thread_key=t.thread_key AND x.chat_id=$2
+	)`)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE thread_keys SET chat_id=$2 WHERE chat_id=$1")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM dedup_keys AS k WHERE k.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM dedup_keys AS x WHERE x.company_id=k.company_id AND x.dedup_key=k.dedup_key AND x.chat_id=$2
+	)`)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE dedup_keys SET chat_id=$2 WHERE chat_id=$1")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`UPDATE dedup_suppressed AS n SET suppressed=n.suppressed+o.suppressed FROM dedup_suppressed AS o
+	WHERE o.chat_id=$1 AND n.company_id=o.company_id AND n.chat_id=$2`)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM dedup_suppressed AS s WHERE s.chat_id=$1 AND EXISTS (
+		SELECT 1 FROM dedup_suppressed AS x WHERE x.company_id=s.company_id AND x.chat_id=$2
+	)`)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE dedup_suppressed SET chat_id=$2 WHERE chat_id=$1")).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("supergroup already added", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		// the rows of the group that collide with the rows of the supergroup are deleted before the rest is moved
+		queries := []struct {
+			query    string
+			affected int64
+		}{
+			{query: deleteOldChats, affected: 1},
+			{query: migrateChat, affected: 1},
+			{query: migrateRoutes, affected: 1},
+			{query: deleteOldCorrelations, affected: 2},
+			{query: migrateCorrelations, affected: 1},
+			{query: deleteOldThreadKeys, affected: 1},
+			{query: migrateThreadKeys, affected: 0},
+			{query: deleteOldDedupKeys, affected: 1},
+			{query: migrateDedupKeys, affected: 0},
+			{query: mergeSuppressed, affected: 1},
+			{query: deleteOldSuppressed, affected: 1},
+			{query: migrateSuppressed, affected: 0},
+		}
+
+		f.Mock.ExpectBegin()
+		for _, q := range queries {
+			f.Mock.ExpectExec(regexp.QuoteMeta(q.query)).
+				WithArgs(int64(-123), int64(-100123)).
+				WillReturnResult(sqlmock.NewResult(0, q.affected))
+		}
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("with error on conflicting rows", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteOldChats)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnError(expectErr)
+		f.Mock.ExpectRollback()
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteOldChats)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(migrateChat)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnError(expectErr)
+		f.Mock.ExpectRollback()
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+
+	t.Run("with error on routes", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteOldChats)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(migrateChat)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		f.Mock.ExpectExec(regexp.QuoteMeta(migrateRoutes)).
+			WithArgs(int64(-123), int64(-100123)).
+			WillReturnError(expectErr)
+		f.Mock.ExpectRollback()
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+
+	t.Run("with error on begin", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectBegin().
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MigrateChat(context.Background(), -123, -100123)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestChatStorage_DeactivateChat(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"telegram_id"}).
+			AddRow("368414991").
+			AddRow("368414992")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(`UPDATE chats SET active=false FROM companies, owners
+	WHERE chats.telegram_id=$1 AND chats.active AND companies.id=chats.company_id AND owners.id=companies.owner_id
+	RETURNING owners.telegram_id`)).
+			WithArgs(int64(-100123)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		owners, err := repo.DeactivateChat(context.Background(), -100123)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{368414991, 368414992}, owners)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(deactivateChat)).
+			WithArgs(int64(-100123)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		owners, err := repo.DeactivateChat(context.Background(), -100123)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, []int64{}, owners)
+	})
+}
@@ -28,6 +28,7 @@ const (
 	getCompanyByOwnerId   = "SELECT c.id, c.token, c.owner_id, c.name, c.email FROM companies AS c INNER JOIN owners As o ON o.id = c.owner_id WHERE o.telegram_id=$1"
 	getCompanyIdByName    = "SELECT id FROM companies WHERE name=$1"
 	updateToken           = "UPDATE companies SET token=$1 WHERE id=$2"
+	setDedupWindow        = "UPDATE companies SET dedup_window=$2 WHERE id=$1"
 	deleteCompany         = "DELETE FROM companies WHERE id=$1"
 	deleteChatByCompanyId = "DELETE FROM chats WHERE company_id=$1"
 )
@@ -77,6 +78,19 @@ func (s *CompanyStorage) UpdateToken(ctx context.Context, companyId int64, token
 	return nil
 }
 
+// SetDedupWindow sets the window of the company in seconds, duplicates of a message sent to a chat within it are suppressed.
+// A zero window turns the deduplication off.
+func (s *CompanyStorage) SetDedupWindow(ctx context.Context, companyId int64, seconds int) error {
+	const op = "storage.postgres.SetDedupWindow"
+
+	_, err := s.db.ExecContext(ctx, setDedupWindow, companyId, seconds)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
 // DeleteCompany deletes a company by its ID.
 func (s *CompanyStorage) DeleteCompany(ctx context.Context, companyId int64) (err error) {
 	const op = "storage.postgres.DeleteCompany"
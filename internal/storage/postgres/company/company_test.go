@@ -373,3 +373,45 @@ func TestCompanyStorage_DeleteCompany(t *testing.T) {
 		assert.ErrorIs(t, err, rollbackErr)
 	})
 }
+
+func TestCompanyStorage_SetDedupWindow(t *testing.T) {
+	t.Run("with company", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE companies SET dedup_window=$2 WHERE id=$1")).
+			WithArgs(int64(12), 600).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetDedupWindow(context.Background(), 12, 600)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setDedupWindow)).
+			WithArgs(int64(12), 0).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetDedupWindow(context.Background(), 12, 0)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
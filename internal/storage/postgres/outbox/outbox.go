@@ -0,0 +1,683 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+// OutboxStorage is a storage implementation for the outbox of webhook messages using PostgreSQL.
+type OutboxStorage struct {
+	db *sqlx.DB
+}
+
+// New returns a new instance of OutboxStorage with the given database connection.
+func New(db *sqlx.DB) *OutboxStorage {
+	return &OutboxStorage{
+		db: db,
+	}
+}
+
+const (
+	addMessage           = "INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id"
+	addDelivery          = "INSERT INTO outbox_deliveries (message_id, chat_id) VALUES ($1, $2) RETURNING id, message_id, chat_id, status, attempts, last_error"
+	addScheduledDelivery = `INSERT INTO outbox_deliveries (message_id, chat_id, next_attempt_at) VALUES ($1, $2, $3)
+	RETURNING id, message_id, chat_id, status, attempts, last_error`
+	addDigestDelivery = `INSERT INTO outbox_deliveries (message_id, chat_id, digest, next_attempt_at) VALUES ($1, $2, true, COALESCE(
+		(SELECT min(d.next_attempt_at) FROM outbox_deliveries AS d INNER JOIN outbox_messages AS m ON m.id=d.message_id
+		WHERE d.chat_id=$2 AND d.digest AND d.status='queued' AND m.company_id=$3),
+		now()+$4*interval '1 millisecond'
+	))
+	RETURNING id, message_id, chat_id, status, attempts, last_error`
+	addClaimedDelivery = `INSERT INTO outbox_deliveries (message_id, chat_id, status, attempts, next_attempt_at) VALUES ($1, $2, 'sending', 1, $3)
+	RETURNING id, message_id, chat_id, status, attempts, last_error`
+	claimDeliveries = `WITH claimed AS (
+		UPDATE outbox_deliveries SET status='sending', attempts=attempts+1, next_attempt_at=now()+$2*interval '1 millisecond', updated_at=now()
+		WHERE id IN (
+			SELECT id FROM outbox_deliveries WHERE status IN ('queued', 'sending') AND next_attempt_at<=now()
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, message_id, chat_id, status, attempts, last_error
+	)
+	SELECT c.id, c.message_id, c.chat_id, c.status, c.attempts, c.last_error, m.company_id, m.payload
+	FROM claimed AS c INNER JOIN outbox_messages AS m ON m.id=c.message_id ORDER BY c.id`
+	claimDigest = `WITH claimed AS (
+		UPDATE outbox_deliveries SET status='sending', attempts=attempts+1, next_attempt_at=now()+$3*interval '1 millisecond', updated_at=now()
+		WHERE id IN (
+			SELECT d.id FROM outbox_deliveries AS d INNER JOIN outbox_messages AS m ON m.id=d.message_id
+			WHERE m.company_id=$1 AND d.chat_id=$2 AND d.digest AND d.status='queued'
+			ORDER BY d.id FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING id, message_id, chat_id, status, attempts, last_error
+	)
+	SELECT c.id, c.message_id, c.chat_id, c.status, c.attempts, c.last_error, m.company_id, m.payload
+	FROM claimed AS c INNER JOIN outbox_messages AS m ON m.id=c.message_id ORDER BY c.id`
+	markDelivered = `UPDATE outbox_deliveries SET status='delivered', digest_id=$3, telegram_message_id=$4, telegram_message_ids=$5, plain_text=$6, last_error='', updated_at=now()
+	WHERE id=$1 AND status='sending' AND attempts=$2`
+	scheduleRetry = "UPDATE outbox_deliveries SET status='queued', next_attempt_at=$3, last_error=$4, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2"
+	postpone      = "UPDATE outbox_deliveries SET status='queued', attempts=GREATEST(attempts-1, 0), next_attempt_at=$3, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2"
+	markFailed    = "UPDATE outbox_deliveries SET status='failed', last_error=$3, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2"
+	markDeleted   = "UPDATE outbox_deliveries SET status='deleted', updated_at=now() WHERE id=$1 AND status IN ('queued', 'delivered')"
+	getDeliveries = `SELECT d.id, d.message_id, d.chat_id, d.status, d.attempts, d.last_error, d.telegram_message_id, d.plain_text, d.telegram_message_ids, d.digest_id
+	FROM outbox_deliveries AS d INNER JOIN outbox_messages AS m ON m.id=d.message_id INNER JOIN companies AS c ON c.id=m.company_id
+	WHERE d.message_id=$1 AND c.token=$2 ORDER BY d.id`
+	getDeliveriesByFilter = `SELECT d.id, d.message_id, d.chat_id, d.status, d.attempts, d.last_error, d.telegram_message_id, d.plain_text, d.telegram_message_ids, d.digest_id
+	FROM outbox_deliveries AS d WHERE d.message_id IN (
+		SELECT m.id FROM outbox_messages AS m INNER JOIN companies AS c ON c.id=m.company_id
+		WHERE c.token=$1 AND ($2::text='' OR m.payload->>'correlationId'=$2::text)
+		AND ($3::timestamptz IS NULL OR m.created_at>=$3) AND ($4::timestamptz IS NULL OR m.created_at<$4)
+		AND EXISTS (SELECT 1 FROM outbox_deliveries AS x WHERE x.message_id=m.id AND x.status IN ('queued', 'delivered'))
+		ORDER BY m.id LIMIT $5
+	) ORDER BY d.message_id, d.id`
+	deleteExpiredKeys = "DELETE FROM idempotency_keys WHERE expires_at<=now()"
+	addKey            = "INSERT INTO idempotency_keys (company_id, idempotency_key, message_id, expires_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING"
+	getMessageIdByKey = "SELECT message_id FROM idempotency_keys WHERE company_id=$1 AND idempotency_key=$2 AND expires_at>now()"
+	getCorrelated     = "SELECT telegram_message_id FROM correlations WHERE company_id=$1 AND correlation_id=$2 AND chat_id=$3"
+	setCorrelated     = `INSERT INTO correlations (company_id, correlation_id, chat_id, telegram_message_id) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (company_id, correlation_id, chat_id) DO UPDATE SET telegram_message_id=EXCLUDED.telegram_message_id, updated_at=now()`
+	getThreadMessageId = "SELECT telegram_message_id FROM thread_keys WHERE company_id=$1 AND thread_key=$2 AND chat_id=$3 AND expires_at>now()"
+	addThreadMessageId = `INSERT INTO thread_keys (company_id, thread_key, chat_id, telegram_message_id, expires_at) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (company_id, thread_key, chat_id) DO UPDATE SET telegram_message_id=EXCLUDED.telegram_message_id, expires_at=EXCLUDED.expires_at
+	WHERE thread_keys.expires_at<=now()`
+	deleteExpiredThreadKeys = "DELETE FROM thread_keys WHERE expires_at<=now()"
+	markSuppressed          = "UPDATE outbox_deliveries SET status='suppressed', updated_at=now() WHERE id=$1"
+	getDedupState           = `SELECT c.dedup_window,
+	EXISTS (SELECT 1 FROM dedup_keys WHERE company_id=c.id AND chat_id=$2 AND dedup_key=$3 AND expires_at>now()) AS duplicate,
+	COALESCE((SELECT suppressed FROM dedup_suppressed WHERE company_id=c.id AND chat_id=$2), 0) AS suppressed
+	FROM companies AS c WHERE c.id=$1`
+	addSuppressed = `INSERT INTO dedup_suppressed (company_id, chat_id, suppressed) VALUES ($1, $2, 1)
+	ON CONFLICT (company_id, chat_id) DO UPDATE SET suppressed=dedup_suppressed.suppressed+1`
+	addDedupKey = `INSERT INTO dedup_keys (company_id, chat_id, dedup_key, expires_at) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (company_id, chat_id, dedup_key) DO UPDATE SET expires_at=EXCLUDED.expires_at`
+	releaseSuppressed      = "UPDATE dedup_suppressed SET suppressed=GREATEST(suppressed-$3, 0) WHERE company_id=$1 AND chat_id=$2"
+	deleteExpiredDedupKeys = "DELETE FROM dedup_keys WHERE expires_at<=now()"
+	clearAttachments       = `UPDATE outbox_messages AS m SET payload=jsonb_set(m.payload, '{attachments}',
+		(SELECT jsonb_agg(a - 'Data') FROM jsonb_array_elements(m.payload->'attachments') AS a))
+	WHERE m.id=(SELECT message_id FROM outbox_deliveries WHERE id=$1)
+	AND EXISTS (SELECT 1 FROM jsonb_array_elements(m.payload->'attachments') AS a WHERE a->>'Data' IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM outbox_deliveries AS d WHERE d.message_id=m.id AND d.status IN ('queued', 'sending'))`
+	deleteFinishedMessages = `DELETE FROM outbox_messages WHERE id IN (
+		SELECT m.id FROM outbox_messages AS m WHERE m.created_at<now()-$1*interval '1 millisecond'
+		AND NOT EXISTS (SELECT 1 FROM outbox_deliveries AS d WHERE d.message_id=m.id AND d.status IN ('queued', 'sending'))
+		ORDER BY m.id LIMIT $2
+	)`
+	countDigestDelivered = "SELECT count(*) FROM outbox_deliveries WHERE digest_id=$1 AND id<>$2 AND status='delivered'"
+)
+
+// payload is the part of entities.Message persisted with an outbox message.
+type payload struct {
+	Text        string                        `json:"text"`
+	ParseMode   entities.ParseMode            `json:"parseMode"`
+	Attachments []entities.Attachment         `json:"attachments,omitempty"`
+	Buttons     [][]entities.Button           `json:"buttons,omitempty"`
+	ThreadIDs   map[int64]int                 `json:"threadIds,omitempty"`
+	Correlation string                        `json:"correlationId,omitempty"`
+	ThreadKey   string                        `json:"threadKey,omitempty"`
+	QuietHours  map[int64]entities.QuietHours `json:"quietHours,omitempty"`
+	Urgent      bool                          `json:"urgent,omitempty"`
+	Digests     map[int64]time.Duration       `json:"digests,omitempty"`
+	DedupKey    string                        `json:"dedupKey,omitempty"`
+
+	DisableNotification   bool `json:"disableNotification,omitempty"`
+	ProtectContent        bool `json:"protectContent,omitempty"`
+	DisableWebPagePreview bool `json:"disableWebPagePreview,omitempty"`
+	ReplyToMessageID      int  `json:"replyToMessageId,omitempty"`
+}
+
+type deliveryRow struct {
+	entities.Delivery
+	CompanyID int64  `db:"company_id"`
+	Payload   []byte `db:"payload"`
+}
+
+type deliveryStateRow struct {
+	entities.Delivery
+	MessageIDs pq.Int64Array `db:"telegram_message_ids"`
+}
+
+// AddMessage stores the message in the outbox and queues a delivery for each of its chats.
+// If claimUntil is not zero, the deliveries are stored as already claimed by the caller until that time,
+// so workers pick them up only if the caller does not record the result in time.
+// Otherwise the deliveries of a message with SendAt are due at that time.
+// Deliveries to chats in digest mode are never claimed, they are due when the digest window of the chat ends,
+// the window starts with the first message collected into the digest.
+// If the message has an idempotency key that is already used by the company, storage.ErrAlreadyExists is returned and nothing is stored.
+// It returns the ID of the stored message and its deliveries.
+func (s *OutboxStorage) AddMessage(ctx context.Context, msg entities.Message, claimUntil time.Time) (id int64, deliveries []entities.Delivery, err error) {
+	const op = "storage.postgres.AddMessage"
+
+	p, err := json.Marshal(payload{
+		Text:        msg.Text,
+		ParseMode:   msg.ParseMode,
+		Attachments: msg.Attachments,
+		Buttons:     msg.Buttons,
+		ThreadIDs:   msg.ThreadIDs,
+		Correlation: msg.CorrelationID,
+		ThreadKey:   msg.ThreadKey,
+		QuietHours:  msg.QuietHours,
+		Urgent:      msg.Urgent,
+		Digests:     msg.Digests,
+		DedupKey:    msg.DedupKey,
+
+		DisableNotification:   msg.DisableNotification,
+		ProtectContent:        msg.ProtectContent,
+		DisableWebPagePreview: msg.DisableWebPagePreview,
+		ReplyToMessageID:      msg.ReplyToMessageID,
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: marshal payload: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%s: rollback transaction: %w", op, rollbackErr)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if msg.Idempotency.Key != "" {
+		if _, err = tx.ExecContext(ctx, deleteExpiredKeys); err != nil {
+			return 0, nil, fmt.Errorf("%s: delete expired idempotency keys: %w", op, err)
+		}
+	}
+
+	if err = tx.QueryRowxContext(ctx, addMessage, msg.CompanyID, string(p)).Scan(&id); err != nil {
+		return 0, nil, fmt.Errorf("%s: add message: %w", op, err)
+	}
+
+	if msg.Idempotency.Key != "" {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, addKey, msg.CompanyID, msg.Idempotency.Key, id, msg.Idempotency.ExpiresAt)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%s: add idempotency key: %w", op, err)
+		}
+
+		var added int64
+		if added, err = res.RowsAffected(); err != nil {
+			return 0, nil, fmt.Errorf("%s: add idempotency key: %w", op, err)
+		}
+		if added == 0 {
+			err = storage.ErrAlreadyExists
+			return 0, nil, err
+		}
+	}
+
+	deliveries = make([]entities.Delivery, 0, len(msg.ChatIds))
+	for _, chatID := range msg.ChatIds {
+		var row *sqlx.Row
+		window, digest := msg.Digests[chatID]
+		switch {
+		case digest:
+			row = tx.QueryRowxContext(ctx, addDigestDelivery, id, chatID, msg.CompanyID, window.Milliseconds())
+		case !claimUntil.IsZero():
+			row = tx.QueryRowxContext(ctx, addClaimedDelivery, id, chatID, claimUntil)
+		case !msg.SendAt.IsZero():
+			row = tx.QueryRowxContext(ctx, addScheduledDelivery, id, chatID, msg.SendAt)
+		default:
+			row = tx.QueryRowxContext(ctx, addDelivery, id, chatID)
+		}
+
+		d := entities.Delivery{}
+		if err = row.StructScan(&d); err != nil {
+			return 0, nil, fmt.Errorf("%s: add delivery: %w", op, err)
+		}
+
+		d.Message = msg
+		d.Message.ChatIds = []int64{chatID}
+		deliveries = append(deliveries, d)
+	}
+
+	return id, deliveries, nil
+}
+
+// ClaimDeliveries locks up to limit deliveries that are due and marks them as sending.
+// A claimed delivery becomes due again after the lease expires, so deliveries of a crashed worker are picked up later.
+func (s *OutboxStorage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entities.Delivery, error) {
+	const op = "storage.postgres.ClaimDeliveries"
+
+	rows := []deliveryRow{}
+
+	if err := s.db.SelectContext(ctx, &rows, claimDeliveries, limit, lease.Milliseconds()); err != nil {
+		return nil, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	deliveries, err := convertRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDigest locks the queued deliveries collected into the digest of the chat of the company and marks them as sending,
+// whether they are due or not. The claimed deliveries become due again after the lease expires like in ClaimDeliveries.
+func (s *OutboxStorage) ClaimDigest(ctx context.Context, companyID, chatID int64, lease time.Duration) ([]entities.Delivery, error) {
+	const op = "storage.postgres.ClaimDigest"
+
+	rows := []deliveryRow{}
+
+	if err := s.db.SelectContext(ctx, &rows, claimDigest, companyID, chatID, lease.Milliseconds()); err != nil {
+		return nil, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	deliveries, err := convertRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// convertRows converts the claimed rows to deliveries with the messages restored from their payloads.
+func convertRows(rows []deliveryRow) ([]entities.Delivery, error) {
+	deliveries := make([]entities.Delivery, 0, len(rows))
+	for _, r := range rows {
+		var p payload
+		if err := json.Unmarshal(r.Payload, &p); err != nil {
+			return nil, fmt.Errorf("unmarshal payload of message %d: %w", r.MessageID, err)
+		}
+
+		d := r.Delivery
+		d.Message = entities.Message{
+			Text:        p.Text,
+			ParseMode:   p.ParseMode,
+			CompanyID:   r.CompanyID,
+			ChatIds:     []int64{r.ChatID},
+			Attachments: p.Attachments,
+			Buttons:     p.Buttons,
+			ThreadIDs:   p.ThreadIDs,
+
+			CorrelationID:         p.Correlation,
+			ThreadKey:             p.ThreadKey,
+			QuietHours:            p.QuietHours,
+			Urgent:                p.Urgent,
+			Digests:               p.Digests,
+			DedupKey:              p.DedupKey,
+			DisableNotification:   p.DisableNotification,
+			ProtectContent:        p.ProtectContent,
+			DisableWebPagePreview: p.DisableWebPagePreview,
+			ReplyToMessageID:      p.ReplyToMessageID,
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered marks the delivery as successfully sent and stores the IDs of the Telegram messages
+// and whether it was sent as plain text. The deliveries sent in a digest are marked with the same digestID, otherwise it is 0.
+// Like the other results of a claimed delivery, it is recorded only if the delivery is still claimed for the given attempt.
+// Every claim counts an attempt, so if the lease expired and the delivery was claimed again, storage.ErrLeaseExpired is returned
+// and the result of the new claim is kept.
+// Once all deliveries of the message are finished, the data of its uploaded files is removed from the outbox.
+func (s *OutboxStorage) MarkDelivered(ctx context.Context, id int64, attempt int, digestID int64, telegramMessageIDs []int, plainText bool) error {
+	const op = "storage.postgres.MarkDelivered"
+
+	ids := make(pq.Int64Array, 0, len(telegramMessageIDs))
+	for _, messageID := range telegramMessageIDs {
+		ids = append(ids, int64(messageID))
+	}
+
+	var first int
+	if len(telegramMessageIDs) > 0 {
+		first = telegramMessageIDs[0]
+	}
+
+	res, err := s.db.ExecContext(ctx, markDelivered, id, attempt, digestID, first, ids, plainText)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if err := checkLease(res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, clearAttachments, id); err != nil {
+		return fmt.Errorf("%s: clear attachments: %w", op, err)
+	}
+
+	return nil
+}
+
+// ScheduleRetry returns the delivery claimed for the given attempt to the queue to be sent again at the given time.
+func (s *OutboxStorage) ScheduleRetry(ctx context.Context, id int64, attempt int, at time.Time, reason string) error {
+	const op = "storage.postgres.ScheduleRetry"
+
+	res, err := s.db.ExecContext(ctx, scheduleRetry, id, attempt, at, reason)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if err := checkLease(res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Postpone returns the delivery claimed for the given attempt to the queue to be sent at the given time without counting the attempt.
+func (s *OutboxStorage) Postpone(ctx context.Context, id int64, attempt int, at time.Time) error {
+	const op = "storage.postgres.Postpone"
+
+	res, err := s.db.ExecContext(ctx, postpone, id, attempt, at)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if err := checkLease(res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkFailed marks the delivery claimed for the given attempt as failed, it will not be retried anymore.
+// The data of uploaded files is removed like in MarkDelivered.
+func (s *OutboxStorage) MarkFailed(ctx context.Context, id int64, attempt int, reason string) error {
+	const op = "storage.postgres.MarkFailed"
+
+	res, err := s.db.ExecContext(ctx, markFailed, id, attempt, reason)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if err := checkLease(res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, clearAttachments, id); err != nil {
+		return fmt.Errorf("%s: clear attachments: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkLease returns storage.ErrLeaseExpired if the result of a claimed delivery was not recorded,
+// because the delivery is not claimed for the attempt anymore.
+func checkLease(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return storage.ErrLeaseExpired
+	}
+
+	return nil
+}
+
+// MarkDeleted marks the queued or delivered delivery as deleted, queued deliveries are not sent anymore.
+// If the delivery is in another state, ErrNotFound is returned.
+// The data of uploaded files is removed like in MarkDelivered.
+func (s *OutboxStorage) MarkDeleted(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkDeleted"
+
+	res, err := s.db.ExecContext(ctx, markDeleted, id)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get affected rows: %w", op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	if _, err := s.db.ExecContext(ctx, clearAttachments, id); err != nil {
+		return fmt.Errorf("%s: clear attachments: %w", op, err)
+	}
+
+	return nil
+}
+
+// CountDigestDelivered returns the number of delivered deliveries of the digest other than the delivery with the given ID.
+func (s *OutboxStorage) CountDigestDelivered(ctx context.Context, digestID, exceptID int64) (int, error) {
+	const op = "storage.postgres.CountDigestDelivered"
+
+	var count int
+
+	if err := s.db.GetContext(ctx, &count, countDigestDelivered, digestID, exceptID); err != nil {
+		return 0, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// GetDeliveries returns the deliveries of the message with the given ID that belongs to the company with the given token.
+func (s *OutboxStorage) GetDeliveries(ctx context.Context, messageID int64, token string) ([]entities.Delivery, error) {
+	const op = "storage.postgres.GetDeliveries"
+
+	rows := []deliveryStateRow{}
+
+	if err := s.db.SelectContext(ctx, &rows, getDeliveries, messageID, token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []entities.Delivery{}, storage.ErrNotFound
+		}
+
+		return []entities.Delivery{}, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return convertStateRows(rows), nil
+}
+
+// GetDeliveriesByFilter returns the deliveries of at most limit messages of the company with the given token
+// that match the filter and still have queued or delivered deliveries, the oldest messages first.
+func (s *OutboxStorage) GetDeliveriesByFilter(ctx context.Context, token string, filter entities.MessageFilter, limit int) ([]entities.Delivery, error) {
+	const op = "storage.postgres.GetDeliveriesByFilter"
+
+	rows := []deliveryStateRow{}
+
+	err := s.db.SelectContext(ctx, &rows, getDeliveriesByFilter, token, filter.CorrelationID, nullTime(filter.From), nullTime(filter.To), limit)
+	if err != nil {
+		return []entities.Delivery{}, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return convertStateRows(rows), nil
+}
+
+func convertStateRows(rows []deliveryStateRow) []entities.Delivery {
+	deliveries := make([]entities.Delivery, 0, len(rows))
+	for _, r := range rows {
+		d := r.Delivery
+		for _, id := range r.MessageIDs {
+			d.TelegramMessageIDs = append(d.TelegramMessageIDs, int(id))
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetMessageIdByIdempotencyKey returns the ID of the message stored with the idempotency key of the company.
+// If the key is not found or expired, ErrNotFound is returned.
+func (s *OutboxStorage) GetMessageIdByIdempotencyKey(ctx context.Context, companyID int64, key string) (int64, error) {
+	const op = "storage.postgres.GetMessageIdByIdempotencyKey"
+
+	var id int64
+
+	if err := s.db.GetContext(ctx, &id, getMessageIdByKey, companyID, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+
+		return 0, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetCorrelatedMessageId returns the ID of the Telegram message sent to the chat for the correlation ID of the company.
+// If there is no such message, ErrNotFound is returned.
+func (s *OutboxStorage) GetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64) (int, error) {
+	const op = "storage.postgres.GetCorrelatedMessageId"
+
+	var id int
+
+	if err := s.db.GetContext(ctx, &id, getCorrelated, companyID, correlationID, chatID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+
+		return 0, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SetCorrelatedMessageId stores the ID of the Telegram message sent to the chat for the correlation ID of the company.
+func (s *OutboxStorage) SetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64, telegramMessageID int) error {
+	const op = "storage.postgres.SetCorrelatedMessageId"
+
+	_, err := s.db.ExecContext(ctx, setCorrelated, companyID, correlationID, chatID, telegramMessageID)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetThreadMessageId returns the ID of the first Telegram message sent to the chat with the thread key of the company.
+// If there is no such message or the key is expired, ErrNotFound is returned.
+func (s *OutboxStorage) GetThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64) (int, error) {
+	const op = "storage.postgres.GetThreadMessageId"
+
+	var id int
+
+	if err := s.db.GetContext(ctx, &id, getThreadMessageId, companyID, threadKey, chatID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+
+		return 0, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// AddThreadMessageId stores the ID of the first Telegram message sent to the chat with the thread key of the company
+// until the key expires. The message of a key that is not expired yet is kept.
+// Expired keys of all companies are deleted.
+func (s *OutboxStorage) AddThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64, telegramMessageID int, expiresAt time.Time) error {
+	const op = "storage.postgres.AddThreadMessageId"
+
+	if _, err := s.db.ExecContext(ctx, deleteExpiredThreadKeys); err != nil {
+		return fmt.Errorf("%s: delete expired keys: %w", op, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, addThreadMessageId, companyID, threadKey, chatID, telegramMessageID, expiresAt); err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkSuppressed marks the delivery as suppressed, it duplicates a message recently sent to the chat and is not sent.
+// The data of uploaded files is removed like in MarkDelivered.
+func (s *OutboxStorage) MarkSuppressed(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkSuppressed"
+
+	_, err := s.db.ExecContext(ctx, markSuppressed, id)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, clearAttachments, id); err != nil {
+		return fmt.Errorf("%s: clear attachments: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteFinishedMessages deletes up to limit messages stored longer than the retention whose deliveries are all finished,
+// together with their deliveries and idempotency keys. It returns the number of deleted messages.
+func (s *OutboxStorage) DeleteFinishedMessages(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	const op = "storage.postgres.DeleteFinishedMessages"
+
+	res, err := s.db.ExecContext(ctx, deleteFinishedMessages, retention.Milliseconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get affected rows: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// GetDedupState returns the deduplication window of the company, whether a message with the deduplication key
+// was sent to the chat within it and the number of duplicates suppressed in the chat since they were last reported.
+// If the company is not found, ErrNotFound is returned.
+func (s *OutboxStorage) GetDedupState(ctx context.Context, companyID, chatID int64, dedupKey string) (entities.DedupState, error) {
+	const op = "storage.postgres.GetDedupState"
+
+	state := entities.DedupState{}
+
+	if err := s.db.GetContext(ctx, &state, getDedupState, companyID, chatID, dedupKey); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return state, storage.ErrNotFound
+		}
+
+		return state, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return state, nil
+}
+
+// AddSuppressed counts a duplicate suppressed in the chat of the company.
+func (s *OutboxStorage) AddSuppressed(ctx context.Context, companyID, chatID int64) error {
+	const op = "storage.postgres.AddSuppressed"
+
+	if _, err := s.db.ExecContext(ctx, addSuppressed, companyID, chatID); err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddDedupKey stores the deduplication key of the message sent to the chat of the company until the key expires
+// and subtracts the reported number of suppressed duplicates from the count of the chat.
+// Expired keys of all companies are deleted.
+func (s *OutboxStorage) AddDedupKey(ctx context.Context, companyID, chatID int64, dedupKey string, expiresAt time.Time, reported int) error {
+	const op = "storage.postgres.AddDedupKey"
+
+	if _, err := s.db.ExecContext(ctx, deleteExpiredDedupKeys); err != nil {
+		return fmt.Errorf("%s: delete expired keys: %w", op, err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, addDedupKey, companyID, chatID, dedupKey, expiresAt); err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	if reported > 0 {
+		if _, err := s.db.ExecContext(ctx, releaseSuppressed, companyID, chatID, reported); err != nil {
+			return fmt.Errorf("%s: release suppressed duplicates: %w", op, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,1156 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/pkg/database"
+)
+
+func TestOutboxStorage_AddMessage(t *testing.T) {
+	msg := entities.Message{
+		Text:      "text",
+		ParseMode: entities.HTML,
+		Token:     "token",
+		CompanyID: 12,
+		ChatIds:   []int64{123, 456},
+	}
+
+	t.Run("queued", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WithArgs(msg.CompanyID, `{"text":"text","parseMode":"HTML"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_deliveries (message_id, chat_id) VALUES ($1, $2) RETURNING id, message_id, chat_id, status, attempts, last_error")).
+			WithArgs(int64(7), int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(1, 7, 123, "queued", 0, ""))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_deliveries (message_id, chat_id) VALUES ($1, $2) RETURNING id, message_id, chat_id, status, attempts, last_error")).
+			WithArgs(int64(7), int64(456)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(2, 7, 456, "queued", 0, ""))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, time.Time{})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+		assert.Len(t, deliveries, 2)
+		assert.Equal(t, entities.DeliveryQueued, deliveries[1].Status)
+		assert.Equal(t, int64(456), deliveries[1].ChatID)
+		assert.Equal(t, []int64{456}, deliveries[1].Message.ChatIds)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("claimed", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		claimUntil := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		msg := msg
+		msg.ChatIds = []int64{123}
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WithArgs(msg.CompanyID, `{"text":"text","parseMode":"HTML"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectQuery(regexp.QuoteMeta(addClaimedDelivery)).
+			WithArgs(int64(7), int64(123), claimUntil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(1, 7, 123, "sending", 1, ""))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, claimUntil)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+		assert.Equal(t, []entities.Delivery{
+			{
+				ID:        1,
+				MessageID: 7,
+				ChatID:    123,
+				Status:    entities.DeliverySending,
+				Attempts:  1,
+				Message:   msg,
+			},
+		}, deliveries)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		sendAt := time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC)
+		msg := msg
+		msg.ChatIds = []int64{123}
+		msg.SendAt = sendAt
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WithArgs(msg.CompanyID, `{"text":"text","parseMode":"HTML"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectQuery(regexp.QuoteMeta(addScheduledDelivery)).
+			WithArgs(int64(7), int64(123), sendAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(1, 7, 123, "queued", 0, ""))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, time.Time{})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, entities.DeliveryQueued, deliveries[0].Status)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		claimUntil := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		msg := msg
+		msg.Digests = map[int64]time.Duration{123: 5 * time.Minute}
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WithArgs(msg.CompanyID, `{"text":"text","parseMode":"HTML","digests":{"123":300000000000}}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectQuery(regexp.QuoteMeta(addDigestDelivery)).
+			WithArgs(int64(7), int64(123), int64(12), int64(300000)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(1, 7, 123, "queued", 0, ""))
+		f.Mock.ExpectQuery(regexp.QuoteMeta(addClaimedDelivery)).
+			WithArgs(int64(7), int64(456), claimUntil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(2, 7, 456, "sending", 1, ""))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, claimUntil)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+		assert.Len(t, deliveries, 2)
+		assert.Equal(t, entities.DeliveryQueued, deliveries[0].Status)
+		assert.Equal(t, entities.DeliverySending, deliveries[1].Status)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("with error on add delivery", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_deliveries (message_id, chat_id) VALUES ($1, $2)")).
+			WithArgs(int64(7), int64(123)).
+			WillReturnError(expectErr)
+		f.Mock.ExpectRollback()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, time.Time{})
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, int64(0), id)
+		assert.Nil(t, deliveries)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("with idempotency key", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expiresAt := time.Date(2023, 9, 2, 12, 0, 0, 0, time.UTC)
+		msg := msg
+		msg.ChatIds = []int64{123}
+		msg.Idempotency = entities.Idempotency{Key: "key", ExpiresAt: expiresAt}
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM idempotency_keys WHERE expires_at<=now()")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		f.Mock.ExpectExec(regexp.QuoteMeta("INSERT INTO idempotency_keys (company_id, idempotency_key, message_id, expires_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING")).
+			WithArgs(msg.CompanyID, "key", int64(7), expiresAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_deliveries (message_id, chat_id) VALUES ($1, $2)")).
+			WithArgs(int64(7), int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error"}).
+				AddRow(1, 7, 123, "queued", 0, ""))
+		f.Mock.ExpectCommit()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, time.Time{})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+		assert.Len(t, deliveries, 1)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("with used idempotency key", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		msg := msg
+		msg.Idempotency = entities.Idempotency{Key: "key", ExpiresAt: time.Date(2023, 9, 2, 12, 0, 0, 0, time.UTC)}
+
+		f.Mock.ExpectBegin()
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM idempotency_keys WHERE expires_at<=now()")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox_messages (company_id, payload) VALUES ($1, $2) RETURNING id")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		f.Mock.ExpectExec(regexp.QuoteMeta("INSERT INTO idempotency_keys")).
+			WithArgs(msg.CompanyID, "key", int64(8), msg.Idempotency.ExpiresAt).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectRollback()
+
+		repo := New(f.DB)
+
+		// Act
+		id, deliveries, err := repo.AddMessage(context.Background(), msg, time.Time{})
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+		assert.Equal(t, int64(0), id)
+		assert.Nil(t, deliveries)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+}
+
+func TestOutboxStorage_ClaimDeliveries(t *testing.T) {
+	t.Run("with deliveries", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := []entities.Delivery{
+			{
+				ID:        1,
+				MessageID: 7,
+				ChatID:    123,
+				Status:    entities.DeliverySending,
+				Attempts:  1,
+				Message: entities.Message{
+					Text:      "text",
+					ParseMode: entities.HTML,
+					CompanyID: 12,
+					ChatIds:   []int64{123},
+				},
+			},
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error", "company_id", "payload"}).
+			AddRow(1, 7, 123, "sending", 1, "", 12, []byte(`{"text":"text","parseMode":"HTML"}`))
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(claimDeliveries)).
+			WithArgs(10, int64(60000)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, deliveries)
+	})
+
+	t.Run("with attachments", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := []entities.Attachment{
+			{Type: entities.AttachmentDocument, Name: "report.html", Data: []byte("<html></html>")},
+			{Type: entities.AttachmentPhoto, URL: "https://testit.software/step.png"},
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error", "company_id", "payload"}).
+			AddRow(1, 7, 123, "sending", 1, "", 12, []byte(`{"text":"text","parseMode":"HTML","attachments":[`+
+				`{"Type":"document","Name":"report.html","URL":"","Data":"PGh0bWw+PC9odG1sPg=="},`+
+				`{"Type":"photo","Name":"","URL":"https://testit.software/step.png","Data":null}]}`))
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(claimDeliveries)).
+			WithArgs(10, int64(60000)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, expected, deliveries[0].Message.Attachments)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(claimDeliveries)).
+			WithArgs(10, int64(60000)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Nil(t, deliveries)
+	})
+}
+
+func TestOutboxStorage_ClaimDigest(t *testing.T) {
+	t.Run("with deliveries", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		digests := map[int64]time.Duration{123: 5 * time.Minute}
+		rows := sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error", "company_id", "payload"}).
+			AddRow(1, 7, 123, "sending", 1, "", 12, []byte(`{"text":"first","parseMode":"HTML","digests":{"123":300000000000}}`)).
+			AddRow(2, 8, 123, "sending", 1, "", 12, []byte(`{"text":"second","parseMode":"HTML","digests":{"123":300000000000}}`))
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(claimDigest)).
+			WithArgs(int64(12), int64(123), int64(60000)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.ClaimDigest(context.Background(), 12, 123, time.Minute)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+		assert.Equal(t, "second", deliveries[1].Message.Text)
+		assert.Equal(t, digests, deliveries[1].Message.Digests)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(claimDigest)).
+			WithArgs(int64(12), int64(123), int64(60000)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.ClaimDigest(context.Background(), 12, 123, time.Minute)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Nil(t, deliveries)
+	})
+}
+
+func TestOutboxStorage_MarkDelivered(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(markDelivered)).
+			WithArgs(int64(1), 2, int64(7), 55, "{55,56}", true).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox_messages AS m SET payload=jsonb_set(m.payload, '{attachments}',
+		(SELECT jsonb_agg(a - 'Data') FROM jsonb_array_elements(m.payload->'attachments') AS a))
+	WHERE m.id=(SELECT message_id FROM outbox_deliveries WHERE id=$1)
+	AND EXISTS (SELECT 1 FROM jsonb_array_elements(m.payload->'attachments') AS a WHERE a->>'Data' IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM outbox_deliveries AS d WHERE d.message_id=m.id AND d.status IN ('queued', 'sending'))`)).
+			WithArgs(int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkDelivered(context.Background(), 1, 2, 7, []int{55, 56}, true)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("lease expired", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox_deliveries SET status='delivered', digest_id=$3, telegram_message_id=$4, telegram_message_ids=$5, plain_text=$6, last_error='', updated_at=now()
+	WHERE id=$1 AND status='sending' AND attempts=$2`)).
+			WithArgs(int64(1), 2, int64(7), 55, "{55,56}", true).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkDelivered(context.Background(), 1, 2, 7, []int{55, 56}, true)
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrLeaseExpired)
+	})
+}
+
+func TestOutboxStorage_Postpone(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		at := time.Date(2023, 9, 2, 8, 0, 0, 0, time.UTC)
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox_deliveries SET status='queued', attempts=GREATEST(attempts-1, 0), next_attempt_at=$3, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2")).
+			WithArgs(int64(1), 1, at).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.Postpone(context.Background(), 1, 1, at)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestOutboxStorage_ScheduleRetry(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		at := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox_deliveries SET status='queued', next_attempt_at=$3, last_error=$4, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2")).
+			WithArgs(int64(1), 2, at, "error").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.ScheduleRetry(context.Background(), 1, 2, at, "error")
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestOutboxStorage_MarkFailed(t *testing.T) {
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox_deliveries SET status='failed', last_error=$3, updated_at=now() WHERE id=$1 AND status='sending' AND attempts=$2")).
+			WithArgs(int64(1), 3, "error").
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkFailed(context.Background(), 1, 3, "error")
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestOutboxStorage_GetDeliveries(t *testing.T) {
+	t.Run("with deliveries", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := []entities.Delivery{
+			{
+				ID:                 1,
+				MessageID:          7,
+				ChatID:             123,
+				Status:             entities.DeliveryDelivered,
+				Attempts:           1,
+				TelegramMessageID:  55,
+				PlainText:          true,
+				DigestID:           1,
+				TelegramMessageIDs: []int{55, 56},
+			},
+			{
+				ID:        2,
+				MessageID: 7,
+				ChatID:    456,
+				Status:    entities.DeliveryQueued,
+				Attempts:  2,
+				LastError: "Too Many Requests: retry after 5",
+			},
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error", "telegram_message_id", "plain_text", "telegram_message_ids", "digest_id"}).
+			AddRow(1, 7, 123, "delivered", 1, "", 55, true, "{55,56}", 1).
+			AddRow(2, 7, 456, "queued", 2, "Too Many Requests: retry after 5", 0, false, "{}", 0)
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDeliveries)).
+			WithArgs(int64(7), "token").
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.GetDeliveries(context.Background(), 7, "token")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, deliveries)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDeliveries)).
+			WithArgs(int64(7), "token").
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.GetDeliveries(context.Background(), 7, "token")
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, []entities.Delivery{}, deliveries)
+	})
+}
+
+func TestOutboxStorage_GetMessageIdByIdempotencyKey(t *testing.T) {
+	t.Run("with key", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT message_id FROM idempotency_keys WHERE company_id=$1 AND idempotency_key=$2 AND expires_at>now()")).
+			WithArgs(int64(12), "key").
+			WillReturnRows(sqlmock.NewRows([]string{"message_id"}).AddRow(7))
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetMessageIdByIdempotencyKey(context.Background(), 12, "key")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getMessageIdByKey)).
+			WithArgs(int64(12), "key").
+			WillReturnError(sql.ErrNoRows)
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetMessageIdByIdempotencyKey(context.Background(), 12, "key")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		assert.Equal(t, int64(0), id)
+	})
+}
+
+func TestOutboxStorage_GetCorrelatedMessageId(t *testing.T) {
+	t.Run("with message", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT telegram_message_id FROM correlations WHERE company_id=$1 AND correlation_id=$2 AND chat_id=$3")).
+			WithArgs(int64(12), "run-1", int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"telegram_message_id"}).AddRow(55))
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetCorrelatedMessageId(context.Background(), 12, "run-1", 123)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 55, id)
+	})
+
+	t.Run("without message", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getCorrelated)).
+			WithArgs(int64(12), "run-1", int64(123)).
+			WillReturnError(sql.ErrNoRows)
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetCorrelatedMessageId(context.Background(), 12, "run-1", 123)
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		assert.Equal(t, 0, id)
+	})
+}
+
+func TestOutboxStorage_SetCorrelatedMessageId(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setCorrelated)).
+			WithArgs(int64(12), "run-1", int64(123), 55).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetCorrelatedMessageId(context.Background(), 12, "run-1", 123, 55)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(setCorrelated)).
+			WithArgs(int64(12), "run-1", int64(123), 55).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.SetCorrelatedMessageId(context.Background(), 12, "run-1", 123, 55)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestOutboxStorage_MarkDeleted(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox_deliveries SET status='deleted', updated_at=now() WHERE id=$1 AND status IN ('queued', 'delivered')")).
+			WithArgs(int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(clearAttachments)).
+			WithArgs(int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkDeleted(context.Background(), 1)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("delivery in another state", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(markDeleted)).
+			WithArgs(int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkDeleted(context.Background(), 1)
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestOutboxStorage_CountDigestDelivered(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"count"}).AddRow(2)
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM outbox_deliveries WHERE digest_id=$1 AND id<>$2 AND status='delivered'")).
+			WithArgs(int64(5), int64(6)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		count, err := repo.CountDigestDelivered(context.Background(), 5, 6)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(countDigestDelivered)).
+			WithArgs(int64(5), int64(6)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		count, err := repo.CountDigestDelivered(context.Background(), 5, 6)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestOutboxStorage_GetDeliveriesByFilter(t *testing.T) {
+	t.Run("with deliveries", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		from := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		filter := entities.MessageFilter{CorrelationID: "run-1", From: from}
+
+		expected := []entities.Delivery{
+			{
+				ID:                 1,
+				MessageID:          7,
+				ChatID:             123,
+				Status:             entities.DeliveryDelivered,
+				Attempts:           1,
+				TelegramMessageID:  55,
+				TelegramMessageIDs: []int{55},
+			},
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "message_id", "chat_id", "status", "attempts", "last_error", "telegram_message_id", "plain_text", "telegram_message_ids", "digest_id"}).
+			AddRow(1, 7, 123, "delivered", 1, "", 55, false, "{55}", 0)
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDeliveriesByFilter)).
+			WithArgs("token", "run-1", from, nil, 100).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.GetDeliveriesByFilter(context.Background(), "token", filter, 100)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, deliveries)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDeliveriesByFilter)).
+			WithArgs("token", "run-1", nil, nil, 100).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		deliveries, err := repo.GetDeliveriesByFilter(context.Background(), "token", entities.MessageFilter{CorrelationID: "run-1"}, 100)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, []entities.Delivery{}, deliveries)
+	})
+}
+
+func TestOutboxStorage_GetThreadMessageId(t *testing.T) {
+	t.Run("with message", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT telegram_message_id FROM thread_keys WHERE company_id=$1 AND thread_key=$2 AND chat_id=$3 AND expires_at>now()")).
+			WithArgs(int64(12), "run-1", int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"telegram_message_id"}).AddRow(55))
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetThreadMessageId(context.Background(), 12, "run-1", 123)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 55, id)
+	})
+
+	t.Run("without message", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getThreadMessageId)).
+			WithArgs(int64(12), "run-1", int64(123)).
+			WillReturnError(sql.ErrNoRows)
+
+		repo := New(f.DB)
+
+		// Act
+		id, err := repo.GetThreadMessageId(context.Background(), 12, "run-1", 123)
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		assert.Equal(t, 0, id)
+	})
+}
+
+func TestOutboxStorage_AddThreadMessageId(t *testing.T) {
+	expiresAt := time.Date(2023, 9, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM thread_keys WHERE expires_at<=now()")).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		f.Mock.ExpectExec(regexp.QuoteMeta(addThreadMessageId)).
+			WithArgs(int64(12), "run-1", int64(123), 55, expiresAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.AddThreadMessageId(context.Background(), 12, "run-1", 123, 55, expiresAt)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteExpiredThreadKeys)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(addThreadMessageId)).
+			WithArgs(int64(12), "run-1", int64(123), 55, expiresAt).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.AddThreadMessageId(context.Background(), 12, "run-1", 123, 55, expiresAt)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestOutboxStorage_GetDedupState(t *testing.T) {
+	t.Run("with company", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDedupState)).
+			WithArgs(int64(12), int64(123), "login-failed").
+			WillReturnRows(sqlmock.NewRows([]string{"dedup_window", "duplicate", "suppressed"}).AddRow(600, true, 3))
+
+		repo := New(f.DB)
+
+		// Act
+		state, err := repo.GetDedupState(context.Background(), 12, 123, "login-failed")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.DedupState{Window: 600, Duplicate: true, Suppressed: 3}, state)
+	})
+
+	t.Run("without company", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getDedupState)).
+			WithArgs(int64(12), int64(123), "login-failed").
+			WillReturnError(sql.ErrNoRows)
+
+		repo := New(f.DB)
+
+		// Act
+		_, err := repo.GetDedupState(context.Background(), 12, 123, "login-failed")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestOutboxStorage_MarkSuppressed(t *testing.T) {
+	t.Run("with error clearing attachments", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox_deliveries SET status='suppressed', updated_at=now() WHERE id=$1")).
+			WithArgs(int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(clearAttachments)).
+			WithArgs(int64(1)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.MarkSuppressed(context.Background(), 1)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestOutboxStorage_DeleteFinishedMessages(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM outbox_messages WHERE id IN (
+		SELECT m.id FROM outbox_messages AS m WHERE m.created_at<now()-$1*interval '1 millisecond'
+		AND NOT EXISTS (SELECT 1 FROM outbox_deliveries AS d WHERE d.message_id=m.id AND d.status IN ('queued', 'sending'))
+		ORDER BY m.id LIMIT $2
+	)`)).
+			WithArgs(int64(3600000), 100).
+			WillReturnResult(sqlmock.NewResult(0, 7))
+
+		repo := New(f.DB)
+
+		// Act
+		deleted, err := repo.DeleteFinishedMessages(context.Background(), time.Hour, 100)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), deleted)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteFinishedMessages)).
+			WithArgs(int64(3600000), 100).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		_, err := repo.DeleteFinishedMessages(context.Background(), time.Hour, 100)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
+
+func TestOutboxStorage_AddSuppressed(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(addSuppressed)).
+			WithArgs(int64(12), int64(123)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.AddSuppressed(context.Background(), 12, 123)
+
+		// Assert
+		assert.NoError(t, err)
+	})
+}
+
+func TestOutboxStorage_AddDedupKey(t *testing.T) {
+	expiresAt := time.Date(2023, 9, 1, 12, 10, 0, 0, time.UTC)
+
+	t.Run("with reported duplicates", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM dedup_keys WHERE expires_at<=now()")).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		f.Mock.ExpectExec(regexp.QuoteMeta(addDedupKey)).
+			WithArgs(int64(12), int64(123), "login-failed", expiresAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		f.Mock.ExpectExec(regexp.QuoteMeta(releaseSuppressed)).
+			WithArgs(int64(12), int64(123), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.AddDedupKey(context.Background(), 12, 123, "login-failed", expiresAt, 3)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, f.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteExpiredDedupKeys)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		f.Mock.ExpectExec(regexp.QuoteMeta(addDedupKey)).
+			WithArgs(int64(12), int64(123), "login-failed", expiresAt).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.AddDedupKey(context.Background(), 12, 123, "login-failed", expiresAt, 0)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
@@ -0,0 +1,120 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+// RouteStorage is a storage implementation for routing rules using PostgreSQL.
+type RouteStorage struct {
+	db *sqlx.DB
+}
+
+// New returns a new instance of RouteStorage with the given database connection.
+func New(db *sqlx.DB) *RouteStorage {
+	return &RouteStorage{
+		db: db,
+	}
+}
+
+const (
+	setRoute = `INSERT INTO routes (company_id, name, conditions, chat_ids) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (company_id, name) DO UPDATE SET conditions=EXCLUDED.conditions, chat_ids=EXCLUDED.chat_ids
+	RETURNING id, company_id, name, conditions, chat_ids`
+	getRoutesByCompanyId = "SELECT id, company_id, name, conditions, chat_ids FROM routes WHERE company_id=$1 ORDER BY name"
+	deleteRoute          = "DELETE FROM routes WHERE company_id=$1 AND name=$2"
+)
+
+type routeRow struct {
+	ID         int64         `db:"id"`
+	CompanyID  int64         `db:"company_id"`
+	Name       string        `db:"name"`
+	Conditions []byte        `db:"conditions"`
+	ChatIds    pq.Int64Array `db:"chat_ids"`
+}
+
+func (r routeRow) convert() (entities.Route, error) {
+	route := entities.Route{
+		ID:        r.ID,
+		CompanyID: r.CompanyID,
+		Name:      r.Name,
+		ChatIds:   r.ChatIds,
+	}
+
+	if err := json.Unmarshal(r.Conditions, &route.Conditions); err != nil {
+		return entities.Route{}, fmt.Errorf("unmarshal conditions of route %d: %w", r.ID, err)
+	}
+
+	return route, nil
+}
+
+// SetRoute adds the routing rule to the company or replaces the rule with the same name.
+func (s *RouteStorage) SetRoute(ctx context.Context, r entities.Route) (entities.Route, error) {
+	const op = "storage.postgres.SetRoute"
+
+	conditions, err := json.Marshal(r.Conditions)
+	if err != nil {
+		return entities.Route{}, fmt.Errorf("%s: marshal conditions: %w", op, err)
+	}
+
+	var row routeRow
+	if err := s.db.QueryRowxContext(ctx, setRoute, r.CompanyID, r.Name, conditions, pq.Int64Array(r.ChatIds)).StructScan(&row); err != nil {
+		return entities.Route{}, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	route, err := row.convert()
+	if err != nil {
+		return entities.Route{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return route, nil
+}
+
+// GetRoutesByCompanyId returns the routing rules of the company with the given ID ordered by name.
+func (s *RouteStorage) GetRoutesByCompanyId(ctx context.Context, companyID int64) ([]entities.Route, error) {
+	const op = "storage.postgres.GetRoutesByCompanyId"
+
+	rows := []routeRow{}
+	if err := s.db.SelectContext(ctx, &rows, getRoutesByCompanyId, companyID); err != nil {
+		return nil, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	routes := make([]entities.Route, 0, len(rows))
+	for _, row := range rows {
+		route, err := row.convert()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// DeleteRoute deletes the routing rule with the given name from the company with the given ID.
+// If the rule is not found, ErrNotFound is returned.
+func (s *RouteStorage) DeleteRoute(ctx context.Context, companyID int64, name string) error {
+	const op = "storage.postgres.DeleteRoute"
+
+	res, err := s.db.ExecContext(ctx, deleteRoute, companyID, name)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get affected rows: %w", op, err)
+	}
+
+	if deleted == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/pkg/database"
+)
+
+func TestRouteStorage_SetRoute(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := entities.Route{
+			ID:        1,
+			CompanyID: 12,
+			Name:      "backend",
+			Conditions: []entities.RouteCondition{
+				{Field: "project", Value: "Backend"},
+				{Field: "data.testRun.name", Value: "nightly*"},
+			},
+			ChatIds: []int64{-100123, -100456},
+		}
+		conditions := `[{"field":"project","value":"Backend"},{"field":"data.testRun.name","value":"nightly*"}]`
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "conditions", "chat_ids"}).
+			AddRow(1, 12, "backend", conditions, "{-100123,-100456}")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO routes (company_id, name, conditions, chat_ids) VALUES ($1, $2, $3, $4)")).
+			WithArgs(expected.CompanyID, expected.Name, []byte(conditions), sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		route, err := repo.SetRoute(context.Background(), expected)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, route)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(setRoute)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		route, err := repo.SetRoute(context.Background(), entities.Route{CompanyID: 12, Name: "backend"})
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, entities.Route{}, route)
+	})
+}
+
+func TestRouteStorage_GetRoutesByCompanyId(t *testing.T) {
+	t.Run("with routes", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := []entities.Route{
+			{
+				ID:         1,
+				CompanyID:  12,
+				Name:       "backend",
+				Conditions: []entities.RouteCondition{{Field: "project", Value: "Backend"}},
+				ChatIds:    []int64{-100123},
+			},
+			{
+				ID:         2,
+				CompanyID:  12,
+				Name:       "critical",
+				Conditions: []entities.RouteCondition{{Field: "severity", Value: "critical"}},
+				ChatIds:    []int64{-100456, -100789},
+			},
+		}
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "conditions", "chat_ids"}).
+			AddRow(1, 12, "backend", `[{"field":"project","value":"Backend"}]`, "{-100123}").
+			AddRow(2, 12, "critical", `[{"field":"severity","value":"critical"}]`, "{-100456,-100789}")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, name, conditions, chat_ids FROM routes WHERE company_id=$1 ORDER BY name")).
+			WithArgs(12).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		routes, err := repo.GetRoutesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, routes)
+	})
+
+	t.Run("without routes", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "conditions", "chat_ids"})
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getRoutesByCompanyId)).
+			WithArgs(12).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		routes, err := repo.GetRoutesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, routes)
+	})
+
+	t.Run("with invalid conditions", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "conditions", "chat_ids"}).
+			AddRow(1, 12, "backend", `{`, "{-100123}")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getRoutesByCompanyId)).
+			WithArgs(12).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		routes, err := repo.GetRoutesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, routes)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getRoutesByCompanyId)).
+			WithArgs(12).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		routes, err := repo.GetRoutesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Nil(t, routes)
+	})
+}
+
+func TestRouteStorage_DeleteRoute(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM routes WHERE company_id=$1 AND name=$2")).
+			WithArgs(12, "backend").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.DeleteRoute(context.Background(), 12, "backend")
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteRoute)).
+			WithArgs(12, "backend").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.DeleteRoute(context.Background(), 12, "backend")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteRoute)).
+			WithArgs(12, "backend").
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.DeleteRoute(context.Background(), 12, "backend")
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+	})
+}
@@ -0,0 +1,100 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+// TemplateStorage is a storage implementation for message templates using PostgreSQL.
+type TemplateStorage struct {
+	db *sqlx.DB
+}
+
+// New returns a new instance of TemplateStorage with the given database connection.
+func New(db *sqlx.DB) *TemplateStorage {
+	return &TemplateStorage{
+		db: db,
+	}
+}
+
+const (
+	setTemplate = `INSERT INTO templates (company_id, name, parse_mode, body) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (company_id, name) DO UPDATE SET parse_mode=EXCLUDED.parse_mode, body=EXCLUDED.body
+	RETURNING id, company_id, name, parse_mode, body`
+	getTemplate             = "SELECT id, company_id, name, parse_mode, body FROM templates WHERE company_id=$1 AND name=$2"
+	getTemplatesByCompanyId = "SELECT id, company_id, name, parse_mode, body FROM templates WHERE company_id=$1 ORDER BY name"
+	deleteTemplate          = "DELETE FROM templates WHERE company_id=$1 AND name=$2"
+)
+
+// SetTemplate adds the template to the company or replaces the template with the same name.
+func (s *TemplateStorage) SetTemplate(ctx context.Context, t entities.Template) (entities.Template, error) {
+	const op = "storage.postgres.SetTemplate"
+
+	var newTemplate entities.Template
+
+	row := s.db.QueryRowxContext(ctx, setTemplate, t.CompanyID, t.Name, t.ParseMode, t.Body)
+	if err := row.StructScan(&newTemplate); err != nil {
+		return newTemplate, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return newTemplate, nil
+}
+
+// GetTemplate returns the template with the given name that belongs to the company with the given ID.
+// If the template is not found, ErrNotFound is returned.
+func (s *TemplateStorage) GetTemplate(ctx context.Context, companyID int64, name string) (entities.Template, error) {
+	const op = "storage.postgres.GetTemplate"
+
+	var t entities.Template
+
+	if err := s.db.GetContext(ctx, &t, getTemplate, companyID, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, storage.ErrNotFound
+		}
+
+		return t, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return t, nil
+}
+
+// GetTemplatesByCompanyId returns the templates of the company with the given ID ordered by name.
+func (s *TemplateStorage) GetTemplatesByCompanyId(ctx context.Context, companyID int64) ([]entities.Template, error) {
+	const op = "storage.postgres.GetTemplatesByCompanyId"
+
+	templates := []entities.Template{}
+
+	if err := s.db.SelectContext(ctx, &templates, getTemplatesByCompanyId, companyID); err != nil {
+		return templates, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	return templates, nil
+}
+
+// DeleteTemplate deletes the template with the given name from the company with the given ID.
+// If the template is not found, ErrNotFound is returned.
+func (s *TemplateStorage) DeleteTemplate(ctx context.Context, companyID int64, name string) error {
+	const op = "storage.postgres.DeleteTemplate"
+
+	res, err := s.db.ExecContext(ctx, deleteTemplate, companyID, name)
+	if err != nil {
+		return fmt.Errorf("%s: execute query: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get affected rows: %w", op, err)
+	}
+
+	if deleted == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/pkg/database"
+)
+
+func TestTemplateStorage_SetTemplate(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expected := entities.Template{
+			ID:        1,
+			CompanyID: 12,
+			Name:      "run-finished",
+			ParseMode: entities.HTML,
+			Body:      "<b>{{.project}}</b>",
+		}
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "parse_mode", "body"}).
+			AddRow(1, 12, "run-finished", "HTML", "<b>{{.project}}</b>")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO templates (company_id, name, parse_mode, body) VALUES ($1, $2, $3, $4)")).
+			WithArgs(expected.CompanyID, expected.Name, expected.ParseMode, expected.Body).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		tmpl, err := repo.SetTemplate(context.Background(), expected)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, tmpl)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(setTemplate)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		tmpl, err := repo.SetTemplate(context.Background(), entities.Template{CompanyID: 12, Name: "run-finished"})
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, entities.Template{}, tmpl)
+	})
+}
+
+func TestTemplateStorage_GetTemplate(t *testing.T) {
+	t.Run("with template", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "parse_mode", "body"}).
+			AddRow(1, 12, "run-finished", "MarkdownV2", "*{{.project}}*")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, name, parse_mode, body FROM templates WHERE company_id=$1 AND name=$2")).
+			WithArgs(int64(12), "run-finished").
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		tmpl, err := repo.GetTemplate(context.Background(), 12, "run-finished")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Template{
+			ID:        1,
+			CompanyID: 12,
+			Name:      "run-finished",
+			ParseMode: entities.MarkdownV2,
+			Body:      "*{{.project}}*",
+		}, tmpl)
+	})
+
+	t.Run("without template", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getTemplate)).
+			WithArgs(int64(12), "run-finished").
+			WillReturnError(sql.ErrNoRows)
+
+		repo := New(f.DB)
+
+		// Act
+		_, err := repo.GetTemplate(context.Background(), 12, "run-finished")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestTemplateStorage_GetTemplatesByCompanyId(t *testing.T) {
+	t.Run("with templates", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		rows := sqlmock.NewRows([]string{"id", "company_id", "name", "parse_mode", "body"}).
+			AddRow(1, 12, "run-finished", "HTML", "body").
+			AddRow(2, 12, "run-started", "HTML", "body")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta("SELECT id, company_id, name, parse_mode, body FROM templates WHERE company_id=$1 ORDER BY name")).
+			WithArgs(int64(12)).
+			WillReturnRows(rows)
+
+		repo := New(f.DB)
+
+		// Act
+		templates, err := repo.GetTemplatesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, templates, 2)
+		assert.Equal(t, "run-started", templates[1].Name)
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		expectErr := errors.New("test error")
+
+		f.Mock.ExpectQuery(regexp.QuoteMeta(getTemplatesByCompanyId)).
+			WithArgs(int64(12)).
+			WillReturnError(expectErr)
+
+		repo := New(f.DB)
+
+		// Act
+		templates, err := repo.GetTemplatesByCompanyId(context.Background(), 12)
+
+		// Assert
+		assert.ErrorIs(t, err, expectErr)
+		assert.Equal(t, []entities.Template{}, templates)
+	})
+}
+
+func TestTemplateStorage_DeleteTemplate(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta("DELETE FROM templates WHERE company_id=$1 AND name=$2")).
+			WithArgs(int64(12), "run-finished").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.DeleteTemplate(context.Background(), 12, "run-finished")
+
+		// Assert
+		assert.NoError(t, err)
+	})
+
+	t.Run("without template", func(t *testing.T) {
+		// Arrange
+		t.Parallel()
+		f := database.NewFixture(t)
+		defer f.Teardown()
+
+		f.Mock.ExpectExec(regexp.QuoteMeta(deleteTemplate)).
+			WithArgs(int64(12), "run-finished").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repo := New(f.DB)
+
+		// Act
+		err := repo.DeleteTemplate(context.Background(), 12, "run-finished")
+
+		// Assert
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
@@ -5,4 +5,8 @@ import "errors"
 var (
 	// ErrNotFound is returned when an entity is not found.
 	ErrNotFound = errors.New("entity not found")
+	// ErrAlreadyExists is returned when an entity with the same unique key already exists.
+	ErrAlreadyExists = errors.New("entity already exists")
+	// ErrLeaseExpired is returned when a claimed entity was claimed again by someone else after the lease expired.
+	ErrLeaseExpired = errors.New("lease expired")
 )
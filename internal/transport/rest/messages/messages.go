@@ -0,0 +1,189 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
+	"golang.org/x/exp/slog"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type deliveryGeter interface {
+	GetDeliveries(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type messageDeleter interface {
+	DeleteMessage(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error)
+	DeleteMessages(ctx context.Context, token string, filter entities.MessageFilter) ([]entities.Delivery, error)
+}
+
+// NewGet returns a new http.HandlerFunc that responds with the delivery state of the message in every chat.
+// The message ID is taken from the "id" URL parameter, only messages of the company with the Authorization token are available.
+func NewGet(log *slog.Logger, dg deliveryGeter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "transport.rest.messages.NewGet"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			log.Debug("token not found")
+			handlers.NewErrorResponse(w, http.StatusUnauthorized, "token is required")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			log.Debug("invalid message id", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusBadRequest, "invalid message id")
+			return
+		}
+
+		deliveries, err := dg.GetDeliveries(r.Context(), token, id)
+		if err != nil {
+			if errors.Is(err, usecases.ErrMessageNotFound) {
+				log.Debug("message not found", slog.Int64("message_id", id))
+				handlers.NewErrorResponse(w, http.StatusNotFound, "message not found")
+				return
+			}
+
+			log.Error("can not get deliveries", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusInternalServerError, "can't get message")
+			return
+		}
+
+		handlers.NewJSONResponse(w, http.StatusOK, newResponse(id, deliveries))
+	}
+}
+
+// NewDelete returns a new http.HandlerFunc that retracts the message: its Telegram messages are deleted from every chat
+// and the deliveries that are not sent yet are cancelled. It responds with the new delivery state of the message in every chat.
+// The message ID is taken from the "id" URL parameter, only messages of the company with the Authorization token are available.
+func NewDelete(log *slog.Logger, md messageDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "transport.rest.messages.NewDelete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			log.Debug("token not found")
+			handlers.NewErrorResponse(w, http.StatusUnauthorized, "token is required")
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			log.Debug("invalid message id", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusBadRequest, "invalid message id")
+			return
+		}
+
+		deliveries, err := md.DeleteMessage(r.Context(), token, id)
+		if err != nil {
+			if errors.Is(err, usecases.ErrMessageNotFound) {
+				log.Debug("message not found", slog.Int64("message_id", id))
+				handlers.NewErrorResponse(w, http.StatusNotFound, "message not found")
+				return
+			}
+
+			log.Error("can not delete message", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusInternalServerError, "can't delete message")
+			return
+		}
+
+		handlers.NewJSONResponse(w, http.StatusOK, newResponse(id, deliveries))
+	}
+}
+
+// NewDeleteBulk returns a new http.HandlerFunc that retracts the messages of the company with the Authorization token
+// like the handler returned by NewDelete. The messages are selected by the "correlationId" query parameter
+// and by the time range of the "from" and "to" query parameters in RFC 3339, at least one of them is required.
+// It deletes at most usecases.MaxDeletedMessages messages at once and responds with their new delivery state.
+func NewDeleteBulk(log *slog.Logger, md messageDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "transport.rest.messages.NewDeleteBulk"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		token := r.Header.Get("Authorization")
+		if token == "" {
+			log.Debug("token not found")
+			handlers.NewErrorResponse(w, http.StatusUnauthorized, "token is required")
+			return
+		}
+
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("invalid filter", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		deliveries, err := md.DeleteMessages(r.Context(), token, filter)
+		if err != nil {
+			log.Error("can not delete messages", sl.Err(err))
+			handlers.NewErrorResponse(w, http.StatusInternalServerError, "can't delete messages")
+			return
+		}
+
+		handlers.NewJSONResponse(w, http.StatusOK, newBulkResponse(deliveries))
+	}
+}
+
+// parseFilter returns the filter of the messages from the query parameters.
+func parseFilter(query url.Values) (entities.MessageFilter, error) {
+	filter := entities.MessageFilter{
+		CorrelationID: query.Get("correlationId"),
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{name: "from", t: &filter.From},
+		{name: "to", t: &filter.To},
+	} {
+		value := query.Get(p.name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return entities.MessageFilter{}, fmt.Errorf("%s must be a time in RFC 3339 format", p.name)
+		}
+		*p.t = t
+	}
+
+	if filter.CorrelationID == "" && filter.From.IsZero() && filter.To.IsZero() {
+		return entities.MessageFilter{}, errors.New("correlationId, from or to is required")
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return entities.MessageFilter{}, errors.New("from must be before to")
+	}
+
+	return filter, nil
+}
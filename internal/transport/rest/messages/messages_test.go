@@ -0,0 +1,355 @@
+package messages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/slogdiscard"
+	"github.com/testit-tms/webhook-bot/internal/transport/rest/messages/mocks"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNewGet(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		id             string
+		mockTimes      int
+		mockDeliveries []entities.Delivery
+		mockError      error
+		respCode       int
+		respError      string
+		wantResp       Response
+	}{
+		{
+			name:      "success",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockDeliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, Attempts: 1, TelegramMessageID: 55},
+				{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryQueued, Attempts: 2, LastError: "rate limited"},
+			},
+			respCode: http.StatusOK,
+			wantResp: Response{
+				ID: 7,
+				Chats: []ChatResponse{
+					{ChatID: 123, Status: "delivered", Attempts: 1, MessageID: 55},
+					{ChatID: 456, Status: "queued", Attempts: 2, Error: "rate limited"},
+				},
+			},
+		},
+		{
+			name:      "unauthorized",
+			id:        "7",
+			respCode:  http.StatusUnauthorized,
+			respError: "token is required",
+		},
+		{
+			name:      "invalid id",
+			token:     "token",
+			id:        "abc",
+			respCode:  http.StatusBadRequest,
+			respError: "invalid message id",
+		},
+		{
+			name:      "not found",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockError: fmt.Errorf("usecases.GetDeliveries: %w", usecases.ErrMessageNotFound),
+			respCode:  http.StatusNotFound,
+			respError: "message not found",
+		},
+		{
+			name:      "error",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockError: errors.New("some error"),
+			respCode:  http.StatusInternalServerError,
+			respError: "can't get message",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			getterMock := mocks.NewMockdeliveryGeter(mockCtrl)
+			getterMock.EXPECT().GetDeliveries(gomock.Any(), tc.token, int64(7)).Return(tc.mockDeliveries, tc.mockError).Times(tc.mockTimes)
+
+			handler := NewGet(slogdiscard.NewDiscardLogger(), getterMock)
+
+			req, err := http.NewRequest(http.MethodGet, "/telegram/messages/"+tc.id, nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				var resp Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, tc.wantResp, resp)
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNewDelete(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		id             string
+		mockTimes      int
+		mockDeliveries []entities.Delivery
+		mockError      error
+		respCode       int
+		respError      string
+		wantResp       Response
+	}{
+		{
+			name:      "success",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockDeliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, Attempts: 1, TelegramMessageID: 55},
+				{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryDelivered, Attempts: 1, TelegramMessageID: 60, LastError: "message can't be deleted"},
+			},
+			respCode: http.StatusOK,
+			wantResp: Response{
+				ID: 7,
+				Chats: []ChatResponse{
+					{ChatID: 123, Status: "deleted", Attempts: 1, MessageID: 55},
+					{ChatID: 456, Status: "delivered", Attempts: 1, MessageID: 60, Error: "message can't be deleted"},
+				},
+			},
+		},
+		{
+			name:      "unauthorized",
+			id:        "7",
+			respCode:  http.StatusUnauthorized,
+			respError: "token is required",
+		},
+		{
+			name:      "invalid id",
+			token:     "token",
+			id:        "abc",
+			respCode:  http.StatusBadRequest,
+			respError: "invalid message id",
+		},
+		{
+			name:      "not found",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockError: fmt.Errorf("usecases.DeleteMessage: %w", usecases.ErrMessageNotFound),
+			respCode:  http.StatusNotFound,
+			respError: "message not found",
+		},
+		{
+			name:      "error",
+			token:     "token",
+			id:        "7",
+			mockTimes: 1,
+			mockError: errors.New("some error"),
+			respCode:  http.StatusInternalServerError,
+			respError: "can't delete message",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			deleterMock := mocks.NewMockmessageDeleter(mockCtrl)
+			deleterMock.EXPECT().DeleteMessage(gomock.Any(), tc.token, int64(7)).Return(tc.mockDeliveries, tc.mockError).Times(tc.mockTimes)
+
+			handler := NewDelete(slogdiscard.NewDiscardLogger(), deleterMock)
+
+			req, err := http.NewRequest(http.MethodDelete, "/telegram/messages/"+tc.id, nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				var resp Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, tc.wantResp, resp)
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNewDeleteBulk(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		query          string
+		mockTimes      int
+		mockFilter     entities.MessageFilter
+		mockDeliveries []entities.Delivery
+		mockError      error
+		respCode       int
+		respError      string
+		wantResp       BulkResponse
+	}{
+		{
+			name:       "by correlation ID",
+			token:      "token",
+			query:      "?correlationId=run-1",
+			mockTimes:  1,
+			mockFilter: entities.MessageFilter{CorrelationID: "run-1"},
+			mockDeliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, Attempts: 1, TelegramMessageID: 55},
+				{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryDeleted, Attempts: 1},
+				{ID: 3, MessageID: 8, ChatID: 123, Status: entities.DeliveryDeleted, Attempts: 1, TelegramMessageID: 60},
+			},
+			respCode: http.StatusOK,
+			wantResp: BulkResponse{
+				Messages: []Response{
+					{ID: 7, Chats: []ChatResponse{
+						{ChatID: 123, Status: "deleted", Attempts: 1, MessageID: 55},
+						{ChatID: 456, Status: "deleted", Attempts: 1},
+					}},
+					{ID: 8, Chats: []ChatResponse{{ChatID: 123, Status: "deleted", Attempts: 1, MessageID: 60}}},
+				},
+			},
+		},
+		{
+			name:      "by time range",
+			token:     "token",
+			query:     "?from=2023-09-01T12:00:00Z&to=2023-09-01T13:00:00Z",
+			mockTimes: 1,
+			mockFilter: entities.MessageFilter{
+				From: time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC),
+				To:   time.Date(2023, 9, 1, 13, 0, 0, 0, time.UTC),
+			},
+			mockDeliveries: []entities.Delivery{},
+			respCode:       http.StatusOK,
+			wantResp:       BulkResponse{Messages: []Response{}},
+		},
+		{
+			name:      "unauthorized",
+			query:     "?correlationId=run-1",
+			respCode:  http.StatusUnauthorized,
+			respError: "token is required",
+		},
+		{
+			name:      "without filter",
+			token:     "token",
+			respCode:  http.StatusBadRequest,
+			respError: "correlationId, from or to is required",
+		},
+		{
+			name:      "invalid time",
+			token:     "token",
+			query:     "?from=yesterday",
+			respCode:  http.StatusBadRequest,
+			respError: "from must be a time in RFC 3339 format",
+		},
+		{
+			name:      "invalid time range",
+			token:     "token",
+			query:     "?from=2023-09-01T13:00:00Z&to=2023-09-01T12:00:00Z",
+			respCode:  http.StatusBadRequest,
+			respError: "from must be before to",
+		},
+		{
+			name:       "error",
+			token:      "token",
+			query:      "?correlationId=run-1",
+			mockTimes:  1,
+			mockFilter: entities.MessageFilter{CorrelationID: "run-1"},
+			mockError:  errors.New("some error"),
+			respCode:   http.StatusInternalServerError,
+			respError:  "can't delete messages",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			deleterMock := mocks.NewMockmessageDeleter(mockCtrl)
+			deleterMock.EXPECT().DeleteMessages(gomock.Any(), tc.token, tc.mockFilter).Return(tc.mockDeliveries, tc.mockError).Times(tc.mockTimes)
+
+			handler := NewDeleteBulk(slogdiscard.NewDiscardLogger(), deleterMock)
+
+			req, err := http.NewRequest(http.MethodDelete, "/telegram/messages"+tc.query, nil)
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				var resp BulkResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, tc.wantResp, resp)
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
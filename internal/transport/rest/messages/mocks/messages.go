@@ -0,0 +1,104 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: messages.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/testit-tms/webhook-bot/internal/entities"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockdeliveryGeter is a mock of deliveryGeter interface.
+type MockdeliveryGeter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdeliveryGeterMockRecorder
+}
+
+// MockdeliveryGeterMockRecorder is the mock recorder for MockdeliveryGeter.
+type MockdeliveryGeterMockRecorder struct {
+	mock *MockdeliveryGeter
+}
+
+// NewMockdeliveryGeter creates a new mock instance.
+func NewMockdeliveryGeter(ctrl *gomock.Controller) *MockdeliveryGeter {
+	mock := &MockdeliveryGeter{ctrl: ctrl}
+	mock.recorder = &MockdeliveryGeterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockdeliveryGeter) EXPECT() *MockdeliveryGeterMockRecorder {
+	return m.recorder
+}
+
+// GetDeliveries mocks base method.
+func (m *MockdeliveryGeter) GetDeliveries(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, token, messageID)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockdeliveryGeterMockRecorder) GetDeliveries(ctx, token, messageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockdeliveryGeter)(nil).GetDeliveries), ctx, token, messageID)
+}
+
+// MockmessageDeleter is a mock of messageDeleter interface.
+type MockmessageDeleter struct {
+	ctrl     *gomock.Controller
+	recorder *MockmessageDeleterMockRecorder
+}
+
+// MockmessageDeleterMockRecorder is the mock recorder for MockmessageDeleter.
+type MockmessageDeleterMockRecorder struct {
+	mock *MockmessageDeleter
+}
+
+// NewMockmessageDeleter creates a new mock instance.
+func NewMockmessageDeleter(ctrl *gomock.Controller) *MockmessageDeleter {
+	mock := &MockmessageDeleter{ctrl: ctrl}
+	mock.recorder = &MockmessageDeleterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockmessageDeleter) EXPECT() *MockmessageDeleterMockRecorder {
+	return m.recorder
+}
+
+// DeleteMessage mocks base method.
+func (m *MockmessageDeleter) DeleteMessage(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessage", ctx, token, messageID)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMessage indicates an expected call of DeleteMessage.
+func (mr *MockmessageDeleterMockRecorder) DeleteMessage(ctx, token, messageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessage", reflect.TypeOf((*MockmessageDeleter)(nil).DeleteMessage), ctx, token, messageID)
+}
+
+// DeleteMessages mocks base method.
+func (m *MockmessageDeleter) DeleteMessages(ctx context.Context, token string, filter entities.MessageFilter) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessages", ctx, token, filter)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMessages indicates an expected call of DeleteMessages.
+func (mr *MockmessageDeleterMockRecorder) DeleteMessages(ctx, token, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessages", reflect.TypeOf((*MockmessageDeleter)(nil).DeleteMessages), ctx, token, filter)
+}
@@ -0,0 +1,63 @@
+package messages
+
+import "github.com/testit-tms/webhook-bot/internal/entities"
+
+// Response represents the delivery state of a message.
+type Response struct {
+	ID    int64          `json:"id"`
+	Chats []ChatResponse `json:"chats"`
+}
+
+// BulkResponse represents the delivery state of several messages.
+type BulkResponse struct {
+	Messages []Response `json:"messages"`
+}
+
+// ChatResponse represents the delivery state of a message in a single chat.
+type ChatResponse struct {
+	ChatID    int64  `json:"chatId"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	MessageID int    `json:"messageId,omitempty"`
+	Error     string `json:"error,omitempty"`
+	PlainText bool   `json:"plainText,omitempty"`
+}
+
+func newResponse(id int64, deliveries []entities.Delivery) Response {
+	resp := Response{
+		ID:    id,
+		Chats: make([]ChatResponse, 0, len(deliveries)),
+	}
+
+	for _, d := range deliveries {
+		resp.Chats = append(resp.Chats, ChatResponse{
+			ChatID:    d.ChatID,
+			Status:    string(d.Status),
+			Attempts:  d.Attempts,
+			MessageID: d.TelegramMessageID,
+			Error:     d.LastError,
+			PlainText: d.PlainText,
+		})
+	}
+
+	return resp
+}
+
+// newBulkResponse groups the deliveries ordered by message by their messages.
+func newBulkResponse(deliveries []entities.Delivery) BulkResponse {
+	resp := BulkResponse{
+		Messages: []Response{},
+	}
+
+	for i := 0; i < len(deliveries); {
+		j := i
+		for j < len(deliveries) && deliveries[j].MessageID == deliveries[i].MessageID {
+			j++
+		}
+
+		resp.Messages = append(resp.Messages, newResponse(deliveries[i].MessageID, deliveries[i:j]))
+		i = j
+	}
+
+	return resp
+}
@@ -35,12 +35,28 @@ func (m *Mocksender) EXPECT() *MocksenderMockRecorder {
 	return m.recorder
 }
 
+// QueueMessage mocks base method.
+func (m *Mocksender) QueueMessage(ctx context.Context, msg entities.Message) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueMessage", ctx, msg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueMessage indicates an expected call of QueueMessage.
+func (mr *MocksenderMockRecorder) QueueMessage(ctx, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueMessage", reflect.TypeOf((*Mocksender)(nil).QueueMessage), ctx, msg)
+}
+
 // SendMessage mocks base method.
-func (m *Mocksender) SendMessage(ctx context.Context, msg entities.Message) error {
+func (m *Mocksender) SendMessage(ctx context.Context, msg entities.Message) (entities.SendReport, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SendMessage", ctx, msg)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(entities.SendReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SendMessage indicates an expected call of SendMessage.
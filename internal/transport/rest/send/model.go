@@ -1,18 +1,200 @@
 package send
 
-import "github.com/testit-tms/webhook-bot/internal/entities"
+import (
+	"net/http"
+	"time"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
 
 // Request represents a request to send a message.
+// The message is either the text of the request or the company template rendered with the data.
+// The text may be omitted if the message has attachments.
+// The chats are given by their Telegram IDs in ChatIds or by their aliases in Chats.
 type Request struct {
-	Message   string  `json:"message" validate:"required"`
-	ParseMode string  `json:"parseMode,omitempty" validate:"parse-mode"`
-	ChatIds   []int64 `json:"chatIds,omitempty"`
+	Message     string                 `json:"message" validate:"required_without_all=Template Attachments"`
+	ParseMode   string                 `json:"parseMode,omitempty" validate:"parse-mode"`
+	ChatIds     []int64                `json:"chatIds,omitempty"`
+	Chats       []string               `json:"chats,omitempty" validate:"dive,required,max=64"`
+	Template    string                 `json:"template,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Attachments []Attachment           `json:"attachments,omitempty" validate:"dive"`
+	Buttons     [][]Button             `json:"buttons,omitempty" validate:"excluded_without_all=Message Template,keyboard-size,dive,min=1,max=8,dive"`
+	// DisableNotification sends the message silently, users get a notification without sound.
+	DisableNotification bool `json:"disableNotification,omitempty"`
+	// ProtectContent protects the message from forwarding and saving.
+	ProtectContent bool `json:"protectContent,omitempty"`
+	// DisableWebPagePreview disables link previews for links in the message.
+	DisableWebPagePreview bool `json:"disableWebPagePreview,omitempty"`
+	// ReplyToMessageID is the ID of the Telegram message to reply to,
+	// the message is sent without the reply to chats where there is no such message.
+	ReplyToMessageID int `json:"replyToMessageId,omitempty" validate:"min=0"`
+	// CorrelationID identifies the messages about the same process, e.g. a test run.
+	// A message with the ID of an earlier one edits the text of the earlier message instead of posting a new one.
+	CorrelationID string `json:"correlationId,omitempty" validate:"max=255"`
+	// ThreadKey identifies the messages about the same process like CorrelationID,
+	// but a message with the key of an earlier one is sent as a reply to the first message with the key.
+	ThreadKey string `json:"threadKey,omitempty" validate:"max=255,excluded_with=CorrelationID"`
+	// SendAt is the time to deliver the message at, the message is delivered right away if it is omitted or in the past.
+	SendAt *time.Time `json:"sendAt,omitempty"`
+	// Urgent delivers the message during the quiet hours of the chats.
+	Urgent bool `json:"urgent,omitempty"`
+	// DedupKey identifies duplicates of the message if the company suppresses them, by default the text identifies them.
+	DedupKey string `json:"dedupKey,omitempty" validate:"max=255"`
+	// Project, EventType, Severity and Tags describe the message for the routing rules of the company,
+	// a message without chat IDs is sent to the chats of the rules it matches.
+	Project   string   `json:"project,omitempty"`
+	EventType string   `json:"eventType,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// Button represents an inline button under the message, the buttons are arranged in rows.
+type Button struct {
+	Text string `json:"text" validate:"required,max=64"`
+	URL  string `json:"url" validate:"required,max=2048,button-url"`
+}
+
+// Attachment represents a file attached to a message, either with base64 encoded data or with a URL to download it from.
+type Attachment struct {
+	Type string `json:"type,omitempty" validate:"omitempty,oneof=photo document"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty" validate:"required_without=Data,omitempty,url"`
+	Data []byte `json:"data,omitempty"`
 }
 
 func (m *Request) convertToDomain() entities.Message {
+	var sendAt time.Time
+	if m.SendAt != nil {
+		sendAt = *m.SendAt
+	}
+
 	return entities.Message{
-		ParseMode: entities.ParseString(m.ParseMode),
-		Text:      m.Message,
-		ChatIds:   m.ChatIds,
+		ParseMode:   entities.ParseString(m.ParseMode),
+		Text:        m.Message,
+		ChatIds:     m.ChatIds,
+		ChatAliases: m.Chats,
+		Template:    m.Template,
+		Data:        m.Data,
+		Attachments: convertAttachments(m.Attachments),
+		Buttons:     convertButtons(m.Buttons),
+
+		DisableNotification:   m.DisableNotification,
+		ProtectContent:        m.ProtectContent,
+		DisableWebPagePreview: m.DisableWebPagePreview,
+		ReplyToMessageID:      m.ReplyToMessageID,
+		CorrelationID:         m.CorrelationID,
+		ThreadKey:             m.ThreadKey,
+		SendAt:                sendAt,
+		Urgent:                m.Urgent,
+		DedupKey:              m.DedupKey,
+		Metadata: entities.Metadata{
+			Project:   m.Project,
+			EventType: m.EventType,
+			Severity:  m.Severity,
+			Tags:      m.Tags,
+		},
+	}
+}
+
+func convertButtons(rows [][]Button) [][]entities.Button {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	res := make([][]entities.Button, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]entities.Button, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, entities.Button{
+				Text: b.Text,
+				URL:  b.URL,
+			})
+		}
+		res = append(res, buttons)
+	}
+
+	return res
+}
+
+// convertAttachments converts the attachments of the request, attachments without a type are sent as documents.
+func convertAttachments(attachments []Attachment) []entities.Attachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	res := make([]entities.Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		t := entities.AttachmentDocument
+		if a.Type == string(entities.AttachmentPhoto) {
+			t = entities.AttachmentPhoto
+		}
+
+		res = append(res, entities.Attachment{
+			Type: t,
+			Name: a.Name,
+			URL:  a.URL,
+			Data: a.Data,
+		})
+	}
+
+	return res
+}
+
+// Response represents the delivery report of a message.
+type Response struct {
+	ID    int64          `json:"id"`
+	Chats []ChatResponse `json:"chats"`
+}
+
+// AcceptedResponse represents the response to a message queued for asynchronous delivery.
+type AcceptedResponse struct {
+	ID int64 `json:"id"`
+}
+
+// ChatResponse represents the result of sending a message to a single chat.
+type ChatResponse struct {
+	ChatID    int64  `json:"chatId"`
+	Status    string `json:"status"`
+	MessageID int    `json:"messageId,omitempty"`
+	Error     string `json:"error,omitempty"`
+	PlainText bool   `json:"plainText,omitempty"`
+}
+
+// newResponse converts the report to the response and returns the HTTP status for it:
+// 200 if the message is sent or suppressed as a duplicate in every chat, 202 if it is queued for every chat, e.g. scheduled,
+// otherwise 207.
+func newResponse(report entities.SendReport) (Response, int) {
+	resp := Response{
+		ID:    report.MessageID,
+		Chats: make([]ChatResponse, 0, len(report.Results)),
 	}
+
+	sent, queued := 0, 0
+	for _, r := range report.Results {
+		switch r.Status {
+		case entities.SendStatusSent, entities.SendStatusSuppressed:
+			sent++
+		case entities.SendStatusQueued:
+			queued++
+		}
+
+		resp.Chats = append(resp.Chats, ChatResponse{
+			ChatID:    r.ChatID,
+			Status:    string(r.Status),
+			MessageID: r.MessageID,
+			Error:     r.Error,
+			PlainText: r.PlainText,
+		})
+	}
+
+	status := http.StatusMultiStatus
+	switch len(report.Results) {
+	case sent:
+		status = http.StatusOK
+	case queued:
+		status = http.StatusAccepted
+	}
+
+	return resp, status
 }
@@ -0,0 +1,129 @@
+package send
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+// decodeMultipart decodes the multipart/form-data body of the request.
+// The text fields hold the fields of Request with comma separated chatIds and JSON encoded data and buttons,
+// the files of the "photo" and "document" fields become attachments in the order of the parts.
+func decodeMultipart(body []byte, boundary string) (Request, error) {
+	var req Request
+
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return req, nil
+		}
+		if err != nil {
+			return Request{}, err
+		}
+
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return Request{}, err
+		}
+
+		switch name := part.FormName(); name {
+		case string(entities.AttachmentPhoto), string(entities.AttachmentDocument):
+			req.Attachments = append(req.Attachments, Attachment{
+				Type: name,
+				Name: part.FileName(),
+				Data: value,
+			})
+		case "message":
+			req.Message = string(value)
+		case "parseMode":
+			req.ParseMode = string(value)
+		case "template":
+			req.Template = string(value)
+		case "correlationId":
+			req.CorrelationID = string(value)
+		case "threadKey":
+			req.ThreadKey = string(value)
+		case "dedupKey":
+			req.DedupKey = string(value)
+		case "project":
+			req.Project = string(value)
+		case "eventType":
+			req.EventType = string(value)
+		case "severity":
+			req.Severity = string(value)
+		case "tags":
+			for _, tag := range strings.Split(string(value), ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					req.Tags = append(req.Tags, tag)
+				}
+			}
+		case "sendAt":
+			t, err := time.Parse(time.RFC3339, string(value))
+			if err != nil {
+				return Request{}, fmt.Errorf("sendAt: %w", err)
+			}
+			req.SendAt = &t
+		case "urgent":
+			urgent, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return Request{}, fmt.Errorf("urgent: %w", err)
+			}
+			req.Urgent = urgent
+		case "disableNotification":
+			disable, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return Request{}, fmt.Errorf("disableNotification: %w", err)
+			}
+			req.DisableNotification = disable
+		case "protectContent":
+			protect, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return Request{}, fmt.Errorf("protectContent: %w", err)
+			}
+			req.ProtectContent = protect
+		case "disableWebPagePreview":
+			disable, err := strconv.ParseBool(string(value))
+			if err != nil {
+				return Request{}, fmt.Errorf("disableWebPagePreview: %w", err)
+			}
+			req.DisableWebPagePreview = disable
+		case "replyToMessageId":
+			id, err := strconv.Atoi(strings.TrimSpace(string(value)))
+			if err != nil {
+				return Request{}, fmt.Errorf("replyToMessageId: %w", err)
+			}
+			req.ReplyToMessageID = id
+		case "data":
+			if err := json.Unmarshal(value, &req.Data); err != nil {
+				return Request{}, fmt.Errorf("data: %w", err)
+			}
+		case "buttons":
+			if err := json.Unmarshal(value, &req.Buttons); err != nil {
+				return Request{}, fmt.Errorf("buttons: %w", err)
+			}
+		case "chats":
+			for _, alias := range strings.Split(string(value), ",") {
+				if alias = strings.TrimSpace(alias); alias != "" {
+					req.Chats = append(req.Chats, alias)
+				}
+			}
+		case "chatIds":
+			for _, s := range strings.Split(string(value), ",") {
+				id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				if err != nil {
+					return Request{}, fmt.Errorf("chatIds: %w", err)
+				}
+				req.ChatIds = append(req.ChatIds, id)
+			}
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package send
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
@@ -11,19 +18,36 @@ import (
 	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
 	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
 	val "github.com/testit-tms/webhook-bot/internal/lib/validator"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
 	"golang.org/x/exp/slog"
 )
 
 //go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
 type sender interface {
-	SendMessage(ctx context.Context, msg entities.Message) error
+	SendMessage(ctx context.Context, msg entities.Message) (entities.SendReport, error)
+	QueueMessage(ctx context.Context, msg entities.Message) (int64, error)
 }
 
+const (
+	respondAsync = "respond-async"
+	// maxIdempotencyKeyLen is the length of the idempotency key column.
+	maxIdempotencyKeyLen = 255
+	// maxRequestSize is the limit of the Telegram Bot API for uploaded files.
+	maxRequestSize = 50 << 20
+	// maxEventSize is the limit of Test IT webhook events, they are small JSON documents.
+	maxEventSize = 1 << 20
+)
+
 // New returns a new http.HandlerFunc that sends a message using the provided sender.
 // It validates the request, converts it to a message, and sends it using the sender.
+// It responds with the delivery report for every chat: 200 if the message is sent to all chats, 207 otherwise.
+// Files are attached either in the JSON body with base64 encoded data or URLs, or as parts of a multipart/form-data body.
+// If the request has the "Prefer: respond-async" header, the message is only queued and it responds 202 with the message ID.
+// A request with the "Idempotency-Key" header is sent only once, repeated requests with the same key get the result of the first one
+// and the "Idempotent-Replayed: true" header. If hashRequests is set, requests without the header are identified by the hash of the token and the body.
 // If any error occurs during the process, it returns an error response.
 // It requires an Authorization token in the request header.
-func New(log *slog.Logger, sender sender) http.HandlerFunc {
+func New(log *slog.Logger, sender sender, hashRequests bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "transport.rest.send.New"
 
@@ -32,16 +56,21 @@ func New(log *slog.Logger, sender sender) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			log.Debug("token not found")
-			handlers.NewErrorResponse(w, http.StatusUnauthorized, "token is required")
+		raw, ok := readRequest(log, w, r, maxRequestSize, hashRequests)
+		if !ok {
 			return
 		}
 
-		var req Request
+		var (
+			req Request
+			err error
+		)
 
-		err := render.DecodeJSON(r.Body, &req)
+		if mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			req, err = decodeMultipart(raw.body, params["boundary"])
+		} else {
+			err = render.DecodeJSON(bytes.NewReader(raw.body), &req)
+		}
 		if err != nil {
 			log.Error("failed to decode request body", sl.Err(err))
 
@@ -54,6 +83,10 @@ func New(log *slog.Logger, sender sender) http.HandlerFunc {
 		v := validator.New()
 		// nolint:errcheck
 		v.RegisterValidation("parse-mode", val.ValidateParseMode)
+		// nolint:errcheck
+		v.RegisterValidation("button-url", val.ValidateButtonURL)
+		// nolint:errcheck
+		v.RegisterValidation("keyboard-size", val.ValidateKeyboardSize)
 		if err := v.Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
 			log.Error("invalid request", sl.Err(err))
@@ -64,19 +97,122 @@ func New(log *slog.Logger, sender sender) http.HandlerFunc {
 		}
 
 		message := req.convertToDomain()
-		message.Token = token
+		message.Token = raw.token
+		message.Idempotency.Key = raw.key
 
 		log.Debug("request convert to message", slog.Any("message", message))
-		err = sender.SendMessage(r.Context(), message)
+
+		if preferAsync(r) {
+			id, err := sender.QueueMessage(r.Context(), message)
+			if err != nil {
+				log.Error("can not queue message", sl.Err(err))
+
+				newSendError(w, err)
+				return
+			}
+
+			w.Header().Set("Preference-Applied", respondAsync)
+			handlers.NewJSONResponse(w, http.StatusAccepted, AcceptedResponse{ID: id})
+			return
+		}
+
+		report, err := sender.SendMessage(r.Context(), message)
 		if err != nil {
 			log.Error("can not send message", sl.Err(err))
 
-			handlers.NewErrorResponse(w, http.StatusInternalServerError, "can't send message")
+			newSendError(w, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		// nolint:errcheck
-		w.Write([]byte("message sent"))
+		if report.Replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+
+		resp, status := newResponse(report)
+		handlers.NewJSONResponse(w, status, resp)
+	}
+}
+
+// rawRequest represents the authorization token, the idempotency key and the body of a request to send a message.
+type rawRequest struct {
+	token string
+	key   string
+	body  []byte
+}
+
+// readRequest checks the token and the idempotency key of the request and reads its body of at most limit bytes.
+// If hashRequests is set, requests without the Idempotency-Key header are keyed by the hash of the token and the body.
+// If the request can not be read, it writes the error response and returns false.
+func readRequest(log *slog.Logger, w http.ResponseWriter, r *http.Request, limit int64, hashRequests bool) (rawRequest, bool) {
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		log.Debug("token not found")
+		handlers.NewErrorResponse(w, http.StatusUnauthorized, "token is required")
+		return rawRequest{}, false
 	}
+
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLen {
+		log.Debug("idempotency key is too long")
+		handlers.NewErrorResponse(w, http.StatusBadRequest, "idempotency key is too long")
+		return rawRequest{}, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		log.Error("failed to read request body", sl.Err(err))
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			handlers.NewErrorResponse(w, http.StatusRequestEntityTooLarge, "request is too large")
+			return rawRequest{}, false
+		}
+
+		handlers.NewErrorResponse(w, http.StatusBadRequest, "failed to decode request")
+		return rawRequest{}, false
+	}
+
+	if key == "" && hashRequests {
+		key = requestHash(token, body)
+	}
+
+	return rawRequest{token: token, key: key, body: body}, true
+}
+
+// newSendError writes the error response for the error of the sender.
+func newSendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, usecases.ErrTemplateNotFound):
+		handlers.NewErrorResponse(w, http.StatusNotFound, "template not found")
+	case errors.Is(err, usecases.ErrChatAliasNotFound):
+		handlers.NewErrorResponse(w, http.StatusNotFound, "chat alias not found")
+	case errors.Is(err, usecases.ErrCanNotRender):
+		handlers.NewErrorResponse(w, http.StatusBadRequest, "can't render template")
+	default:
+		handlers.NewErrorResponse(w, http.StatusInternalServerError, "can't send message")
+	}
+}
+
+// preferAsync reports whether the client asked to respond before the message is delivered.
+func preferAsync(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(token), respondAsync) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// requestHash returns the idempotency key identifying the request by its token and body.
+func requestHash(token string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(token))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
 }
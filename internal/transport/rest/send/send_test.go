@@ -2,34 +2,40 @@ package send
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/testit-tms/webhook-bot/internal/entities"
 	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
 	"github.com/testit-tms/webhook-bot/internal/lib/logger/slogdiscard"
 	"github.com/testit-tms/webhook-bot/internal/transport/rest/send/mocks"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
 	"go.uber.org/mock/gomock"
 )
 
 func TestNew(t *testing.T) {
 	tests := []struct {
-		name      string
-		token     string
-		message   string
-		parseMode string
-		chatIds   []int64
-		body      string
-		respCode  int
-		respError string
-		mockTimes int
-		mockError error
+		name       string
+		token      string
+		message    string
+		parseMode  string
+		chatIds    []int64
+		body       string
+		respCode   int
+		respError  string
+		mockTimes  int
+		mockError  error
+		mockReport entities.SendReport
+		wantResp   Response
 	}{
 		{
 			name:      "Success",
@@ -38,8 +44,63 @@ func TestNew(t *testing.T) {
 			parseMode: "HTML",
 			chatIds:   []int64{12345},
 			respCode:  http.StatusOK,
-			respError: "message sent",
 			mockTimes: 1,
+			mockReport: entities.SendReport{
+				MessageID: 7,
+				Results: []entities.SendResult{
+					{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55},
+				},
+			},
+			wantResp: Response{
+				ID: 7,
+				Chats: []ChatResponse{
+					{ChatID: 12345, Status: "sent", MessageID: 55},
+				},
+			},
+		},
+		{
+			name:      "sent as plain text",
+			token:     "token",
+			message:   "test message",
+			parseMode: "HTML",
+			chatIds:   []int64{12345},
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+			mockReport: entities.SendReport{
+				MessageID: 7,
+				Results: []entities.SendResult{
+					{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55, PlainText: true},
+				},
+			},
+			wantResp: Response{
+				ID: 7,
+				Chats: []ChatResponse{
+					{ChatID: 12345, Status: "sent", MessageID: 55, PlainText: true},
+				},
+			},
+		},
+		{
+			name:      "partial success",
+			token:     "token",
+			message:   "test message",
+			parseMode: "HTML",
+			chatIds:   []int64{12345, 54321},
+			respCode:  http.StatusMultiStatus,
+			mockTimes: 1,
+			mockReport: entities.SendReport{
+				MessageID: 7,
+				Results: []entities.SendResult{
+					{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55},
+					{ChatID: 54321, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was kicked from the group chat"},
+				},
+			},
+			wantResp: Response{
+				ID: 7,
+				Chats: []ChatResponse{
+					{ChatID: 12345, Status: "sent", MessageID: 55},
+					{ChatID: 54321, Status: "forbidden", Error: "Forbidden: bot was kicked from the group chat"},
+				},
+			},
 		},
 		{
 			name:      "unauthorized",
@@ -71,21 +132,47 @@ func TestNew(t *testing.T) {
 			respError: "field ParseMode must be empty or have following value: markdownv2, markdown or html",
 			mockTimes: 0,
 		},
+		{
+			name:      "markdownv2 parse mode",
+			token:     "token",
+			message:   "*bold*",
+			parseMode: "MarkdownV2",
+			chatIds:   []int64{12345},
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+			wantResp: Response{
+				Chats: []ChatResponse{},
+			},
+		},
+		{
+			name:      "markdown parse mode",
+			token:     "token",
+			message:   "*bold*",
+			parseMode: "markdown",
+			chatIds:   []int64{12345},
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+			wantResp: Response{
+				Chats: []ChatResponse{},
+			},
+		},
 		{
 			name:      "empty parse mode",
 			token:     "token",
 			message:   "test message",
 			chatIds:   []int64{12345},
 			respCode:  http.StatusOK,
-			respError: "message sent",
 			mockTimes: 1,
+			wantResp: Response{
+				Chats: []ChatResponse{},
+			},
 		},
 		{
 			name:      "empty message",
 			token:     "token",
 			chatIds:   []int64{12345},
 			respCode:  http.StatusBadRequest,
-			respError: "field Message is a required field",
+			respError: "field Message is required if fields Template, Attachments are empty",
 			mockTimes: 0,
 		},
 		{
@@ -93,9 +180,11 @@ func TestNew(t *testing.T) {
 			token:     "token",
 			message:   "test message",
 			respCode:  http.StatusOK,
-			respError: "message sent",
 			chatIds:   []int64{},
 			mockTimes: 1,
+			wantResp: Response{
+				Chats: []ChatResponse{},
+			},
 		},
 		{
 			name:      "error send message",
@@ -124,13 +213,9 @@ func TestNew(t *testing.T) {
 				Token:     tc.token,
 			}
 
-			if tc.mockError != nil {
-				senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(tc.mockError).Times(tc.mockTimes)
-			} else {
-				senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(nil).Times(tc.mockTimes)
-			}
+			senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(tc.mockReport, tc.mockError).Times(tc.mockTimes)
 
-			handler := New(slogdiscard.NewDiscardLogger(), senderMock)
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
 
 			var input string
 			if tc.body != "" {
@@ -154,18 +239,937 @@ func TestNew(t *testing.T) {
 
 			body := rr.Body.String()
 
-			var respMessage string
+			if tc.respCode == http.StatusOK || tc.respCode == http.StatusMultiStatus {
+				var resp Response
+				require.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+				require.Equal(t, tc.wantResp, resp)
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Async(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefer    string
+		respCode  int
+		wantID    int64
+		respError string
+		mockError error
+	}{
+		{
+			name:     "queued",
+			prefer:   "respond-async",
+			respCode: http.StatusAccepted,
+			wantID:   7,
+		},
+		{
+			name:     "with other preferences",
+			prefer:   "return=minimal, respond-async; wait=10",
+			respCode: http.StatusAccepted,
+			wantID:   7,
+		},
+		{
+			name:      "error queue message",
+			prefer:    "respond-async",
+			respCode:  http.StatusInternalServerError,
+			respError: "can't send message",
+			mockError: errors.New("some error"),
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			mes := entities.Message{
+				Text:      "test message",
+				ParseMode: entities.HTML,
+				ChatIds:   []int64{12345},
+				Token:     "token",
+			}
+
+			senderMock.EXPECT().QueueMessage(gomock.Any(), mes).Return(tc.wantID, tc.mockError).Times(1)
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			input := `{"message": "test message", "parseMode": "HTML", "chatIds": [12345]}`
+			req, err := http.NewRequest(http.MethodPost, "/telegram", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			req.Header.Set("Prefer", tc.prefer)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusAccepted {
+				var resp AcceptedResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, tc.wantID, resp.ID)
+				require.Equal(t, "respond-async", rr.Header().Get("Preference-Applied"))
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Idempotency(t *testing.T) {
+	input := `{"message": "test message", "parseMode": "HTML", "chatIds": [12345]}`
+
+	tests := []struct {
+		name         string
+		key          string
+		hashRequests bool
+		wantKey      string
+		replayed     bool
+		respCode     int
+		respError    string
+		mockTimes    int
+	}{
+		{
+			name:      "with key",
+			key:       "run-42",
+			wantKey:   "run-42",
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+		},
+		{
+			name:      "replayed",
+			key:       "run-42",
+			wantKey:   "run-42",
+			replayed:  true,
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+		},
+		{
+			name:         "key has priority over hash",
+			key:          "run-42",
+			hashRequests: true,
+			wantKey:      "run-42",
+			respCode:     http.StatusOK,
+			mockTimes:    1,
+		},
+		{
+			name:         "hash of request",
+			hashRequests: true,
+			wantKey:      "sha256:" + fmt.Sprintf("%x", sha256.Sum256([]byte("token\n"+input))),
+			respCode:     http.StatusOK,
+			mockTimes:    1,
+		},
+		{
+			name:      "without key",
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+		},
+		{
+			name:      "too long key",
+			key:       strings.Repeat("k", 256),
+			respCode:  http.StatusBadRequest,
+			respError: "idempotency key is too long",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			mes := entities.Message{
+				Text:        "test message",
+				ParseMode:   entities.HTML,
+				ChatIds:     []int64{12345},
+				Token:       "token",
+				Idempotency: entities.Idempotency{Key: tc.wantKey},
+			}
+			report := entities.SendReport{
+				MessageID: 7,
+				Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+				Replayed:  tc.replayed,
+			}
+
+			senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(tc.mockTimes)
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, tc.hashRequests)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			if tc.key != "" {
+				req.Header.Set("Idempotency-Key", tc.key)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
 
 			if tc.respCode == http.StatusOK {
-				respMessage = "message sent"
-			} else {
+				var resp Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, int64(7), resp.ID)
+				if tc.replayed {
+					require.Equal(t, "true", rr.Header().Get("Idempotent-Replayed"))
+				} else {
+					require.Empty(t, rr.Header().Get("Idempotent-Replayed"))
+				}
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Template(t *testing.T) {
+	input := `{"template": "run-finished", "data": {"project": "API", "failed": 1}, "chatIds": [12345]}`
+
+	tests := []struct {
+		name      string
+		respCode  int
+		respError string
+		mockError error
+	}{
+		{
+			name:     "rendered",
+			respCode: http.StatusOK,
+		},
+		{
+			name:      "template not found",
+			respCode:  http.StatusNotFound,
+			respError: "template not found",
+			mockError: fmt.Errorf("template run-finished not found: %w", usecases.ErrTemplateNotFound),
+		},
+		{
+			name:      "can not render",
+			respCode:  http.StatusBadRequest,
+			respError: "can't render template",
+			mockError: fmt.Errorf("bad data: %w", usecases.ErrCanNotRender),
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			mes := entities.Message{
+				ParseMode: entities.Undefined,
+				ChatIds:   []int64{12345},
+				Token:     "token",
+				Template:  "run-finished",
+				Data:      map[string]interface{}{"project": "API", "failed": float64(1)},
+			}
+			report := entities.SendReport{
+				MessageID: 7,
+				Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+			}
+
+			senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, tc.mockError).Times(1)
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Attachments(t *testing.T) {
+	multipartBody := func() (string, string) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		require.NoError(t, mw.WriteField("message", "<b>Run failed</b>"))
+		require.NoError(t, mw.WriteField("parseMode", "HTML"))
+		require.NoError(t, mw.WriteField("chatIds", "12345, 67890"))
+		fw, err := mw.CreateFormFile("document", "report.html")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("<html></html>"))
+		require.NoError(t, err)
+		fw, err = mw.CreateFormFile("photo", "step.png")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("png"))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		return b.String(), mw.FormDataContentType()
+	}
+	body, contentType := multipartBody()
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		respCode    int
+		respError   string
+		want        entities.Message
+	}{
+		{
+			name: "json with data and url",
+			body: `{"message": "Run failed", "chatIds": [12345], "attachments": [` +
+				`{"type": "document", "name": "report.html", "data": "PGh0bWw+PC9odG1sPg=="},` +
+				`{"type": "photo", "url": "https://testit.software/step.png"}]}`,
+			contentType: "application/json",
+			respCode:    http.StatusOK,
+			want: entities.Message{
+				Text:      "Run failed",
+				ParseMode: entities.Undefined,
+				ChatIds:   []int64{12345},
+				Token:     "token",
+				Attachments: []entities.Attachment{
+					{Type: entities.AttachmentDocument, Name: "report.html", Data: []byte("<html></html>")},
+					{Type: entities.AttachmentPhoto, URL: "https://testit.software/step.png"},
+				},
+			},
+		},
+		{
+			name:        "json without message and type",
+			body:        `{"attachments": [{"url": "https://testit.software/report.html"}]}`,
+			contentType: "application/json",
+			respCode:    http.StatusOK,
+			want: entities.Message{
+				ParseMode: entities.Undefined,
+				Token:     "token",
+				Attachments: []entities.Attachment{
+					{Type: entities.AttachmentDocument, URL: "https://testit.software/report.html"},
+				},
+			},
+		},
+		{
+			name:        "multipart",
+			body:        body,
+			contentType: contentType,
+			respCode:    http.StatusOK,
+			want: entities.Message{
+				Text:      "<b>Run failed</b>",
+				ParseMode: entities.HTML,
+				ChatIds:   []int64{12345, 67890},
+				Token:     "token",
+				Attachments: []entities.Attachment{
+					{Type: entities.AttachmentDocument, Name: "report.html", Data: []byte("<html></html>")},
+					{Type: entities.AttachmentPhoto, Name: "step.png", Data: []byte("png")},
+				},
+			},
+		},
+		{
+			name:        "wrong type",
+			body:        `{"attachments": [{"type": "video", "url": "https://testit.software/run.mp4"}]}`,
+			contentType: "application/json",
+			respCode:    http.StatusBadRequest,
+			respError:   "field Attachments[0].Type must have one of following values: photo, document",
+		},
+		{
+			name:        "without url and data",
+			body:        `{"attachments": [{"type": "photo"}]}`,
+			contentType: "application/json",
+			respCode:    http.StatusBadRequest,
+			respError:   "field Attachments[0].URL is required if field Data is empty",
+		},
+		{
+			name:        "multipart with wrong chat id",
+			body:        strings.Replace(body, "12345, 67890", "first", 1),
+			contentType: contentType,
+			respCode:    http.StatusBadRequest,
+			respError:   "failed to decode request",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			if tc.respCode == http.StatusOK {
+				report := entities.SendReport{
+					MessageID: 7,
+					Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+				}
+				senderMock.EXPECT().SendMessage(gomock.Any(), tc.want).Return(report, nil).Times(1)
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Buttons(t *testing.T) {
+	button := `{"text": "Open test run", "url": "https://testit.software/runs/1"}`
+	row := func(n int) string {
+		return "[" + strings.TrimSuffix(strings.Repeat(button+",", n), ",") + "]"
+	}
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	require.NoError(t, mw.WriteField("message", "Run failed"))
+	require.NoError(t, mw.WriteField("buttons", "[["+button+"]]"))
+	fw, err := mw.CreateFormFile("document", "report.html")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("<html></html>"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		respCode    int
+		respError   string
+		want        [][]entities.Button
+		attachments []entities.Attachment
+	}{
+		{
+			name:     "rows of buttons",
+			body:     `{"message": "Run failed", "buttons": [[` + button + `, {"text": "Open defect", "url": "tg://resolve?domain=testit"}], [` + button + `]]}`,
+			respCode: http.StatusOK,
+			want: [][]entities.Button{
+				{{Text: "Open test run", URL: "https://testit.software/runs/1"}, {Text: "Open defect", URL: "tg://resolve?domain=testit"}},
+				{{Text: "Open test run", URL: "https://testit.software/runs/1"}},
+			},
+		},
+		{
+			name:        "multipart with file",
+			body:        b.String(),
+			contentType: mw.FormDataContentType(),
+			respCode:    http.StatusOK,
+			want:        [][]entities.Button{{{Text: "Open test run", URL: "https://testit.software/runs/1"}}},
+			attachments: []entities.Attachment{{Type: entities.AttachmentDocument, Name: "report.html", Data: []byte("<html></html>")}},
+		},
+		{
+			name:      "too many buttons in a row",
+			body:      `{"message": "Run failed", "buttons": [` + row(9) + `]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons[0] must have at most 8 items",
+		},
+		{
+			name:      "empty row",
+			body:      `{"message": "Run failed", "buttons": [[]]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons[0] must have at least 1 items",
+		},
+		{
+			name:      "too many buttons",
+			body:      `{"message": "Run failed", "buttons": [` + strings.TrimSuffix(strings.Repeat(row(8)+",", 13), ",") + `]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons must have at most 100 buttons",
+		},
+		{
+			name:      "wrong url",
+			body:      `{"message": "Run failed", "buttons": [[{"text": "Open", "url": "javascript:alert(1)"}]]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons[0][0].URL must be an http, https or tg URL",
+		},
+		{
+			name:      "too long text",
+			body:      `{"message": "Run failed", "buttons": [[{"text": "` + strings.Repeat("a", 65) + `", "url": "https://testit.software"}]]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons[0][0].Text must have at most 64 characters",
+		},
+		{
+			name:      "without text of the message",
+			body:      `{"attachments": [{"url": "https://testit.software/report.html"}], "buttons": [[` + button + `]]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Buttons can be used only with one of fields Message, Template",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			if tc.respCode == http.StatusOK {
+				mes := entities.Message{
+					Text:        "Run failed",
+					ParseMode:   entities.Undefined,
+					Token:       "token",
+					Buttons:     tc.want,
+					Attachments: tc.attachments,
+				}
+				report := entities.SendReport{
+					MessageID: 7,
+					Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+				}
+				senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(1)
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
+
+func TestNew_Options(t *testing.T) {
+	want := entities.Message{
+		Text:                  "Nightly run finished",
+		ParseMode:             entities.Undefined,
+		Token:                 "token",
+		DisableNotification:   true,
+		ProtectContent:        true,
+		DisableWebPagePreview: true,
+		ReplyToMessageID:      42,
+	}
+
+	multipartBody := func(replyTo string) (string, string) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		require.NoError(t, mw.WriteField("message", "Nightly run finished"))
+		require.NoError(t, mw.WriteField("disableNotification", "true"))
+		require.NoError(t, mw.WriteField("protectContent", "true"))
+		require.NoError(t, mw.WriteField("disableWebPagePreview", "true"))
+		require.NoError(t, mw.WriteField("replyToMessageId", replyTo))
+		require.NoError(t, mw.Close())
+		return b.String(), mw.FormDataContentType()
+	}
+	body, contentType := multipartBody("42")
+	wrongBody, wrongContentType := multipartBody("first")
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		respCode    int
+	}{
+		{
+			name: "json",
+			body: `{"message": "Nightly run finished", "disableNotification": true, "protectContent": true, ` +
+				`"disableWebPagePreview": true, "replyToMessageId": 42}`,
+			contentType: "application/json",
+			respCode:    http.StatusOK,
+		},
+		{
+			name:        "multipart",
+			body:        body,
+			contentType: contentType,
+			respCode:    http.StatusOK,
+		},
+		{
+			name:        "multipart with wrong reply to message id",
+			body:        wrongBody,
+			contentType: wrongContentType,
+			respCode:    http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			if tc.respCode == http.StatusOK {
+				report := entities.SendReport{
+					MessageID: 7,
+					Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+				}
+				senderMock.EXPECT().SendMessage(gomock.Any(), want).Return(report, nil).Times(1)
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+		})
+	}
+}
+
+func TestNew_CorrelationID(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		respCode  int
+		respError string
+		mockTimes int
+	}{
+		{
+			name:      "with correlation ID",
+			input:     `{"message": "Nightly run: 10 of 20 tests passed", "correlationId": "run-1"}`,
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+		},
+		{
+			name:      "with thread key and correlation ID",
+			input:     `{"message": "Nightly run: 10 of 20 tests passed", "correlationId": "run-1", "threadKey": "run-1"}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field ThreadKey can not be used with field CorrelationID",
+		},
+		{
+			name:      "too long correlation ID",
+			input:     `{"message": "Nightly run: 10 of 20 tests passed", "correlationId": "` + strings.Repeat("a", 256) + `"}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field CorrelationID must have at most 255 characters",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			mes := entities.Message{
+				Text:          "Nightly run: 10 of 20 tests passed",
+				ParseMode:     entities.Undefined,
+				Token:         "token",
+				CorrelationID: "run-1",
+			}
+			report := entities.SendReport{
+				MessageID: 7,
+				Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+			}
+			senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(tc.mockTimes)
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.input))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respError != "" {
 				var resp handlers.ErrorResponse
-				require.NoError(t, json.Unmarshal([]byte(body), &resp))
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, tc.respError, resp.Message)
+			}
+		})
+	}
+}
+
+func TestNew_ThreadKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	senderMock := mocks.NewMocksender(mockCtrl)
+
+	mes := entities.Message{
+		Text:      "Login test failed",
+		ParseMode: entities.Undefined,
+		Token:     "token",
+		ThreadKey: "nightly-2023-09-01",
+	}
+	report := entities.SendReport{
+		MessageID: 7,
+		Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+	}
+	senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(1)
+
+	handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+	input := `{"message": "Login test failed", "threadKey": "nightly-2023-09-01"}`
+	req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(input))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "token")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestNew_SendAt(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	senderMock := mocks.NewMocksender(mockCtrl)
+
+	mes := entities.Message{
+		Text:      "Nightly run starts in an hour",
+		ParseMode: entities.Undefined,
+		Token:     "token",
+		SendAt:    time.Date(2023, 9, 1, 21, 0, 0, 0, time.UTC),
+		Urgent:    true,
+	}
+	report := entities.SendReport{
+		MessageID: 7,
+		Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusQueued}},
+	}
+	senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(1)
+
+	handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+	input := `{"message": "Nightly run starts in an hour", "sendAt": "2023-09-01T21:00:00Z", "urgent": true}`
+	req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(input))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "token")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, Response{ID: 7, Chats: []ChatResponse{{ChatID: 12345, Status: "queued"}}}, resp)
+}
+
+func TestNew_DedupKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	senderMock := mocks.NewMocksender(mockCtrl)
+
+	mes := entities.Message{
+		Text:      "Login test failed in run 42",
+		ParseMode: entities.Undefined,
+		Token:     "token",
+		DedupKey:  "login-test-failed",
+	}
+	report := entities.SendReport{
+		MessageID: 7,
+		Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSuppressed}},
+	}
+	senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(1)
+
+	handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+	input := `{"message": "Login test failed in run 42", "dedupKey": "login-test-failed"}`
+	req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(input))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "token")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, Response{ID: 7, Chats: []ChatResponse{{ChatID: 12345, Status: "suppressed"}}}, resp)
+}
+
+func TestNew_Metadata(t *testing.T) {
+	want := entities.Message{
+		Text:      "Run failed",
+		ParseMode: entities.Undefined,
+		Token:     "token",
+		Metadata: entities.Metadata{
+			Project:   "Backend",
+			EventType: "TestRunCompleted",
+			Severity:  "critical",
+			Tags:      []string{"nightly", "api"},
+		},
+	}
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	require.NoError(t, mw.WriteField("message", "Run failed"))
+	require.NoError(t, mw.WriteField("project", "Backend"))
+	require.NoError(t, mw.WriteField("eventType", "TestRunCompleted"))
+	require.NoError(t, mw.WriteField("severity", "critical"))
+	require.NoError(t, mw.WriteField("tags", "nightly, api"))
+	require.NoError(t, mw.Close())
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+	}{
+		{
+			name: "json",
+			body: `{"message": "Run failed", "project": "Backend", "eventType": "TestRunCompleted",` +
+				` "severity": "critical", "tags": ["nightly", "api"]}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "multipart",
+			body:        b.String(),
+			contentType: mw.FormDataContentType(),
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
 
-				respMessage = resp.Message
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			report := entities.SendReport{
+				MessageID: 7,
+				Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
 			}
+			senderMock.EXPECT().SendMessage(gomock.Any(), want).Return(report, nil).Times(1)
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
 
-			require.Equal(t, tc.respError, respMessage)
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+		})
+	}
+}
+
+func TestNew_ChatAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		sendErr   error
+		respCode  int
+		respError string
+	}{
+		{
+			name:     "success",
+			body:     `{"message": "Run failed", "chatIds": [12345], "chats": ["qa-team", "release"]}`,
+			respCode: http.StatusOK,
+		},
+		{
+			name:      "unknown alias",
+			body:      `{"message": "Run failed", "chatIds": [12345], "chats": ["qa-team", "release"]}`,
+			sendErr:   fmt.Errorf("usecases.SendMessage: chat release not found: %w", usecases.ErrChatAliasNotFound),
+			respCode:  http.StatusNotFound,
+			respError: "chat alias not found",
+		},
+		{
+			name:      "empty alias",
+			body:      `{"message": "Run failed", "chats": [""]}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Chats[0] is a required field",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			if tc.respCode != http.StatusBadRequest {
+				mes := entities.Message{
+					Text:        "Run failed",
+					ParseMode:   entities.Undefined,
+					Token:       "token",
+					ChatIds:     []int64{12345},
+					ChatAliases: []string{"qa-team", "release"},
+				}
+				report := entities.SendReport{
+					MessageID: 7,
+					Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+				}
+				senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, tc.sendErr).Times(1)
+			}
+
+			handler := New(slogdiscard.NewDiscardLogger(), senderMock, false)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "token")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respError != "" {
+				var resp handlers.ErrorResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, tc.respError, resp.Message)
+			}
 		})
 	}
 }
@@ -0,0 +1,265 @@
+package send
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"golang.org/x/exp/slog"
+)
+
+// Event types of Test IT webhooks.
+const (
+	EventTestRunStarted        = "TestRunStarted"
+	EventTestRunCompleted      = "TestRunCompleted"
+	EventAutoTestResultCreated = "AutoTestResultCreated"
+	EventWorkItemCreated       = "WorkItemCreated"
+	EventWorkItemUpdated       = "WorkItemUpdated"
+	EventWorkItemDeleted       = "WorkItemDeleted"
+)
+
+var errUnsupportedEvent = errors.New("unsupported event")
+
+// Event represents a webhook event sent by Test IT.
+type Event struct {
+	Type       string      `json:"eventType" validate:"required"`
+	Project    Project     `json:"project"`
+	TestRun    *TestRun    `json:"testRun,omitempty"`
+	TestResult *TestResult `json:"testResult,omitempty"`
+	WorkItem   *WorkItem   `json:"workItem,omitempty"`
+	User       *User       `json:"user,omitempty"`
+}
+
+// Project represents the Test IT project of an event.
+type Project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// TestRun represents a Test IT test run.
+type TestRun struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Statistics Statistics `json:"statistics"`
+}
+
+// Statistics represents the outcomes of the test results of a test run.
+type Statistics struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	Blocked int `json:"blocked"`
+}
+
+// TestResult represents the result of an autotest in a test run.
+type TestResult struct {
+	AutoTestName string `json:"autoTestName"`
+	Outcome      string `json:"outcome"`
+	Message      string `json:"message"`
+	TestRunName  string `json:"testRunName"`
+	URL          string `json:"url"`
+}
+
+// WorkItem represents a Test IT work item like a test case or a checklist.
+type WorkItem struct {
+	GlobalID int64  `json:"globalId"`
+	Name     string `json:"name"`
+	Type     string `json:"entityTypeName"`
+	URL      string `json:"url"`
+}
+
+// User represents the Test IT user who caused an event.
+type User struct {
+	Name string `json:"name"`
+}
+
+// convertToDomain formats the event as an HTML message for all chats of the company.
+// The project and the type of the event and the event itself as the data of the message are matched by the routing rules of the company.
+func (e *Event) convertToDomain() (entities.Message, error) {
+	var b strings.Builder
+
+	switch {
+	case strings.EqualFold(e.Type, EventTestRunStarted) && e.TestRun != nil:
+		fmt.Fprintf(&b, "▶️ Test run <b>%s</b> started", html.EscapeString(e.TestRun.Name))
+		writeProject(&b, e.Project)
+		writeUser(&b, e.User)
+		writeLink(&b, e.TestRun.URL)
+	case strings.EqualFold(e.Type, EventTestRunCompleted) && e.TestRun != nil:
+		s := e.TestRun.Statistics
+		icon := "✅"
+		if s.Failed > 0 {
+			icon = "❌"
+		}
+		fmt.Fprintf(&b, "%s Test run <b>%s</b> completed", icon, html.EscapeString(e.TestRun.Name))
+		writeProject(&b, e.Project)
+		fmt.Fprintf(&b, "\nPassed: <b>%d</b>, failed: <b>%d</b>, skipped: <b>%d</b>", s.Passed, s.Failed, s.Skipped)
+		if s.Blocked > 0 {
+			fmt.Fprintf(&b, ", blocked: <b>%d</b>", s.Blocked)
+		}
+		if s.Total > 0 {
+			fmt.Fprintf(&b, " of %d", s.Total)
+		}
+		writeLink(&b, e.TestRun.URL)
+	case strings.EqualFold(e.Type, EventAutoTestResultCreated) && e.TestResult != nil:
+		r := e.TestResult
+		icon := "✅"
+		if !strings.EqualFold(r.Outcome, "Passed") {
+			icon = "❌"
+		}
+		fmt.Fprintf(&b, "%s Autotest <b>%s</b>: %s", icon, html.EscapeString(r.AutoTestName), html.EscapeString(r.Outcome))
+		writeProject(&b, e.Project)
+		if r.TestRunName != "" {
+			fmt.Fprintf(&b, "\nTest run: %s", html.EscapeString(r.TestRunName))
+		}
+		if r.Message != "" {
+			fmt.Fprintf(&b, "\n<pre>%s</pre>", html.EscapeString(r.Message))
+		}
+		writeLink(&b, r.URL)
+	case (strings.EqualFold(e.Type, EventWorkItemCreated) ||
+		strings.EqualFold(e.Type, EventWorkItemUpdated) ||
+		strings.EqualFold(e.Type, EventWorkItemDeleted)) && e.WorkItem != nil:
+		w := e.WorkItem
+		kind := "Work item"
+		if w.Type != "" {
+			kind = w.Type
+		}
+		action := strings.ToLower(e.Type[len("WorkItem"):])
+		fmt.Fprintf(&b, "📝 %s <b>%s</b>", html.EscapeString(kind), html.EscapeString(w.Name))
+		if w.GlobalID != 0 {
+			fmt.Fprintf(&b, " (%d)", w.GlobalID)
+		}
+		fmt.Fprintf(&b, " %s", action)
+		writeProject(&b, e.Project)
+		writeUser(&b, e.User)
+		writeLink(&b, w.URL)
+	default:
+		return entities.Message{}, fmt.Errorf("%w: %s", errUnsupportedEvent, e.Type)
+	}
+
+	return entities.Message{
+		Text:      b.String(),
+		ParseMode: entities.HTML,
+		Data:      e.data(),
+		Metadata: entities.Metadata{
+			Project:   e.Project.Name,
+			EventType: e.Type,
+		},
+	}, nil
+}
+
+// data returns the fields of the event as they are sent by Test IT.
+func (e *Event) data() map[string]interface{} {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil
+	}
+
+	return data
+}
+
+func writeProject(b *strings.Builder, p Project) {
+	if p.Name != "" {
+		fmt.Fprintf(b, "\nProject: %s", html.EscapeString(p.Name))
+	}
+}
+
+func writeUser(b *strings.Builder, u *User) {
+	if u != nil && u.Name != "" {
+		fmt.Fprintf(b, "\nBy: %s", html.EscapeString(u.Name))
+	}
+}
+
+func writeLink(b *strings.Builder, url string) {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+		fmt.Fprintf(b, "\n<a href=\"%s\">Open in Test IT</a>", html.EscapeString(url))
+	}
+}
+
+// NewTestIT returns a new http.HandlerFunc that sends a Test IT webhook event using the provided sender.
+// It formats test run, autotest result and work item events as HTML messages for all chats of the company
+// and responds with the delivery report like the handler returned by New.
+// Events of other types are rejected with 400.
+// Requests without the Idempotency-Key header are keyed by the hash of the token and the body if hashRequests is set,
+// so retries of Test IT are not sent again.
+// Events larger than 1 MiB are rejected with 413.
+// It requires an Authorization token in the request header.
+func NewTestIT(log *slog.Logger, sender sender, hashRequests bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "transport.rest.send.NewTestIT"
+
+		log = log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		// Test IT retries webhooks without an idempotency key, only the hash of the body can recognize them
+		raw, ok := readRequest(log, w, r, maxEventSize, hashRequests)
+		if !ok {
+			return
+		}
+
+		var event Event
+
+		if err := render.DecodeJSON(bytes.NewReader(raw.body), &event); err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			handlers.NewErrorResponse(w, http.StatusBadRequest, "failed to decode request")
+			return
+		}
+
+		log.Debug("request body decoded", slog.Any("event", event))
+
+		if err := validator.New().Struct(event); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+
+			handlers.NewErrorResponse(w, http.StatusBadRequest, handlers.ValidationError(validateErr))
+
+			return
+		}
+
+		message, err := event.convertToDomain()
+		if err != nil {
+			log.Debug("unsupported event", sl.Err(err))
+
+			handlers.NewErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		message.Token = raw.token
+		message.Idempotency.Key = raw.key
+
+		log.Debug("event convert to message", slog.Any("message", message))
+
+		report, err := sender.SendMessage(r.Context(), message)
+		if err != nil {
+			log.Error("can not send message", sl.Err(err))
+
+			newSendError(w, err)
+			return
+		}
+
+		if report.Replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+
+		resp, status := newResponse(report)
+		handlers.NewJSONResponse(w, status, resp)
+	}
+}
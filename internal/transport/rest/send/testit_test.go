@@ -0,0 +1,230 @@
+package send
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/handlers"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/slogdiscard"
+	"github.com/testit-tms/webhook-bot/internal/transport/rest/send/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestEvent_convertToDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test run started",
+			event: Event{
+				Type:    EventTestRunStarted,
+				Project: Project{Name: "Web <UI>"},
+				TestRun: &TestRun{Name: "Nightly", URL: "https://testit.software/runs/1"},
+				User:    &User{Name: "Ann"},
+			},
+			want: "▶️ Test run <b>Nightly</b> started\nProject: Web &lt;UI&gt;\nBy: Ann\n" +
+				"<a href=\"https://testit.software/runs/1\">Open in Test IT</a>",
+		},
+		{
+			name: "test run completed with failures",
+			event: Event{
+				Type:    "testruncompleted",
+				Project: Project{Name: "API"},
+				TestRun: &TestRun{
+					Name:       "Nightly",
+					URL:        "https://testit.software/runs/1?a=1&b=2",
+					Statistics: Statistics{Total: 15, Passed: 12, Failed: 1, Skipped: 1, Blocked: 1},
+				},
+			},
+			want: "❌ Test run <b>Nightly</b> completed\nProject: API\n" +
+				"Passed: <b>12</b>, failed: <b>1</b>, skipped: <b>1</b>, blocked: <b>1</b> of 15\n" +
+				"<a href=\"https://testit.software/runs/1?a=1&amp;b=2\">Open in Test IT</a>",
+		},
+		{
+			name: "test run completed without link",
+			event: Event{
+				Type:    EventTestRunCompleted,
+				TestRun: &TestRun{Name: "Nightly", URL: "javascript:alert(1)", Statistics: Statistics{Passed: 3}},
+			},
+			want: "✅ Test run <b>Nightly</b> completed\nPassed: <b>3</b>, failed: <b>0</b>, skipped: <b>0</b>",
+		},
+		{
+			name: "autotest result",
+			event: Event{
+				Type:       EventAutoTestResultCreated,
+				TestResult: &TestResult{AutoTestName: "Login", Outcome: "Failed", Message: "expected <nil>", TestRunName: "Nightly"},
+			},
+			want: "❌ Autotest <b>Login</b>: Failed\nTest run: Nightly\n<pre>expected &lt;nil&gt;</pre>",
+		},
+		{
+			name: "work item updated",
+			event: Event{
+				Type:     EventWorkItemUpdated,
+				Project:  Project{Name: "API"},
+				WorkItem: &WorkItem{GlobalID: 42, Name: "Login works", Type: "TestCase"},
+				User:     &User{Name: "Ann"},
+			},
+			want: "📝 TestCase <b>Login works</b> (42) updated\nProject: API\nBy: Ann",
+		},
+		{
+			name:    "event without its object",
+			event:   Event{Type: EventTestRunStarted},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported event",
+			event:   Event{Type: "ProjectCreated"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.event.convertToDomain()
+			if tt.wantErr {
+				require.ErrorIs(t, err, errUnsupportedEvent)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, entities.HTML, got.ParseMode)
+			require.Equal(t, tt.want, got.Text)
+			require.Equal(t, entities.Metadata{Project: tt.event.Project.Name, EventType: tt.event.Type}, got.Metadata)
+			require.Equal(t, tt.event.Type, got.Data["eventType"])
+		})
+	}
+}
+
+func TestNewTestIT(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		body         string
+		hashRequests bool
+		respCode     int
+		respError    string
+		mockTimes    int
+	}{
+		{
+			name:      "Success",
+			token:     "token",
+			body:      `{"eventType": "TestRunStarted", "testRun": {"name": "Nightly"}}`,
+			respCode:  http.StatusOK,
+			mockTimes: 1,
+		},
+		{
+			name:         "Success with hashed request",
+			token:        "token",
+			body:         `{"eventType": "TestRunStarted", "testRun": {"name": "Nightly"}}`,
+			hashRequests: true,
+			respCode:     http.StatusOK,
+			mockTimes:    1,
+		},
+		{
+			name:      "too large",
+			token:     "token",
+			body:      `{"eventType": "TestRunStarted", "testRun": {"name": "` + strings.Repeat("a", maxEventSize) + `"}}`,
+			respCode:  http.StatusRequestEntityTooLarge,
+			respError: "request is too large",
+		},
+		{
+			name:      "empty token",
+			body:      `{"eventType": "TestRunStarted", "testRun": {"name": "Nightly"}}`,
+			respCode:  http.StatusUnauthorized,
+			respError: "token is required",
+		},
+		{
+			name:      "without event type",
+			token:     "token",
+			body:      `{"testRun": {"name": "Nightly"}}`,
+			respCode:  http.StatusBadRequest,
+			respError: "field Type is a required field",
+		},
+		{
+			name:      "unsupported event",
+			token:     "token",
+			body:      `{"eventType": "ProjectCreated"}`,
+			respCode:  http.StatusBadRequest,
+			respError: "unsupported event: ProjectCreated",
+		},
+		{
+			name:      "invalid json",
+			token:     "token",
+			body:      `{"eventType": `,
+			respCode:  http.StatusBadRequest,
+			respError: "failed to decode request",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			senderMock := mocks.NewMocksender(mockCtrl)
+
+			mes := entities.Message{
+				Text:      "▶️ Test run <b>Nightly</b> started",
+				ParseMode: entities.HTML,
+				Token:     "token",
+				Data: map[string]interface{}{
+					"eventType": "TestRunStarted",
+					"project":   map[string]interface{}{"id": "", "name": ""},
+					"testRun": map[string]interface{}{
+						"id":   "",
+						"name": "Nightly",
+						"url":  "",
+						"statistics": map[string]interface{}{
+							"total": float64(0), "passed": float64(0), "failed": float64(0), "skipped": float64(0), "blocked": float64(0),
+						},
+					},
+				},
+				Metadata: entities.Metadata{EventType: "TestRunStarted"},
+			}
+			if tc.hashRequests {
+				mes.Idempotency.Key = requestHash(tc.token, []byte(tc.body))
+			}
+			report := entities.SendReport{
+				MessageID: 7,
+				Results:   []entities.SendResult{{ChatID: 12345, Status: entities.SendStatusSent, MessageID: 55}},
+			}
+
+			senderMock.EXPECT().SendMessage(gomock.Any(), mes).Return(report, nil).Times(tc.mockTimes)
+
+			handler := NewTestIT(slogdiscard.NewDiscardLogger(), senderMock, tc.hashRequests)
+
+			req, err := http.NewRequest(http.MethodPost, "/telegram/testit", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.respCode, rr.Code)
+
+			if tc.respCode == http.StatusOK {
+				var resp Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+				require.Equal(t, Response{ID: 7, Chats: []ChatResponse{{ChatID: 12345, Status: "sent", MessageID: 55}}}, resp)
+				return
+			}
+
+			var resp handlers.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			require.Equal(t, tc.respError, resp.Message)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+)
+
+var (
+	// errChannelNotFound is returned when there is no public chat with the username.
+	errChannelNotFound = errors.New("channel not found")
+	// errCannotPost is returned when the bot is not allowed to post to the chat.
+	errCannotPost = errors.New("bot can not post to channel")
+	// errNotAdmin is returned when the user who sent the command is not an administrator of the chat.
+	errNotAdmin = errors.New("user is not an administrator of channel")
+
+	channelUsername = regexp.MustCompile(`^@([a-zA-Z][a-zA-Z0-9_]{3,31})$`)
+)
+
+// addChannel adds the public channel or group with the username from the arguments of the /addchat command.
+func (b *TelegramBot) addChannel(u update, username string) {
+	var userID int64
+	if u.Message.From != nil {
+		userID = u.Message.From.ID
+	}
+
+	chat, err := b.getChannel(username, userID)
+	if err != nil {
+		text := "Something went wrong. Lets try again"
+		switch {
+		case errors.Is(err, errChannelNotFound):
+			text = fmt.Sprintf("Channel @%s not found", username)
+		case errors.Is(err, errCannotPost):
+			text = fmt.Sprintf("Add the bot to @%s as an administrator allowed to post messages and try again", username)
+		case errors.Is(err, errNotAdmin):
+			text = fmt.Sprintf("Only an administrator of @%s can add it", username)
+		default:
+			b.logger.Error("cannot get channel", sl.Err(err))
+		}
+		b.reply(u, tgbotapi.NewMessage(u.Message.Chat.ID, text))
+		return
+	}
+
+	msg, err := b.chc.AddChannel(u.Message, chat)
+	if err != nil {
+		b.logger.Error("cannot add channel", sl.Err(err))
+	}
+	b.reply(u, msg)
+}
+
+// getChannel resolves the username of a public chat with getChat and checks that the bot can post to it:
+// the bot must be an administrator allowed to post messages in a channel and a member allowed to send messages in a group.
+// The user who sent the command must be the creator or an administrator of the chat, so nobody can add a chat they do not manage.
+func (b *TelegramBot) getChannel(username string, userID int64) (entities.Chat, error) {
+	const op = "telegram.getChannel"
+
+	chat, err := b.bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{SuperGroupUsername: "@" + username},
+	})
+	if err != nil {
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest {
+			return entities.Chat{}, fmt.Errorf("%s: %w", op, errChannelNotFound)
+		}
+		return entities.Chat{}, fmt.Errorf("%s: get chat: %w", op, err)
+	}
+
+	member, err := b.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: b.bot.Self.ID},
+	})
+	if err != nil {
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest {
+			return entities.Chat{}, fmt.Errorf("%s: %w", op, errCannotPost)
+		}
+		return entities.Chat{}, fmt.Errorf("%s: get chat member: %w", op, err)
+	}
+
+	if !canPost(chat, member) {
+		return entities.Chat{}, fmt.Errorf("%s: %w", op, errCannotPost)
+	}
+
+	if userID == 0 {
+		return entities.Chat{}, fmt.Errorf("%s: %w", op, errNotAdmin)
+	}
+
+	user, err := b.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: userID},
+	})
+	if err != nil {
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest {
+			return entities.Chat{}, fmt.Errorf("%s: %w", op, errNotAdmin)
+		}
+		return entities.Chat{}, fmt.Errorf("%s: get chat member: %w", op, err)
+	}
+
+	if !user.IsCreator() && !user.IsAdministrator() {
+		return entities.Chat{}, fmt.Errorf("%s: %w", op, errNotAdmin)
+	}
+
+	return entities.Chat{
+		TelegramID: chat.ID,
+		Username:   chat.UserName,
+	}, nil
+}
+
+// canPost reports whether the member can post messages to the chat.
+func canPost(chat tgbotapi.Chat, member tgbotapi.ChatMember) bool {
+	if chat.IsChannel() {
+		return member.IsCreator() || (member.IsAdministrator() && member.CanPostMessages)
+	}
+
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.CanSendMessages
+	default:
+		return false
+	}
+}
+
+// commandUsername returns the username of the chat if the arguments of the command are a single @username.
+func commandUsername(m *tgbotapi.Message) (string, bool) {
+	match := channelUsername.FindStringSubmatch(strings.TrimSpace(m.CommandArguments()))
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
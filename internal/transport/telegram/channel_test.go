@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+// apiClient answers the Telegram API requests with the result of the method,
+// getChatMember is answered with the status of the requested user.
+type apiClient struct {
+	chat    string
+	members map[string]string
+}
+
+func (c apiClient) Do(req *http.Request) (*http.Response, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	result := c.chat
+	if path.Base(req.URL.Path) == "getChatMember" {
+		result = fmt.Sprintf(`{"status":%q,"can_post_messages":true}`, c.members[req.PostForm.Get("user_id")])
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":` + result + `}`)),
+	}, nil
+}
+
+func TestTelegramBot_getChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		members map[string]string
+		want    entities.Chat
+		wantErr error
+	}{
+		{
+			name:    "administrator",
+			members: map[string]string{"1": "administrator", "42": "administrator"},
+			want:    entities.Chat{TelegramID: -100123, Username: "news"},
+		},
+		{
+			name:    "creator",
+			members: map[string]string{"1": "administrator", "42": "creator"},
+			want:    entities.Chat{TelegramID: -100123, Username: "news"},
+		},
+		{
+			name:    "user is not administrator",
+			members: map[string]string{"1": "administrator", "42": "member"},
+			wantErr: errNotAdmin,
+		},
+		{
+			name:    "user left",
+			members: map[string]string{"1": "administrator", "42": "left"},
+			wantErr: errNotAdmin,
+		},
+		{
+			name:    "bot can not post",
+			members: map[string]string{"1": "member", "42": "creator"},
+			wantErr: errCannotPost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &tgbotapi.BotAPI{
+				Token:  "token",
+				Client: apiClient{chat: `{"id":-100123,"type":"channel","username":"news"}`, members: tt.members},
+				Self:   tgbotapi.User{ID: 1},
+			}
+			api.SetAPIEndpoint(tgbotapi.APIEndpoint)
+
+			b := &TelegramBot{bot: api}
+
+			got, err := b.getChannel("news", 42)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
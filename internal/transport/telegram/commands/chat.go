@@ -2,16 +2,23 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
 )
 
 type chatUsecases interface {
 	AddChat(ctx context.Context, chat entities.Chat) (entities.Chat, error)
 	DeleteChatByTelegramId(ctx context.Context, ownerId, chatId int64) error
+	SetQuietHours(ctx context.Context, ownerId, chatId int64, q entities.QuietHours) error
+	SetDigestWindow(ctx context.Context, ownerId, chatId int64, window time.Duration) error
+	SetChatAlias(ctx context.Context, ownerId, chatId int64, alias string) error
 }
 
 type chatCommands struct {
@@ -28,19 +35,39 @@ func NewChatCommands(cu chatUsecases, compu companyUsesaces) *chatCommands {
 }
 
 // AddChat adds a new chat to the company with the owner's Telegram ID.
-// It takes a Telegram message as input and extracts the chat ID from the command arguments.
-// If the chat ID is not a valid integer, it returns an error.
+// It takes a Telegram message as input and extracts the chat ID and the optional forum topic ID from the command arguments.
+// Without arguments in a group it adds the group itself with the forum topic the command is sent to.
+// If the chat ID or the topic ID is not a valid integer, it returns an error.
 // It then retrieves the company associated with the owner's Telegram ID and adds the chat to the company.
 // If there is an error while adding the chat, it returns an error.
 // Otherwise, it returns a success message.
-func (c *chatCommands) AddChat(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+func (c *chatCommands) AddChat(m *tgbotapi.Message, threadID int) (tgbotapi.MessageConfig, error) {
 	const op = "chatCommands.AddChat"
 
-	args := m.CommandArguments()
-	chatID, err := strconv.Atoi(args)
-	if err != nil {
+	var chatID int64
+	args := strings.Fields(m.CommandArguments())
+	switch {
+	case len(args) == 0 && !m.Chat.IsPrivate():
+		chatID = m.Chat.ID
+	case len(args) == 1 || len(args) == 2:
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return tgbotapi.NewMessage(m.Chat.ID, "Wrong chat id"),
+				fmt.Errorf("%s: convert chat id: %w", op, err)
+		}
+		chatID = id
+		threadID = 0
+
+		if len(args) == 2 {
+			threadID, err = strconv.Atoi(args[1])
+			if err != nil || threadID < 0 {
+				return tgbotapi.NewMessage(m.Chat.ID, "Wrong topic id"),
+					fmt.Errorf("%s: convert topic id: %w", op, err)
+			}
+		}
+	default:
 		return tgbotapi.NewMessage(m.Chat.ID, "Wrong chat id"),
-			fmt.Errorf("%s: convert chat id: %w", op, err)
+			fmt.Errorf("%s: wrong arguments: %q", op, m.CommandArguments())
 	}
 
 	company, err := c.compu.GetCompanyByOwnerTelegramId(context.Background(), m.From.ID)
@@ -50,17 +77,56 @@ func (c *chatCommands) AddChat(m *tgbotapi.Message) (tgbotapi.MessageConfig, err
 	}
 
 	_, err = c.cu.AddChat(context.Background(), entities.Chat{
-		CompanyID:  company.ID,
-		TelegramID: int64(chatID),
+		CompanyID:       company.ID,
+		TelegramID:      chatID,
+		MessageThreadID: threadID,
 	})
 	if err != nil {
+		if errors.Is(err, usecases.ErrChatAlreadyAdded) {
+			return tgbotapi.NewMessage(m.Chat.ID, chatAddedText(chatID)), nil
+		}
 		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
 			fmt.Errorf("%s: add chat: %w", op, err)
 	}
 
+	if threadID != 0 {
+		return tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Chat added, messages will be sent to topic %d", threadID)), nil
+	}
+
 	return tgbotapi.NewMessage(m.Chat.ID, "Chat added"), nil
 }
 
+// AddChannel adds the public channel or group resolved from its username to the company with the owner's Telegram ID.
+// The chat is stored with its numeric ID, the username is kept for display.
+// If there is an error while adding the chat, it returns an error.
+func (c *chatCommands) AddChannel(m *tgbotapi.Message, chat entities.Chat) (tgbotapi.MessageConfig, error) {
+	const op = "chatCommands.AddChannel"
+
+	company, err := c.compu.GetCompanyByOwnerTelegramId(context.Background(), m.From.ID)
+	if err != nil {
+		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
+			fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	chat.CompanyID = company.ID
+
+	if _, err := c.cu.AddChat(context.Background(), chat); err != nil {
+		if errors.Is(err, usecases.ErrChatAlreadyAdded) {
+			return tgbotapi.NewMessage(m.Chat.ID, chatAddedText(chat.TelegramID)), nil
+		}
+		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
+			fmt.Errorf("%s: add chat: %w", op, err)
+	}
+
+	return tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Channel @%s added with chat id %d", chat.Username, chat.TelegramID)), nil
+}
+
+// chatAddedText explains that the chat can not be added again, messages are sent to one topic of a chat only.
+func chatAddedText(chatID int64) string {
+	return fmt.Sprintf("The chat is already added to the company, messages are sent to one topic of a chat only. "+
+		"To change the topic delete the chat with /deletechat %d and add it again", chatID)
+}
+
 // DeleteChat deletes a chat by its ID. It takes a Telegram message as input and extracts the chat ID from the command arguments.
 // It then calls the DeleteChatByTelegramId method of the ChatUseCase to delete the chat from the database.
 // If the chat ID is not a valid integer, it returns an error and a message to the user.
@@ -83,3 +149,194 @@ func (c *chatCommands) DeleteChat(m *tgbotapi.Message) (tgbotapi.MessageConfig,
 
 	return tgbotapi.NewMessage(m.Chat.ID, "Chat deleted"), nil
 }
+
+// SetQuietHours sets the quiet hours of a chat of the company with the owner's Telegram ID.
+// The command arguments are the chat ID, the hours like 22:00-08:00 and the optional time zone like Europe/Berlin,
+// UTC is used by default. The chat ID may be omitted in a group to set the quiet hours of the group itself.
+// The argument "off" instead of the hours turns the quiet hours off.
+// If the arguments are not valid or the chat is not found, it returns an error and a message to the user.
+func (c *chatCommands) SetQuietHours(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "chatCommands.SetQuietHours"
+
+	args := strings.Fields(m.CommandArguments())
+
+	chatID, args, ok := commandChatID(m, args)
+	if !ok {
+		return tgbotapi.NewMessage(m.Chat.ID, "Wrong chat id"),
+			fmt.Errorf("%s: wrong arguments: %q", op, m.CommandArguments())
+	}
+
+	q, err := parseQuietHours(args)
+	if err != nil {
+		return tgbotapi.NewMessage(m.Chat.ID, "Wrong quiet hours, for example: /quiethours 123456789 22:00-08:00 Europe/Berlin"),
+			fmt.Errorf("%s: parse quiet hours: %w", op, err)
+	}
+
+	if err := c.cu.SetQuietHours(context.Background(), m.From.ID, chatID, q); err != nil {
+		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
+			fmt.Errorf("%s: set quiet hours: %w", op, err)
+	}
+
+	if !q.IsQuiet() {
+		return tgbotapi.NewMessage(m.Chat.ID, "Quiet hours turned off"), nil
+	}
+
+	return tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Quiet hours set to %s, urgent messages are still sent right away", q)), nil
+}
+
+// SetDigest sets the digest window of a chat of the company with the owner's Telegram ID.
+// The command arguments are the chat ID and the window like 5m, the chat ID may be omitted in a group
+// to set the window of the group itself. The argument "off" instead of the window turns the digest off.
+// If the arguments are not valid or the chat is not found, it returns an error and a message to the user.
+func (c *chatCommands) SetDigest(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "chatCommands.SetDigest"
+
+	args := strings.Fields(m.CommandArguments())
+
+	chatID, args, ok := commandChatID(m, args)
+	if !ok {
+		return tgbotapi.NewMessage(m.Chat.ID, "Wrong chat id"),
+			fmt.Errorf("%s: wrong arguments: %q", op, m.CommandArguments())
+	}
+
+	window, err := parseWindow(args, 24*time.Hour)
+	if err != nil {
+		return tgbotapi.NewMessage(m.Chat.ID, "Wrong digest window, it must be from 1m to 24h, for example: /digest 123456789 5m"),
+			fmt.Errorf("%s: parse digest window: %w", op, err)
+	}
+
+	if err := c.cu.SetDigestWindow(context.Background(), m.From.ID, chatID, window); err != nil {
+		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
+			fmt.Errorf("%s: set digest window: %w", op, err)
+	}
+
+	if window == 0 {
+		return tgbotapi.NewMessage(m.Chat.ID, "Digest turned off"), nil
+	}
+
+	return tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Messages are collected into a digest for %s, urgent messages are still sent right away", window)), nil
+}
+
+// SetAlias sets the alias of a chat of the company with the owner's Telegram ID, the messages can be sent to the chat by it.
+// The command arguments are the chat ID and the alias like qa-team, the chat ID may be omitted in a group
+// to set the alias of the group itself. The argument "off" instead of the alias removes it.
+// If the arguments are not valid or the chat is not found, it returns an error and a message to the user.
+func (c *chatCommands) SetAlias(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "chatCommands.SetAlias"
+
+	args := strings.Fields(m.CommandArguments())
+
+	chatID, args, ok := commandChatID(m, args)
+	if !ok || len(args) != 1 {
+		return tgbotapi.NewMessage(m.Chat.ID, "Wrong arguments, for example: /alias 123456789 qa-team"),
+			fmt.Errorf("%s: wrong arguments: %q", op, m.CommandArguments())
+	}
+
+	alias := args[0]
+	if strings.EqualFold(alias, "off") {
+		alias = ""
+	}
+
+	if err := c.cu.SetChatAlias(context.Background(), m.From.ID, chatID, alias); err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrChatAliasInvalid):
+			return tgbotapi.NewMessage(m.Chat.ID, "Wrong alias, it must start with a letter and contain only letters, digits, '_', '.' and '-'"), nil
+		case errors.Is(err, usecases.ErrChatAliasTaken):
+			return tgbotapi.NewMessage(m.Chat.ID, "Another chat of the company already has this alias"), nil
+		}
+		return tgbotapi.NewMessage(m.Chat.ID, "Something went wrong. Lets try again"),
+			fmt.Errorf("%s: set chat alias: %w", op, err)
+	}
+
+	if alias == "" {
+		return tgbotapi.NewMessage(m.Chat.ID, "Alias removed"), nil
+	}
+
+	return tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Messages can be sent to the chat with \"chats\": [\"%s\"]", strings.ToLower(alias))), nil
+}
+
+// commandChatID returns the chat ID from the first command argument and the rest of the arguments.
+// If the first argument is not a chat ID, the chat of the message is used unless it is a private chat.
+func commandChatID(m *tgbotapi.Message, args []string) (int64, []string, bool) {
+	if len(args) > 0 {
+		if id, err := strconv.ParseInt(args[0], 10, 64); err == nil && id != 0 {
+			return id, args[1:], true
+		}
+	}
+
+	if m.Chat.IsPrivate() {
+		return 0, args, false
+	}
+
+	return m.Chat.ID, args, true
+}
+
+// parseWindow parses the window like 5m or "off", which means a zero window.
+// The window must be from a minute to max.
+func parseWindow(args []string, max time.Duration) (time.Duration, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("wrong number of arguments: %d", len(args))
+	}
+
+	if strings.EqualFold(args[0], "off") {
+		return 0, nil
+	}
+
+	window, err := time.ParseDuration(args[0])
+	if err != nil {
+		return 0, err
+	}
+	if window < time.Minute || window > max {
+		return 0, fmt.Errorf("window out of range: %s", window)
+	}
+
+	return window, nil
+}
+
+// parseQuietHours parses the quiet hours like 22:00-08:00 with the optional time zone or "off".
+func parseQuietHours(args []string) (entities.QuietHours, error) {
+	q := entities.QuietHours{TimeZone: "UTC"}
+
+	if len(args) == 1 && strings.EqualFold(args[0], "off") {
+		return q, nil
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		return q, fmt.Errorf("wrong number of arguments: %d", len(args))
+	}
+
+	start, end, ok := strings.Cut(args[0], "-")
+	if !ok {
+		return q, fmt.Errorf("wrong hours: %q", args[0])
+	}
+
+	var err error
+	if q.Start, err = parseMinutes(start); err != nil {
+		return q, err
+	}
+	if q.End, err = parseMinutes(end); err != nil {
+		return q, err
+	}
+	if q.Start == q.End {
+		return q, fmt.Errorf("empty hours: %q", args[0])
+	}
+
+	if len(args) == 2 {
+		if _, err := time.LoadLocation(args[1]); err != nil {
+			return q, err
+		}
+		q.TimeZone = args[1]
+	}
+
+	return q, nil
+}
+
+// parseMinutes parses the time of day like 22:00 to minutes after midnight.
+func parseMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/testit-tms/webhook-bot/internal/entities"
@@ -14,6 +16,7 @@ type companyUsesaces interface {
 	GetCompanyByOwnerTelegramId(ctx context.Context, ownerId int64) (entities.CompanyInfo, error)
 	UpdateToken(ctx context.Context, ownerId int64) error
 	DeleteCompany(ctx context.Context, ownerId int64) error
+	SetDedupWindow(ctx context.Context, ownerId int64, window time.Duration) error
 }
 
 // CompanyCommands represents a set of commands related to companies.
@@ -64,6 +67,9 @@ func (c *CompanyCommands) GetMyCompanies(m *tgbotapi.Message) (tgbotapi.MessageC
 		msg.Text += "\n<b>Chats:</b>"
 		for _, chatId := range company.ChatIds {
 			msg.Text += fmt.Sprintf("\n%d", chatId)
+			if username, ok := company.Usernames[chatId]; ok {
+				msg.Text += fmt.Sprintf(" (@%s)", username)
+			}
 		}
 	}
 
@@ -123,3 +129,41 @@ func (c *CompanyCommands) DeleteCompany(m *tgbotapi.Message) (tgbotapi.MessageCo
 	msg.Text = "Company deleted successfully"
 	return msg, nil
 }
+
+// SetDedup sets the window duplicates of a message sent to a chat of the company owned by the user are suppressed within.
+// The command argument is the window like 10m, up to a week, or "off" to turn the deduplication off.
+// If the argument is not valid or the user does not own any companies, a message about it is returned.
+func (c *CompanyCommands) SetDedup(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "CompanyCommands.SetDedup"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	window, err := parseWindow(strings.Fields(m.CommandArguments()), 7*24*time.Hour)
+	if err != nil {
+		msg.Text = "Wrong window, it must be from 1m to 168h, for example: /dedup 30m"
+		return msg, fmt.Errorf("%s: parse window: %w", op, err)
+	}
+
+	err = c.cu.SetDedupWindow(context.Background(), m.From.ID, window)
+	if err != nil {
+		if errors.Is(err, usecases.ErrCompanyNotFound) {
+			msg.Text = `
+			<b>You have no companies</b>
+	
+			You can register new company with <b>/register</b> command
+			`
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: set dedup window: %w", op, err)
+	}
+
+	if window == 0 {
+		msg.Text = "Duplicates are not suppressed anymore"
+		return msg, nil
+	}
+
+	msg.Text = fmt.Sprintf("Duplicates of messages sent to a chat within %s are suppressed", window)
+	return msg, nil
+}
@@ -17,6 +17,22 @@ func GetHelpMessage(m *tgbotapi.Message) tgbotapi.MessageConfig {
 	/deletecompany - delete company
 	/updatetoken - update company token
 	/addchat {chat_id} - add chat to company, for example: /addchat 123456789
+	/addchat {chat_id} {topic_id} - add forum topic of chat to company, or send /addchat inside the topic, a chat can have one topic
+	/addchat @{username} - add public channel to company, the bot must be its administrator allowed to post messages
 	/deletechat {chat_id} - delete chat from company, for example: /deletechat 123456789
+	/alias {chat_id} {alias} - send messages to chat by alias instead of chat ID, for example: /alias 123456789 qa-team
+	/alias {chat_id} off - remove chat alias
+	/quiethours {chat_id} {from}-{to} {time_zone} - hold non-urgent messages during quiet hours, for example: /quiethours 123456789 22:00-08:00 Europe/Berlin
+	/quiethours {chat_id} off - turn quiet hours off
+	/digest {chat_id} {window} - collect messages into one digest per window, for example: /digest 123456789 5m
+	/digest {chat_id} off - turn digest off
+	/dedup {window} - suppress duplicates of messages sent to a chat within the window, for example: /dedup 30m
+	/dedup off - turn duplicate suppression off
+	/settemplate {name} {parse_mode} - add or replace message template, the template goes on the next lines
+	/templates - show company templates
+	/deletetemplate {name} - delete message template, for example: /deletetemplate run-finished
+	/setroute {name} {chat_ids} {field=value}... - send messages matching all conditions only to the chats, for example: /setroute backend -100123,-100456 project=Backend severity=critical
+	/routes - show company routes
+	/deleteroute {name} - delete route, for example: /deleteroute backend
 	`)
 }
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
+)
+
+type routeUsecases interface {
+	SetRoute(ctx context.Context, ownerId int64, r entities.Route) (entities.Route, error)
+	GetRoutes(ctx context.Context, ownerId int64) ([]entities.Route, error)
+	DeleteRoute(ctx context.Context, ownerId int64, name string) error
+}
+
+type routeCommands struct {
+	ru routeUsecases
+}
+
+// NewRouteCommands returns a new instance of routeCommands with the provided routeUsecases.
+func NewRouteCommands(ru routeUsecases) *routeCommands {
+	return &routeCommands{
+		ru: ru,
+	}
+}
+
+// SetRoute adds a routing rule to the company of the owner or replaces the rule with the same name.
+// The command arguments are the name, the comma-separated chat IDs and the conditions like project=Backend,
+// values with spaces are put in double quotes like project="Web UI".
+func (c *routeCommands) SetRoute(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "routeCommands.SetRoute"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	route, ok := parseRoute(m.CommandArguments())
+	if !ok {
+		msg.Text = "Send the rule name, the chat IDs and the conditions, for example:\n" +
+			"<code>/setroute backend -100123,-100456 project=Backend severity=critical</code>\n" +
+			"Conditions match project, eventType, severity, tag or data.{path} fields, * matches any characters"
+		return msg, nil
+	}
+
+	route, err := c.ru.SetRoute(context.Background(), m.From.ID, route)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrCompanyNotFound):
+			msg.Text = noCompanyText
+			return msg, nil
+		case errors.Is(err, usecases.ErrRouteInvalid):
+			text := err.Error()
+			if i := strings.Index(text, usecases.ErrRouteInvalid.Error()); i >= 0 {
+				text = text[i:]
+			}
+			msg.Text = html.EscapeString(text)
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: set route: %w", op, err)
+	}
+
+	msg.Text = fmt.Sprintf("Route <b>%s</b> saved", html.EscapeString(route.Name))
+	return msg, nil
+}
+
+// GetRoutes returns a message listing the routing rules of the company of the owner.
+func (c *routeCommands) GetRoutes(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "routeCommands.GetRoutes"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	routes, err := c.ru.GetRoutes(context.Background(), m.From.ID)
+	if err != nil {
+		if errors.Is(err, usecases.ErrCompanyNotFound) {
+			msg.Text = noCompanyText
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: get routes: %w", op, err)
+	}
+
+	if len(routes) == 0 {
+		msg.Text = "You have no routes, messages are sent to all chats. You can add one with <b>/setroute</b> command"
+		return msg, nil
+	}
+
+	msg.Text = "<b>Routes:</b>"
+	for _, r := range routes {
+		conditions := make([]string, 0, len(r.Conditions))
+		for _, cond := range r.Conditions {
+			conditions = append(conditions, cond.String())
+		}
+		chats := make([]string, 0, len(r.ChatIds))
+		for _, id := range r.ChatIds {
+			chats = append(chats, strconv.FormatInt(id, 10))
+		}
+		msg.Text += fmt.Sprintf("\n%s: <i>%s</i> → %s",
+			html.EscapeString(r.Name), html.EscapeString(strings.Join(conditions, " ")), strings.Join(chats, ", "))
+	}
+
+	return msg, nil
+}
+
+// DeleteRoute deletes the routing rule with the name from the command arguments from the company of the owner.
+func (c *routeCommands) DeleteRoute(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "routeCommands.DeleteRoute"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	name := strings.TrimSpace(m.CommandArguments())
+	if name == "" {
+		msg.Text = "Wrong route name"
+		return msg, nil
+	}
+
+	if err := c.ru.DeleteRoute(context.Background(), m.From.ID, name); err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrCompanyNotFound):
+			msg.Text = noCompanyText
+			return msg, nil
+		case errors.Is(err, usecases.ErrRouteNotFound):
+			msg.Text = "Route not found"
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: delete route: %w", op, err)
+	}
+
+	msg.Text = "Route deleted"
+	return msg, nil
+}
+
+// parseRoute parses the name, the comma-separated chat IDs and the field=value conditions of a routing rule.
+func parseRoute(s string) (entities.Route, bool) {
+	args, ok := splitQuoted(s)
+	if !ok || len(args) < 3 {
+		return entities.Route{}, false
+	}
+
+	route := entities.Route{Name: args[0]}
+
+	for _, id := range strings.Split(args[1], ",") {
+		chatID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			return entities.Route{}, false
+		}
+		route.ChatIds = append(route.ChatIds, chatID)
+	}
+
+	for _, arg := range args[2:] {
+		field, value, found := strings.Cut(arg, "=")
+		if !found || field == "" || value == "" {
+			return entities.Route{}, false
+		}
+		route.Conditions = append(route.Conditions, entities.RouteCondition{Field: field, Value: value})
+	}
+
+	return route, true
+}
+
+// splitQuoted splits the string by spaces except the spaces in double quotes, the quotes are removed.
+// It reports false if a quote is not closed.
+func splitQuoted(s string) ([]string, bool) {
+	var args []string
+	var b strings.Builder
+	quoted, started := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if started {
+				args = append(args, b.String())
+				b.Reset()
+				started = false
+			}
+		default:
+			b.WriteRune(r)
+			started = true
+		}
+	}
+
+	if quoted {
+		return nil, false
+	}
+	if started {
+		args = append(args, b.String())
+	}
+
+	return args, true
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/usecases"
+)
+
+const noCompanyText = `
+			<b>You have no companies</b>
+	
+			You can register new company with <b>/register</b> command
+			`
+
+type templateUsecases interface {
+	SetTemplate(ctx context.Context, ownerId int64, t entities.Template) (entities.Template, error)
+	GetTemplates(ctx context.Context, ownerId int64) ([]entities.Template, error)
+	DeleteTemplate(ctx context.Context, ownerId int64, name string) error
+}
+
+type templateCommands struct {
+	tu templateUsecases
+}
+
+// NewTemplateCommands returns a new instance of templateCommands with the provided templateUsecases.
+func NewTemplateCommands(tu templateUsecases) *templateCommands {
+	return &templateCommands{
+		tu: tu,
+	}
+}
+
+// SetTemplate adds a message template to the company of the owner or replaces the template with the same name.
+// The first line of the command arguments holds the name and optionally the parse mode (html by default),
+// the following lines hold the body of the template.
+func (c *templateCommands) SetTemplate(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "templateCommands.SetTemplate"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	header, body, _ := strings.Cut(m.CommandArguments(), "\n")
+	args := strings.Fields(header)
+	if len(args) == 0 || len(args) > 2 || strings.TrimSpace(body) == "" {
+		msg.Text = "Send the template name and parse mode on the first line and the template on the next lines, for example:\n" +
+			"<code>/settemplate run-finished html\n&lt;b&gt;{{.project}}&lt;/b&gt; finished</code>"
+		return msg, nil
+	}
+
+	parseMode := entities.HTML
+	if len(args) == 2 {
+		parseMode = entities.ParseString(args[1])
+		if parseMode == entities.Undefined && !strings.EqualFold(args[1], "plain") {
+			msg.Text = "Wrong parse mode, use one of: html, markdownv2, markdown or plain"
+			return msg, nil
+		}
+	}
+
+	t, err := c.tu.SetTemplate(context.Background(), m.From.ID, entities.Template{
+		Name:      args[0],
+		ParseMode: parseMode,
+		Body:      body,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrCompanyNotFound):
+			msg.Text = noCompanyText
+			return msg, nil
+		case errors.Is(err, usecases.ErrTemplateInvalid):
+			text := err.Error()
+			if i := strings.Index(text, usecases.ErrTemplateInvalid.Error()); i >= 0 {
+				text = text[i:]
+			}
+			msg.Text = html.EscapeString(text)
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: set template: %w", op, err)
+	}
+
+	msg.Text = fmt.Sprintf("Template <b>%s</b> saved", html.EscapeString(t.Name))
+	return msg, nil
+}
+
+// GetTemplates returns a message listing the templates of the company of the owner.
+func (c *templateCommands) GetTemplates(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "templateCommands.GetTemplates"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	templates, err := c.tu.GetTemplates(context.Background(), m.From.ID)
+	if err != nil {
+		if errors.Is(err, usecases.ErrCompanyNotFound) {
+			msg.Text = noCompanyText
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: get templates: %w", op, err)
+	}
+
+	if len(templates) == 0 {
+		msg.Text = "You have no templates. You can add one with <b>/settemplate</b> command"
+		return msg, nil
+	}
+
+	msg.Text = "<b>Templates:</b>"
+	for _, t := range templates {
+		mode := t.ParseMode.String()
+		if mode == "" {
+			mode = "plain"
+		}
+		msg.Text += fmt.Sprintf("\n%s <i>(%s)</i>", html.EscapeString(t.Name), mode)
+	}
+
+	return msg, nil
+}
+
+// DeleteTemplate deletes the template with the name from the command arguments from the company of the owner.
+func (c *templateCommands) DeleteTemplate(m *tgbotapi.Message) (tgbotapi.MessageConfig, error) {
+	const op = "templateCommands.DeleteTemplate"
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	name := strings.TrimSpace(m.CommandArguments())
+	if name == "" {
+		msg.Text = "Wrong template name"
+		return msg, nil
+	}
+
+	if err := c.tu.DeleteTemplate(context.Background(), m.From.ID, name); err != nil {
+		switch {
+		case errors.Is(err, usecases.ErrCompanyNotFound):
+			msg.Text = noCompanyText
+			return msg, nil
+		case errors.Is(err, usecases.ErrTemplateNotFound):
+			msg.Text = "Template not found"
+			return msg, nil
+		}
+		msg.Text = "Something went wrong. Lets try again"
+		return msg, fmt.Errorf("%s: delete template: %w", op, err)
+	}
+
+	msg.Text = "Template deleted"
+	return msg, nil
+}
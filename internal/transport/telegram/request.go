@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+// messageParams returns the params of a Bot API method sending the message to the chat.
+// The webhook messages are sent with the params instead of the tgbotapi configs,
+// because the configs do not support all the options of the Bot API.
+func messageParams(chatID int64, msg entities.Message, markup interface{}) (tgbotapi.Params, error) {
+	params := make(tgbotapi.Params)
+
+	params.AddNonZero64("chat_id", chatID)
+	params.AddNonZero("message_thread_id", msg.ThreadIDs[chatID])
+	params.AddBool("disable_notification", msg.DisableNotification)
+	params.AddBool("protect_content", msg.ProtectContent)
+	if msg.ReplyToMessageID != 0 {
+		params.AddNonZero("reply_to_message_id", msg.ReplyToMessageID)
+		// the message is sent to chats where the replied message does not exist as well
+		params.AddBool("allow_sending_without_reply", true)
+	}
+
+	if err := params.AddInterface("reply_markup", markup); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// request calls the Bot API method like send and decodes its result into v.
+// Files that need to be uploaded are sent as multipart/form-data.
+func (b *TelegramBot) request(ctx context.Context, chatID int64, method string, params tgbotapi.Params, files []tgbotapi.RequestFile, v interface{}) error {
+	var resp *tgbotapi.APIResponse
+
+	err := b.do(ctx, chatID, func() (err error) {
+		if len(files) > 0 {
+			resp, err = b.bot.UploadFiles(method, params, files)
+		} else {
+			resp, err = b.bot.MakeRequest(method, params)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(resp.Result, v)
+}
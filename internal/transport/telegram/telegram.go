@@ -2,26 +2,44 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/testit-tms/webhook-bot/internal/entities"
 	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"github.com/testit-tms/webhook-bot/internal/lib/markdown"
+	"github.com/testit-tms/webhook-bot/internal/lib/split"
 	"github.com/testit-tms/webhook-bot/internal/transport/telegram/commands"
 	"golang.org/x/exp/slog"
 )
 
 const (
-	rigesterCommand    = "register"
-	getChatIdCommand   = "getchatid"
-	getCompanyCommand  = "getcompany"
-	addChatCommand     = "addchat"
-	helpCommand        = "help"
-	deleteChatCommand  = "deletechat"
-	startCommand       = "start"
-	updateTokenCommand = "updatetoken"
-	deleteCompany      = "deletecompany"
+	rigesterCommand       = "register"
+	getChatIdCommand      = "getchatid"
+	getCompanyCommand     = "getcompany"
+	addChatCommand        = "addchat"
+	helpCommand           = "help"
+	deleteChatCommand     = "deletechat"
+	startCommand          = "start"
+	updateTokenCommand    = "updatetoken"
+	deleteCompany         = "deletecompany"
+	setTemplateCommand    = "settemplate"
+	templatesCommand      = "templates"
+	deleteTemplateCommand = "deletetemplate"
+	quietHoursCommand     = "quiethours"
+	digestCommand         = "digest"
+	dedupCommand          = "dedup"
+	aliasCommand          = "alias"
+	setRouteCommand       = "setroute"
+	routesCommand         = "routes"
+	deleteRouteCommand    = "deleteroute"
 )
 
 type registrator interface {
@@ -33,25 +51,59 @@ type companyCommands interface {
 	GetMyCompanies(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
 	UpdateToken(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
 	DeleteCompany(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	SetDedup(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
 }
 
 type chatCommands interface {
-	AddChat(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	AddChat(m *tgbotapi.Message, threadID int) (tgbotapi.MessageConfig, error)
+	AddChannel(m *tgbotapi.Message, chat entities.Chat) (tgbotapi.MessageConfig, error)
 	DeleteChat(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	SetQuietHours(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	SetDigest(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	SetAlias(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+}
+
+type templateCommands interface {
+	SetTemplate(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	GetTemplates(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	DeleteTemplate(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+}
+
+type routeCommands interface {
+	SetRoute(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	GetRoutes(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+	DeleteRoute(m *tgbotapi.Message) (tgbotapi.MessageConfig, error)
+}
+
+// htmlTag matches the tags of the HTML parse mode.
+var htmlTag = regexp.MustCompile(`<[^<>]*>`)
+
+// maxMediaGroupSize is the maximum number of files Telegram sends in one album.
+const maxMediaGroupSize = 10
+
+// errRateLimited is returned when the message is not sent before the context is done because of rate limits.
+var errRateLimited = errors.New("rate limited")
+
+type limiter interface {
+	Wait(ctx context.Context, chatID int64) error
+	Pause(chatID int64, d time.Duration)
 }
 
 // TelegramBot represents a Telegram bot instance
 type TelegramBot struct {
 	logger           *slog.Logger
 	bot              *tgbotapi.BotAPI
+	limiter          limiter
 	waitConversation map[int64]Conversation
 	registrator      registrator
 	cc               companyCommands
 	chc              chatCommands
+	tc               templateCommands
+	rc               routeCommands
 }
 
 // New creates a new TelegramBot instance
-func New(logger *slog.Logger, token string, r registrator, cc companyCommands, chc chatCommands) (*TelegramBot, error) {
+func New(logger *slog.Logger, token string, l limiter, r registrator, cc companyCommands, chc chatCommands, tc templateCommands, rc routeCommands) (*TelegramBot, error) {
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, err
@@ -60,10 +112,13 @@ func New(logger *slog.Logger, token string, r registrator, cc companyCommands, c
 	return &TelegramBot{
 			logger:           logger,
 			bot:              bot,
+			limiter:          l,
 			waitConversation: make(map[int64]Conversation),
 			registrator:      r,
 			cc:               cc,
 			chc:              chc,
+			tc:               tc,
+			rc:               rc,
 		},
 		nil
 }
@@ -73,7 +128,7 @@ func (b *TelegramBot) Run() {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := b.bot.GetUpdatesChan(u)
+	updates := b.getUpdatesChan(u)
 
 	for update := range updates {
 		if update.Message == nil { // ignore any non-Message updates
@@ -93,7 +148,7 @@ func (b *TelegramBot) Run() {
 							step:               step,
 						}
 					}
-					b.sendMessage(msg)
+					b.reply(update, msg)
 				}
 			}
 			continue
@@ -126,7 +181,7 @@ func (b *TelegramBot) Run() {
 			if err != nil {
 				b.logger.Error("cannot get company", sl.Err(err))
 			}
-			b.sendMessage(msg)
+			b.reply(update, msg)
 			continue
 
 		case updateTokenCommand:
@@ -134,61 +189,687 @@ func (b *TelegramBot) Run() {
 			if err != nil {
 				b.logger.Error("cannot update token", sl.Err(err))
 			}
-			b.sendMessage(msg)
+			b.reply(update, msg)
 			continue
 		case addChatCommand:
-			msg, err := b.chc.AddChat(update.Message)
+			if username, ok := commandUsername(update.Message); ok {
+				b.addChannel(update, username)
+				continue
+			}
+			msg, err := b.chc.AddChat(update.Message, update.threadID)
 			if err != nil {
 				b.logger.Error("cannot add chat", sl.Err(err))
 			}
-			b.sendMessage(msg)
+			b.reply(update, msg)
 			continue
 		case deleteChatCommand:
 			msg, err := b.chc.DeleteChat(update.Message)
 			if err != nil {
 				b.logger.Error("cannot delete chat", sl.Err(err))
 			}
-			b.sendMessage(msg)
+			b.reply(update, msg)
+			continue
+		case quietHoursCommand:
+			msg, err := b.chc.SetQuietHours(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set quiet hours", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case digestCommand:
+			msg, err := b.chc.SetDigest(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set digest", sl.Err(err))
+			}
+			b.reply(update, msg)
 			continue
 		case deleteCompany:
 			msg, err := b.cc.DeleteCompany(update.Message)
 			if err != nil {
 				b.logger.Error("cannot delete company", sl.Err(err))
 			}
-			b.sendMessage(msg)
+			b.reply(update, msg)
+			continue
+		case aliasCommand:
+			msg, err := b.chc.SetAlias(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set chat alias", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case dedupCommand:
+			msg, err := b.cc.SetDedup(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set dedup window", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case setTemplateCommand:
+			msg, err := b.tc.SetTemplate(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set template", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case templatesCommand:
+			msg, err := b.tc.GetTemplates(update.Message)
+			if err != nil {
+				b.logger.Error("cannot get templates", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case deleteTemplateCommand:
+			msg, err := b.tc.DeleteTemplate(update.Message)
+			if err != nil {
+				b.logger.Error("cannot delete template", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case setRouteCommand:
+			msg, err := b.rc.SetRoute(update.Message)
+			if err != nil {
+				b.logger.Error("cannot set route", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case routesCommand:
+			msg, err := b.rc.GetRoutes(update.Message)
+			if err != nil {
+				b.logger.Error("cannot get routes", sl.Err(err))
+			}
+			b.reply(update, msg)
+			continue
+		case deleteRouteCommand:
+			msg, err := b.rc.DeleteRoute(update.Message)
+			if err != nil {
+				b.logger.Error("cannot delete route", sl.Err(err))
+			}
+			b.reply(update, msg)
 			continue
 		default:
 			msg.Text = "I don't know that command"
 		}
 
+		if update.threadID != 0 {
+			msg.ReplyToMessageID = update.Message.MessageID
+		}
+
 		if _, err := b.bot.Send(msg); err != nil {
 			log.Panic(err)
 		}
 	}
 }
 
+// reply sends the answer to the message of the update.
+// In a forum topic the answer is sent as a reply to the message, so it stays in the topic.
+func (b *TelegramBot) reply(u update, m tgbotapi.MessageConfig) {
+	if u.threadID != 0 && m.ReplyToMessageID == 0 {
+		m.ReplyToMessageID = u.Message.MessageID
+	}
+
+	b.sendMessage(m)
+}
+
 func (b *TelegramBot) sendMessage(m tgbotapi.MessageConfig) {
 	const op = "telegram.sendMessage"
 
-	if _, err := b.bot.Send(m); err != nil {
+	if _, err := b.send(context.Background(), m.ChatID, m); err != nil {
 		b.logger.Error("cannot send message", sl.Err(err), slog.String("op", op), slog.Int64("chatID", m.ChatID), slog.String("text", m.Text))
 	}
 }
 
+// send sends the message when the rate limiter allows it.
+// If Telegram flood control rejects the message, it waits for retry_after and tries again until the context is done.
+func (b *TelegramBot) send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var m tgbotapi.Message
+
+	err := b.do(ctx, chatID, func() (err error) {
+		m, err = b.bot.Send(c)
+		return err
+	})
+
+	return m, err
+}
+
+// do calls the Telegram API when the rate limiter allows it.
+// If Telegram flood control rejects the call, it waits for retry_after and tries again until the context is done.
+func (b *TelegramBot) do(ctx context.Context, chatID int64, call func() error) error {
+	const op = "telegram.do"
+
+	for {
+		if err := b.limiter.Wait(ctx, chatID); err != nil {
+			return fmt.Errorf("%s: %w: %w", op, errRateLimited, err)
+		}
+
+		err := call()
+
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+			retryAfter := time.Duration(tgErr.RetryAfter) * time.Second
+			b.logger.Warn("flood control exceeded", slog.String("op", op), slog.Int64("chatID", chatID), slog.Duration("retry_after", retryAfter))
+			b.limiter.Pause(chatID, retryAfter)
+			continue
+		}
+
+		return err
+	}
+}
+
 // SendMessage sends a message to the specified chat IDs using the Telegram bot API.
-func (b *TelegramBot) SendMessage(ctx context.Context, msg entities.Message) error {
+// A text longer than Telegram allows is sent as several ordered messages, the result holds the ID of the first one
+// and the IDs of all messages sent to the chat.
+// Attachments are sent as photos, documents or albums with the text as the caption of the first one,
+// a text longer than the caption limit is sent as a separate message before them.
+// Buttons are shown under the message with the text, so the text is not used as the caption of an album.
+// A message with EditMessageID set replaces the text of that message if it still exists and the text fits a single message.
+// If Telegram can not parse the entities of the message, it is sent once more as plain text and the result is flagged.
+// If the group was upgraded to a supergroup, the message is sent to the supergroup and its ID is reported in the result.
+// A failure in one chat does not stop sending to the others, it returns the result for every chat.
+func (b *TelegramBot) SendMessage(ctx context.Context, msg entities.Message) []entities.SendResult {
 	const op = "telegram.SendMessage"
+
+	parts := splitText(msg)
+
+	results := make([]entities.SendResult, 0, len(msg.ChatIds))
 	for _, chatID := range msg.ChatIds {
-		newMessage := tgbotapi.NewMessage(chatID, msg.Text)
+		messageIDs, plainText, err := b.deliver(ctx, chatID, msg, parts)
 
-		if msg.ParseMode != entities.Undefined {
-			newMessage.ParseMode = string(msg.ParseMode)
+		migratedTo := migrateToChatID(err)
+		if migratedTo != 0 {
+			b.logger.Info("group upgraded to supergroup, sending to it", slog.String("op", op), slog.Int64("chatID", chatID), slog.Int64("migratedTo", migratedTo))
+			messageIDs, plainText, err = b.deliver(ctx, migratedTo, migrateMessage(msg, chatID, migratedTo), parts)
 		}
 
-		if _, err := b.bot.Send(newMessage); err != nil {
+		if err != nil {
 			b.logger.Error("cannot send message", sl.Err(err), slog.String("op", op), slog.Int64("chatID", chatID), slog.String("text", msg.Text))
-			return fmt.Errorf("%s :cannot send message to chat %d: %w", op, chatID, err)
+			result := failedResult(chatID, err)
+			result.MigratedTo = migratedTo
+			results = append(results, result)
+			continue
+		}
+
+		result := entities.SendResult{
+			ChatID:     chatID,
+			Status:     entities.SendStatusSent,
+			MessageIDs: messageIDs,
+			PlainText:  plainText,
+			MigratedTo: migratedTo,
 		}
+		if len(messageIDs) > 0 {
+			result.MessageID = messageIDs[0]
+		}
+		results = append(results, result)
 	}
-	return nil
+
+	return results
+}
+
+// deliver sends the text and the attachments of the message to the chat and returns the IDs of the sent messages
+// and whether anything was sent as plain text.
+func (b *TelegramBot) deliver(ctx context.Context, chatID int64, msg entities.Message, parts []string) ([]int, bool, error) {
+	markup := keyboard(msg.Buttons)
+
+	// only a single text message can be edited, a message that does not fit or has attachments is sent anew
+	if msg.EditMessageID != 0 && len(msg.Attachments) == 0 && len(parts) == 1 {
+		plainText, err := b.editPart(ctx, chatID, msg, parts[0], markup)
+		if err != nil && !isEditGone(err) {
+			return nil, false, err
+		}
+		if err == nil {
+			return []int{msg.EditMessageID}, plainText, nil
+		}
+
+		b.logger.Warn("cannot edit message, sending a new one",
+			sl.Err(err),
+			slog.Int64("chatID", chatID),
+			slog.Int("message_id", msg.EditMessageID),
+		)
+	}
+
+	if len(msg.Attachments) == 0 {
+		return b.sendParts(ctx, chatID, msg, parts, markup)
+	}
+
+	groups := groupAttachments(msg.Attachments)
+
+	var (
+		ids       []int
+		plainText bool
+		caption   = msg.Text
+	)
+	// an album can not have buttons
+	if !fitsCaption(msg) || (markup != nil && len(groups[0]) > 1) {
+		var err error
+		if ids, plainText, err = b.sendParts(ctx, chatID, msg, parts, markup); err != nil {
+			return nil, false, err
+		}
+		caption = ""
+		markup = nil
+	}
+
+	for i, group := range groups {
+		if i > 0 {
+			caption = ""
+			markup = nil
+		}
+
+		groupIDs, fallback, err := b.sendGroup(ctx, chatID, msg, group, caption, markup)
+		if err != nil {
+			return nil, false, fmt.Errorf("attachments: %w", err)
+		}
+
+		ids = append(ids, groupIDs...)
+		plainText = plainText || fallback
+	}
+
+	return ids, plainText, nil
+}
+
+// sendGroup sends the attachments of the same type with the caption.
+// If Telegram can not parse the entities of the caption, they are sent again with the caption without markup and the fallback is reported.
+func (b *TelegramBot) sendGroup(ctx context.Context, chatID int64, msg entities.Message, group []entities.Attachment, caption string, markup interface{}) ([]int, bool, error) {
+	const op = "telegram.sendGroup"
+
+	mode := ""
+	if msg.ParseMode != entities.Undefined && caption != "" {
+		mode = string(msg.ParseMode)
+	}
+
+	ids, err := b.sendFiles(ctx, chatID, msg, group, caption, mode, markup)
+	if err == nil || mode == "" || !isParseError(err) {
+		return ids, false, err
+	}
+
+	b.logger.Warn("cannot parse caption entities, sending as plain text",
+		sl.Err(err),
+		slog.String("op", op),
+		slog.Int64("chatID", chatID),
+		slog.String("parse_mode", mode),
+		slog.String("caption", caption),
+	)
+
+	ids, err = b.sendFiles(ctx, chatID, msg, group, plainText(caption, msg.ParseMode), "", markup)
+
+	return ids, err == nil, err
+}
+
+// sendFiles sends a single attachment as a photo or a document and several attachments as an album.
+// The markup is shown only under a single attachment. It returns the IDs of the sent messages.
+func (b *TelegramBot) sendFiles(ctx context.Context, chatID int64, msg entities.Message, group []entities.Attachment, caption, parseMode string, markup interface{}) ([]int, error) {
+	params, err := messageParams(chatID, msg, markup)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(group) == 1 {
+		a := group[0]
+		params.AddNonEmpty("caption", caption)
+		params.AddNonEmpty("parse_mode", parseMode)
+
+		method := "sendDocument"
+		if a.Type == entities.AttachmentPhoto {
+			method = "sendPhoto"
+		}
+
+		var m tgbotapi.Message
+		if err := b.request(ctx, chatID, method, params, []tgbotapi.RequestFile{{Name: string(a.Type), Data: requestFile(a)}}, &m); err != nil {
+			return nil, err
+		}
+
+		return []int{m.MessageID}, nil
+	}
+
+	media := make([]interface{}, 0, len(group))
+	files := make([]tgbotapi.RequestFile, 0, len(group))
+	for i, a := range group {
+		base := tgbotapi.BaseInputMedia{
+			Type:  string(a.Type),
+			Media: requestFile(a),
+		}
+		if base.Media.NeedsUpload() {
+			name := fmt.Sprintf("file-%d", i)
+			files = append(files, tgbotapi.RequestFile{Name: name, Data: base.Media})
+			base.Media = tgbotapi.FileID("attach://" + name)
+		}
+		if i == 0 {
+			base.Caption = caption
+			base.ParseMode = parseMode
+		}
+
+		if a.Type == entities.AttachmentPhoto {
+			media = append(media, tgbotapi.InputMediaPhoto{BaseInputMedia: base})
+		} else {
+			media = append(media, tgbotapi.InputMediaDocument{BaseInputMedia: base})
+		}
+	}
+
+	if err := params.AddInterface("media", media); err != nil {
+		return nil, err
+	}
+
+	var ms []tgbotapi.Message
+	if err := b.request(ctx, chatID, "sendMediaGroup", params, files, &ms); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.MessageID)
+	}
+
+	return ids, nil
+}
+
+// sendParts sends the parts of the text to the chat in order and returns the IDs of the sent messages
+// and whether any part was sent as plain text. The markup is shown under the last part.
+func (b *TelegramBot) sendParts(ctx context.Context, chatID int64, msg entities.Message, parts []string, markup interface{}) ([]int, bool, error) {
+	var (
+		ids       = make([]int, 0, len(parts))
+		plainText bool
+	)
+	for i, part := range parts {
+		var partMarkup interface{}
+		if i == len(parts)-1 {
+			partMarkup = markup
+		}
+
+		m, fallback, err := b.sendPart(ctx, chatID, msg, part, partMarkup)
+		if err != nil && len(parts) > 1 {
+			return nil, false, fmt.Errorf("part %d of %d: %w", i+1, len(parts), err)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		ids = append(ids, m.MessageID)
+		plainText = plainText || fallback
+	}
+
+	return ids, plainText, nil
+}
+
+// sendPart sends a part of the text to the chat.
+// If Telegram can not parse its entities, the part is sent again without markup and the fallback is reported.
+func (b *TelegramBot) sendPart(ctx context.Context, chatID int64, msg entities.Message, part string, markup interface{}) (tgbotapi.Message, bool, error) {
+	const op = "telegram.sendPart"
+
+	params, err := messageParams(chatID, msg, markup)
+	if err != nil {
+		return tgbotapi.Message{}, false, err
+	}
+	params["text"] = part
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	if msg.ParseMode != entities.Undefined {
+		params["parse_mode"] = string(msg.ParseMode)
+	}
+
+	var m tgbotapi.Message
+	err = b.request(ctx, chatID, "sendMessage", params, nil, &m)
+	if err == nil || msg.ParseMode == entities.Undefined || !isParseError(err) {
+		return m, false, err
+	}
+
+	b.logger.Warn("cannot parse message entities, sending as plain text",
+		sl.Err(err),
+		slog.String("op", op),
+		slog.Int64("chatID", chatID),
+		slog.String("parse_mode", string(msg.ParseMode)),
+		slog.String("text", part),
+	)
+
+	params["text"] = plainText(part, msg.ParseMode)
+	delete(params, "parse_mode")
+
+	err = b.request(ctx, chatID, "sendMessage", params, nil, &m)
+
+	return m, err == nil, err
+}
+
+// editPart replaces the text of the message sent to the chat earlier with the part.
+// If Telegram can not parse its entities, the part is set again without markup and the fallback is reported.
+// A message that already has the same text is not an error.
+func (b *TelegramBot) editPart(ctx context.Context, chatID int64, msg entities.Message, part string, markup interface{}) (bool, error) {
+	const op = "telegram.editPart"
+
+	params := make(tgbotapi.Params)
+	params.AddNonZero64("chat_id", chatID)
+	params.AddNonZero("message_id", msg.EditMessageID)
+	params["text"] = part
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	if msg.ParseMode != entities.Undefined {
+		params["parse_mode"] = string(msg.ParseMode)
+	}
+	if err := params.AddInterface("reply_markup", markup); err != nil {
+		return false, err
+	}
+
+	var m tgbotapi.Message
+	err := b.request(ctx, chatID, "editMessageText", params, nil, &m)
+	if err != nil && msg.ParseMode != entities.Undefined && isParseError(err) {
+		b.logger.Warn("cannot parse message entities, editing as plain text",
+			sl.Err(err),
+			slog.String("op", op),
+			slog.Int64("chatID", chatID),
+			slog.String("parse_mode", string(msg.ParseMode)),
+			slog.String("text", part),
+		)
+
+		params["text"] = plainText(part, msg.ParseMode)
+		delete(params, "parse_mode")
+
+		err = b.request(ctx, chatID, "editMessageText", params, nil, &m)
+		if err == nil || isNotModified(err) {
+			return true, nil
+		}
+	}
+	if err != nil && !isNotModified(err) {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// DeleteMessages deletes the messages with the given IDs from the chat.
+// Messages that are already deleted are skipped, it tries to delete all messages and returns the errors of those it could not.
+// Deletes do not wait for the send rate limiter, they stop when the context is done or Telegram flood control rejects them.
+func (b *TelegramBot) DeleteMessages(ctx context.Context, chatID int64, messageIDs []int) error {
+	var errs []error
+	for i, id := range messageIDs {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("messages %v: %w", messageIDs[i:], err))
+			break
+		}
+
+		params := make(tgbotapi.Params)
+		params.AddNonZero64("chat_id", chatID)
+		params.AddNonZero("message_id", id)
+
+		_, err := b.bot.MakeRequest("deleteMessage", params)
+
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+			errs = append(errs, fmt.Errorf("messages %v: %w: %w", messageIDs[i:], errRateLimited, err))
+			break
+		}
+		if err != nil && !isDeleteGone(err) {
+			errs = append(errs, fmt.Errorf("message %d: %w", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// splitText splits the text of the message into parts that fit the Telegram message length limit.
+func splitText(msg entities.Message) []string {
+	switch msg.ParseMode {
+	case entities.HTML:
+		return split.HTML(msg.Text, split.MaxMessageLength)
+	case entities.MarkdownV2:
+		return split.MarkdownV2(msg.Text, split.MaxMessageLength)
+	case entities.Markdown:
+		return split.Markdown(msg.Text, split.MaxMessageLength)
+	default:
+		return split.Text(msg.Text, split.MaxMessageLength)
+	}
+}
+
+// keyboard returns the inline keyboard with the buttons or nil if there are no buttons.
+func keyboard(buttons [][]entities.Button) interface{} {
+	if len(buttons) == 0 {
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+	for _, row := range buttons {
+		r := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			r = append(r, tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL))
+		}
+		rows = append(rows, r)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// fitsCaption reports whether the text of the message fits the caption of an attachment.
+func fitsCaption(msg entities.Message) bool {
+	switch msg.ParseMode {
+	case entities.HTML:
+		return len(split.HTML(msg.Text, split.MaxCaptionLength)) == 1
+	case entities.MarkdownV2:
+		return len(split.MarkdownV2(msg.Text, split.MaxCaptionLength)) == 1
+	case entities.Markdown:
+		return len(split.Markdown(msg.Text, split.MaxCaptionLength)) == 1
+	default:
+		return len(split.Text(msg.Text, split.MaxCaptionLength)) == 1
+	}
+}
+
+// groupAttachments splits the attachments into groups of consecutive attachments of the same type
+// that fit an album, because Telegram does not mix photos and documents in one album.
+func groupAttachments(attachments []entities.Attachment) [][]entities.Attachment {
+	var groups [][]entities.Attachment
+	for i, a := range attachments {
+		last := len(groups) - 1
+		if i == 0 || groups[last][0].Type != a.Type || len(groups[last]) == maxMediaGroupSize {
+			groups = append(groups, []entities.Attachment{a})
+			continue
+		}
+		groups[last] = append(groups[last], a)
+	}
+
+	return groups
+}
+
+// requestFile returns the file of the attachment to upload or to download by URL.
+func requestFile(a entities.Attachment) tgbotapi.RequestFileData {
+	if a.URL != "" {
+		return tgbotapi.FileURL(a.URL)
+	}
+
+	name := a.Name
+	if name == "" {
+		name = string(a.Type)
+	}
+
+	return tgbotapi.FileBytes{Name: name, Bytes: a.Data}
+}
+
+// isParseError reports whether Telegram rejected the message because it could not parse its entities.
+func isParseError(err error) bool {
+	var tgErr *tgbotapi.Error
+
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(tgErr.Message), "can't parse entities")
+}
+
+// isNotModified reports whether Telegram rejected the edit because the message already has the same content.
+func isNotModified(err error) bool {
+	var tgErr *tgbotapi.Error
+
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(tgErr.Message), "message is not modified")
+}
+
+// isEditGone reports whether Telegram rejected the edit because the message was deleted or can not be edited anymore.
+func isEditGone(err error) bool {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.Code != http.StatusBadRequest {
+		return false
+	}
+
+	message := strings.ToLower(tgErr.Message)
+
+	return strings.Contains(message, "message to edit not found") || strings.Contains(message, "message can't be edited")
+}
+
+// isDeleteGone reports whether Telegram rejected the deletion because the message is already deleted.
+func isDeleteGone(err error) bool {
+	var tgErr *tgbotapi.Error
+
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(tgErr.Message), "message to delete not found")
+}
+
+// migrateMessage returns the message to send to the supergroup the group was upgraded to,
+// it is sent to the same topic as to the group. The thread IDs of the original message are not changed.
+func migrateMessage(msg entities.Message, chatID, migratedTo int64) entities.Message {
+	threadID, ok := msg.ThreadIDs[chatID]
+	if !ok {
+		return msg
+	}
+
+	threadIDs := make(map[int64]int, len(msg.ThreadIDs)+1)
+	for id, t := range msg.ThreadIDs {
+		threadIDs[id] = t
+	}
+	threadIDs[migratedTo] = threadID
+	msg.ThreadIDs = threadIDs
+
+	return msg
+}
+
+// migrateToChatID returns the ID of the supergroup the group was upgraded to if Telegram rejected the message because of it.
+func migrateToChatID(err error) int64 {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return 0
+	}
+
+	return tgErr.MigrateToChatID
+}
+
+// plainText returns the text without the markup of the parse mode.
+func plainText(text string, parseMode entities.ParseMode) string {
+	switch parseMode {
+	case entities.HTML:
+		return html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	case entities.MarkdownV2:
+		return markdown.StripV2(text)
+	case entities.Markdown:
+		return markdown.Strip(text)
+	default:
+		return text
+	}
+}
+
+// failedResult converts the error returned by send to the result of the chat.
+func failedResult(chatID int64, err error) entities.SendResult {
+	result := entities.SendResult{
+		ChatID: chatID,
+		Status: entities.SendStatusFailed,
+		Error:  err.Error(),
+	}
+
+	var tgErr *tgbotapi.Error
+	switch {
+	case errors.Is(err, errRateLimited):
+		result.Status = entities.SendStatusRateLimited
+	case errors.As(err, &tgErr) && tgErr.Code == http.StatusForbidden:
+		result.Status = entities.SendStatusForbidden
+	case errors.As(err, &tgErr) && strings.Contains(strings.ToLower(tgErr.Message), "chat not found"):
+		result.Status = entities.SendStatusNotFound
+	}
+
+	return result
 }
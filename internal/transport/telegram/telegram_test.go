@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+func Test_plainText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		parseMode entities.ParseMode
+		want      string
+	}{
+		{
+			name:      "HTML",
+			text:      "<b>Run #12</b> &lt;failed&gt; <a href=\"https://testit.software\">details</a>",
+			parseMode: entities.HTML,
+			want:      "Run #12 <failed> details",
+		},
+		{
+			name:      "Markdown",
+			text:      "*Run #12* _failed_, `my_test`: [details](https://testit.software)",
+			parseMode: entities.Markdown,
+			want:      "Run #12 failed, my_test: details",
+		},
+		{
+			name:      "Markdown with unclosed marker",
+			text:      "*Run #12* my_test failed",
+			parseMode: entities.Markdown,
+			want:      "Run #12 my_test failed",
+		},
+		{
+			name:      "MarkdownV2",
+			text:      "*Run \\#12* _failed_ ||~passed~|| \\(1\\.2\\)",
+			parseMode: entities.MarkdownV2,
+			want:      "Run #12 failed passed (1.2)",
+		},
+		{
+			name:      "without parse mode",
+			text:      "*Run #12* failed",
+			parseMode: entities.Undefined,
+			want:      "*Run #12* failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, plainText(tt.text, tt.parseMode))
+		})
+	}
+}
+
+func Test_migrateMessage(t *testing.T) {
+	t.Run("with topic", func(t *testing.T) {
+		msg := entities.Message{Text: "text", ThreadIDs: map[int64]int{-123: 7, -456: 9}}
+
+		got := migrateMessage(msg, -123, -100123)
+
+		assert.Equal(t, map[int64]int{-123: 7, -456: 9, -100123: 7}, got.ThreadIDs)
+		assert.Equal(t, map[int64]int{-123: 7, -456: 9}, msg.ThreadIDs)
+	})
+
+	t.Run("without topic", func(t *testing.T) {
+		msg := entities.Message{Text: "text", ThreadIDs: map[int64]int{-456: 9}}
+
+		assert.Equal(t, msg, migrateMessage(msg, -123, -100123))
+	})
+}
@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"encoding/json"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"golang.org/x/exp/slog"
+)
+
+// update is an update from Telegram with the forum topic of its message.
+type update struct {
+	tgbotapi.Update
+	// threadID is the forum topic the message is sent to, 0 if the chat is not a forum.
+	threadID int
+}
+
+// topicUpdate holds the fields of forum topic messages that tgbotapi does not decode.
+type topicUpdate struct {
+	Message *struct {
+		MessageThreadID int  `json:"message_thread_id"`
+		IsTopicMessage  bool `json:"is_topic_message"`
+	} `json:"message"`
+}
+
+// getUpdatesChan starts polling Telegram for updates like tgbotapi.BotAPI.GetUpdatesChan
+// and decodes the forum topics of the messages as well.
+func (b *TelegramBot) getUpdatesChan(config tgbotapi.UpdateConfig) <-chan update {
+	const op = "telegram.getUpdatesChan"
+
+	ch := make(chan update, b.bot.Buffer)
+
+	go func() {
+		for {
+			updates, err := b.getUpdates(config)
+			if err != nil {
+				b.logger.Error("cannot get updates, retrying in 3 seconds", sl.Err(err), slog.String("op", op))
+				time.Sleep(3 * time.Second)
+				continue
+			}
+
+			for _, u := range updates {
+				if u.UpdateID >= config.Offset {
+					config.Offset = u.UpdateID + 1
+				}
+				ch <- u
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (b *TelegramBot) getUpdates(config tgbotapi.UpdateConfig) ([]update, error) {
+	resp, err := b.bot.Request(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		updates []tgbotapi.Update
+		topics  []topicUpdate
+	)
+	if err := json.Unmarshal(resp.Result, &updates); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp.Result, &topics); err != nil {
+		return nil, err
+	}
+
+	res := make([]update, 0, len(updates))
+	for i, u := range updates {
+		res = append(res, update{Update: u})
+		if m := topics[i].Message; m != nil && m.IsTopicMessage {
+			res[i].threadID = m.MessageThreadID
+		}
+	}
+
+	return res, nil
+}
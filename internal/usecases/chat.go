@@ -2,9 +2,14 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
 )
 
 //go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
@@ -12,8 +17,23 @@ type chatsStorage interface {
 	AddChat(ctx context.Context, chat entities.Chat) (entities.Chat, error)
 	DeleteChatById(ctx context.Context, id int64) error
 	GetChatsByCompanyId(ctx context.Context, id int64) ([]entities.Chat, error)
+	SetQuietHours(ctx context.Context, id int64, q entities.QuietHours) error
+	SetDigestWindow(ctx context.Context, id int64, seconds int) error
+	SetAlias(ctx context.Context, id int64, alias string) error
+	ActivateChat(ctx context.Context, id int64) error
 }
 
+var (
+	// ErrChatAliasInvalid is returned when a chat alias is not valid.
+	ErrChatAliasInvalid = errors.New("chat alias is invalid")
+	// ErrChatAliasTaken is returned when another chat of the company has the alias.
+	ErrChatAliasTaken = errors.New("chat alias is taken")
+	// ErrChatAlreadyAdded is returned when the company already has the chat, messages are sent to one topic of a chat only.
+	ErrChatAlreadyAdded = errors.New("chat is already added")
+
+	chatAlias = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+)
+
 type chatUsecases struct {
 	cs   chatsStorage
 	coms companyStorage
@@ -30,7 +50,32 @@ func NewChatUsecases(cs chatsStorage, coms companyStorage) *chatUsecases {
 
 // AddChat adds a new chat to the system.
 // It takes a context and a Chat entity as input and returns the newly added Chat entity and an error (if any).
+// If the company has the chat turned off because the bot was blocked or removed from it, the chat is turned on again instead.
+// If the company already has the chat, with the same or another forum topic, ErrChatAlreadyAdded is returned.
 func (u *chatUsecases) AddChat(ctx context.Context, chat entities.Chat) (entities.Chat, error) {
+	const op = "usecases.AddChat"
+
+	chats, err := u.cs.GetChatsByCompanyId(ctx, chat.CompanyID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return entities.Chat{}, fmt.Errorf("%s: get chats by company id: %w", op, err)
+	}
+
+	for _, c := range chats {
+		if c.TelegramID != chat.TelegramID {
+			continue
+		}
+		if c.MessageThreadID != chat.MessageThreadID || c.Active {
+			return entities.Chat{}, fmt.Errorf("%s: chat %d with topic %d: %w", op, c.TelegramID, c.MessageThreadID, ErrChatAlreadyAdded)
+		}
+
+		if err := u.cs.ActivateChat(ctx, c.Id); err != nil {
+			return entities.Chat{}, fmt.Errorf("%s: activate chat: %w", op, err)
+		}
+		c.Active = true
+
+		return c, nil
+	}
+
 	return u.cs.AddChat(ctx, chat)
 }
 
@@ -62,3 +107,96 @@ func (u *chatUsecases) DeleteChatByTelegramId(ctx context.Context, ownerId, chat
 
 	return fmt.Errorf("%s: chat not found", op)
 }
+
+// SetQuietHours sets the quiet hours of the chat with the given Telegram ID of the company with the given owner ID.
+// If the company has no such chat, it returns an error.
+func (u *chatUsecases) SetQuietHours(ctx context.Context, ownerId, chatId int64, q entities.QuietHours) error {
+	const op = "usecases.SetQuietHours"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	chats, err := u.cs.GetChatsByCompanyId(ctx, company.ID)
+	if err != nil {
+		return fmt.Errorf("%s: get chats by company id: %w", op, err)
+	}
+
+	for _, chat := range chats {
+		if chat.TelegramID == chatId {
+			if err := u.cs.SetQuietHours(ctx, chat.Id, q); err != nil {
+				return fmt.Errorf("%s: set quiet hours: %w", op, err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: chat not found", op)
+}
+
+// SetDigestWindow sets the digest window of the chat with the given Telegram ID of the company with the given owner ID,
+// a zero window turns the digest off. If the company has no such chat, it returns an error.
+func (u *chatUsecases) SetDigestWindow(ctx context.Context, ownerId, chatId int64, window time.Duration) error {
+	const op = "usecases.SetDigestWindow"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	chats, err := u.cs.GetChatsByCompanyId(ctx, company.ID)
+	if err != nil {
+		return fmt.Errorf("%s: get chats by company id: %w", op, err)
+	}
+
+	for _, chat := range chats {
+		if chat.TelegramID == chatId {
+			if err := u.cs.SetDigestWindow(ctx, chat.Id, int(window.Seconds())); err != nil {
+				return fmt.Errorf("%s: set digest window: %w", op, err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: chat not found", op)
+}
+
+// SetChatAlias sets the alias of the chat with the given Telegram ID of the company with the given owner ID,
+// an empty alias removes it. Aliases are case-insensitive and stored in lower case.
+// It returns ErrChatAliasInvalid if the alias is not valid and ErrChatAliasTaken if another chat of the company has it.
+// If the company has no such chat, it returns an error.
+func (u *chatUsecases) SetChatAlias(ctx context.Context, ownerId, chatId int64, alias string) error {
+	const op = "usecases.SetChatAlias"
+
+	alias = strings.ToLower(alias)
+	if alias != "" && !chatAlias.MatchString(alias) {
+		return fmt.Errorf("%s: %w: alias must start with a letter and contain only letters, digits, '_', '.' and '-'", op, ErrChatAliasInvalid)
+	}
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	chats, err := u.cs.GetChatsByCompanyId(ctx, company.ID)
+	if err != nil {
+		return fmt.Errorf("%s: get chats by company id: %w", op, err)
+	}
+
+	for _, chat := range chats {
+		if chat.TelegramID != chatId {
+			continue
+		}
+
+		if err := u.cs.SetAlias(ctx, chat.Id, alias); err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				return fmt.Errorf("%s: %w", op, ErrChatAliasTaken)
+			}
+			return fmt.Errorf("%s: set alias: %w", op, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s: chat not found", op)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/testit-tms/webhook-bot/internal/entities"
@@ -17,6 +18,9 @@ func Test_chatUsecases_AddChat(t *testing.T) {
 	tests := []struct {
 		name           string
 		chat           entities.Chat
+		chats          []entities.Chat
+		activateTimes  int
+		addTimes       int
 		want           entities.Chat
 		wantErr        bool
 		wantErrMessage string
@@ -28,6 +32,8 @@ func Test_chatUsecases_AddChat(t *testing.T) {
 				CompanyID:  12,
 				TelegramID: 123,
 			},
+			chats:    []entities.Chat{{Id: 2, CompanyID: 12, TelegramID: 321, Active: false}},
+			addTimes: 1,
 			want: entities.Chat{
 				Id:         1,
 				CompanyID:  12,
@@ -37,12 +43,54 @@ func Test_chatUsecases_AddChat(t *testing.T) {
 			wantErrMessage: "",
 			wantError:      nil,
 		},
+		{
+			name: "inactive chat activated",
+			chat: entities.Chat{
+				CompanyID:  12,
+				TelegramID: 123,
+			},
+			chats:         []entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123, Alias: "qa-team", Active: false}},
+			activateTimes: 1,
+			want: entities.Chat{
+				Id:         1,
+				CompanyID:  12,
+				TelegramID: 123,
+				Alias:      "qa-team",
+				Active:     true,
+			},
+		},
+		{
+			name: "already added",
+			chat: entities.Chat{
+				CompanyID:  12,
+				TelegramID: 123,
+			},
+			chats:          []entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123, Active: true}},
+			want:           entities.Chat{},
+			wantErr:        true,
+			wantErrMessage: "usecases.AddChat: chat 123 with topic 0: chat is already added",
+			wantError:      ErrChatAlreadyAdded,
+		},
+		{
+			name: "added with another topic",
+			chat: entities.Chat{
+				CompanyID:       12,
+				TelegramID:      123,
+				MessageThreadID: 8,
+			},
+			chats:          []entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123, MessageThreadID: 5, Active: false}},
+			want:           entities.Chat{},
+			wantErr:        true,
+			wantErrMessage: "usecases.AddChat: chat 123 with topic 5: chat is already added",
+			wantError:      ErrChatAlreadyAdded,
+		},
 		{
 			name: "error",
 			chat: entities.Chat{
 				CompanyID:  12,
 				TelegramID: 123,
 			},
+			addTimes:       1,
 			want:           entities.Chat{},
 			wantErr:        true,
 			wantErrMessage: "error",
@@ -53,7 +101,9 @@ func Test_chatUsecases_AddChat(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockCtrl := gomock.NewController(t)
 			chatMock := mocks.NewMockchatsStorage(mockCtrl)
-			chatMock.EXPECT().AddChat(gomock.Any(), tt.chat).Return(tt.want, tt.wantError)
+			chatMock.EXPECT().GetChatsByCompanyId(gomock.Any(), tt.chat.CompanyID).Return(tt.chats, nil).Times(1)
+			chatMock.EXPECT().ActivateChat(gomock.Any(), int64(1)).Return(nil).Times(tt.activateTimes)
+			chatMock.EXPECT().AddChat(gomock.Any(), tt.chat).Return(tt.want, tt.wantError).Times(tt.addTimes)
 
 			companyMock := mocks.NewMockcompanyStorage(mockCtrl)
 			u := NewChatUsecases(chatMock, companyMock)
@@ -66,6 +116,7 @@ func Test_chatUsecases_AddChat(t *testing.T) {
 				}
 
 				assert.Equal(t, tt.wantErrMessage, err.Error())
+				assert.ErrorIs(t, err, tt.wantError)
 			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("chatUsecases.AddChat() = %v, want %v", got, tt.want)
@@ -218,3 +269,225 @@ func Test_chatUsecases_DeleteChatByTelegramId(t *testing.T) {
 		})
 	}
 }
+
+func Test_chatUsecases_SetQuietHours(t *testing.T) {
+	quietHours := entities.QuietHours{Start: 1320, End: 480, TimeZone: "Europe/Berlin"}
+
+	tests := []struct {
+		name             string
+		chatId           int64
+		mockCompError    error
+		mockGetChatTimes int
+		mockSetTimes     int
+		mockSetError     error
+		wantErrMessage   string
+	}{
+		{
+			name:             "success",
+			chatId:           123,
+			mockGetChatTimes: 1,
+			mockSetTimes:     1,
+		},
+		{
+			name:           "company not found",
+			chatId:         123,
+			mockCompError:  storage.ErrNotFound,
+			wantErrMessage: "usecases.SetQuietHours: get company by owner id: entity not found",
+		},
+		{
+			name:             "chat not found",
+			chatId:           321,
+			mockGetChatTimes: 1,
+			wantErrMessage:   "usecases.SetQuietHours: chat not found",
+		},
+		{
+			name:             "set quiet hours error",
+			chatId:           123,
+			mockGetChatTimes: 1,
+			mockSetTimes:     1,
+			mockSetError:     errors.New("error"),
+			wantErrMessage:   "usecases.SetQuietHours: set quiet hours: error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			companyMock := mocks.NewMockcompanyStorage(mockCtrl)
+			companyMock.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(1)).Return(entities.Company{ID: 12}, tt.mockCompError).Times(1)
+
+			chatMock := mocks.NewMockchatsStorage(mockCtrl)
+			chatMock.EXPECT().GetChatsByCompanyId(gomock.Any(), int64(12)).
+				Return([]entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123}}, nil).
+				Times(tt.mockGetChatTimes)
+			chatMock.EXPECT().SetQuietHours(gomock.Any(), int64(1), quietHours).Return(tt.mockSetError).Times(tt.mockSetTimes)
+
+			u := NewChatUsecases(chatMock, companyMock)
+
+			err := u.SetQuietHours(context.Background(), 1, tt.chatId, quietHours)
+			if tt.wantErrMessage != "" {
+				assert.EqualError(t, err, tt.wantErrMessage)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_chatUsecases_SetDigestWindow(t *testing.T) {
+	tests := []struct {
+		name             string
+		chatId           int64
+		mockCompError    error
+		mockGetChatTimes int
+		mockSetTimes     int
+		mockSetError     error
+		wantErrMessage   string
+	}{
+		{
+			name:             "success",
+			chatId:           123,
+			mockGetChatTimes: 1,
+			mockSetTimes:     1,
+		},
+		{
+			name:           "company not found",
+			chatId:         123,
+			mockCompError:  storage.ErrNotFound,
+			wantErrMessage: "usecases.SetDigestWindow: get company by owner id: entity not found",
+		},
+		{
+			name:             "chat not found",
+			chatId:           321,
+			mockGetChatTimes: 1,
+			wantErrMessage:   "usecases.SetDigestWindow: chat not found",
+		},
+		{
+			name:             "set digest window error",
+			chatId:           123,
+			mockGetChatTimes: 1,
+			mockSetTimes:     1,
+			mockSetError:     errors.New("error"),
+			wantErrMessage:   "usecases.SetDigestWindow: set digest window: error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			companyMock := mocks.NewMockcompanyStorage(mockCtrl)
+			companyMock.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(1)).Return(entities.Company{ID: 12}, tt.mockCompError).Times(1)
+
+			chatMock := mocks.NewMockchatsStorage(mockCtrl)
+			chatMock.EXPECT().GetChatsByCompanyId(gomock.Any(), int64(12)).
+				Return([]entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123}}, nil).
+				Times(tt.mockGetChatTimes)
+			chatMock.EXPECT().SetDigestWindow(gomock.Any(), int64(1), 300).Return(tt.mockSetError).Times(tt.mockSetTimes)
+
+			u := NewChatUsecases(chatMock, companyMock)
+
+			err := u.SetDigestWindow(context.Background(), 1, tt.chatId, 5*time.Minute)
+			if tt.wantErrMessage != "" {
+				assert.EqualError(t, err, tt.wantErrMessage)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_chatUsecases_SetChatAlias(t *testing.T) {
+	tests := []struct {
+		name             string
+		chatId           int64
+		alias            string
+		mockCompTimes    int
+		mockGetChatTimes int
+		mockSetAlias     string
+		mockSetTimes     int
+		mockSetError     error
+		wantErr          error
+		wantErrMessage   string
+	}{
+		{
+			name:             "success",
+			chatId:           123,
+			alias:            "QA-Team",
+			mockCompTimes:    1,
+			mockGetChatTimes: 1,
+			mockSetAlias:     "qa-team",
+			mockSetTimes:     1,
+		},
+		{
+			name:             "remove alias",
+			chatId:           123,
+			mockCompTimes:    1,
+			mockGetChatTimes: 1,
+			mockSetTimes:     1,
+		},
+		{
+			name:    "invalid alias",
+			chatId:  123,
+			alias:   "-100123",
+			wantErr: ErrChatAliasInvalid,
+		},
+		{
+			name:             "chat not found",
+			chatId:           321,
+			alias:            "qa-team",
+			mockCompTimes:    1,
+			mockGetChatTimes: 1,
+			wantErrMessage:   "usecases.SetChatAlias: chat not found",
+		},
+		{
+			name:             "alias taken",
+			chatId:           123,
+			alias:            "qa-team",
+			mockCompTimes:    1,
+			mockGetChatTimes: 1,
+			mockSetAlias:     "qa-team",
+			mockSetTimes:     1,
+			mockSetError:     storage.ErrAlreadyExists,
+			wantErr:          ErrChatAliasTaken,
+		},
+		{
+			name:             "set alias error",
+			chatId:           123,
+			alias:            "qa-team",
+			mockCompTimes:    1,
+			mockGetChatTimes: 1,
+			mockSetAlias:     "qa-team",
+			mockSetTimes:     1,
+			mockSetError:     errors.New("error"),
+			wantErrMessage:   "usecases.SetChatAlias: set alias: error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+
+			companyMock := mocks.NewMockcompanyStorage(mockCtrl)
+			companyMock.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(1)).Return(entities.Company{ID: 12}, nil).Times(tt.mockCompTimes)
+
+			chatMock := mocks.NewMockchatsStorage(mockCtrl)
+			chatMock.EXPECT().GetChatsByCompanyId(gomock.Any(), int64(12)).
+				Return([]entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123}}, nil).
+				Times(tt.mockGetChatTimes)
+			chatMock.EXPECT().SetAlias(gomock.Any(), int64(1), tt.mockSetAlias).Return(tt.mockSetError).Times(tt.mockSetTimes)
+
+			u := NewChatUsecases(chatMock, companyMock)
+
+			err := u.SetChatAlias(context.Background(), 1, tt.chatId, tt.alias)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantErrMessage != "":
+				assert.EqualError(t, err, tt.wantErrMessage)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/testit-tms/webhook-bot/internal/entities"
 	"github.com/testit-tms/webhook-bot/internal/lib/random"
@@ -15,6 +16,7 @@ type companyStorage interface {
 	GetCompanyByOwnerTelegramId(ctx context.Context, ownerId int64) (entities.Company, error)
 	UpdateToken(ctx context.Context, companyId int64, token string) error
 	DeleteCompany(ctx context.Context, companyId int64) error
+	SetDedupWindow(ctx context.Context, companyId int64, seconds int) error
 }
 
 //go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
@@ -71,6 +73,12 @@ func (u *companyUsecases) GetCompanyByOwnerTelegramId(ctx context.Context, owner
 
 	for _, chat := range chats {
 		ci.ChatIds = append(ci.ChatIds, chat.TelegramID)
+		if chat.Username != "" {
+			if ci.Usernames == nil {
+				ci.Usernames = make(map[int64]string)
+			}
+			ci.Usernames[chat.TelegramID] = chat.Username
+		}
 	}
 
 	return ci, nil
@@ -115,5 +123,26 @@ func (u *companyUsecases) DeleteCompany(ctx context.Context, ownerId int64) erro
 		return fmt.Errorf("%s: delete company: %w", op, err)
 	}
 
+	return nil
+}
+
+// SetDedupWindow sets the window duplicates of a message sent to a chat of the company with the given owner Telegram ID
+// are suppressed within, a zero window turns the deduplication off.
+// It returns an error if the company is not found.
+func (u *companyUsecases) SetDedupWindow(ctx context.Context, ownerId int64, window time.Duration) error {
+	const op = "usecases.SetDedupWindow"
+
+	company, err := u.cs.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	if err := u.cs.SetDedupWindow(ctx, company.ID, int(window.Seconds())); err != nil {
+		return fmt.Errorf("%s: set dedup window: %w", op, err)
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/testit-tms/webhook-bot/internal/entities"
@@ -38,6 +39,10 @@ func Test_companyUsecases_GetCompanyByOwnerId(t *testing.T) {
 				Email:   "info@ya.ru",
 				ChatIds: []int64{
 					123,
+					-100456,
+				},
+				Usernames: map[int64]string{
+					-100456: "our_qa_channel",
 				},
 			},
 			mockCompEntities: entities.Company{
@@ -55,6 +60,12 @@ func Test_companyUsecases_GetCompanyByOwnerId(t *testing.T) {
 					CompanyID:  12,
 					TelegramID: 123,
 				},
+				{
+					Id:         2,
+					CompanyID:  12,
+					TelegramID: -100456,
+					Username:   "our_qa_channel",
+				},
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
@@ -324,3 +335,47 @@ func Test_companyUsecases_DeleteCompany(t *testing.T) {
 		})
 	}
 }
+
+func Test_companyUsecases_SetDedupWindow(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockCompError  error
+		mockSetError   error
+		mockSetTimes   int
+		wantErrMessage string
+	}{
+		{
+			name:         "success",
+			mockSetTimes: 1,
+		},
+		{
+			name:           "company not found",
+			mockCompError:  storage.ErrNotFound,
+			wantErrMessage: "usecases.SetDedupWindow: company not found",
+		},
+		{
+			name:           "set dedup window error",
+			mockSetError:   errors.New("test error"),
+			mockSetTimes:   1,
+			wantErrMessage: "usecases.SetDedupWindow: set dedup window: test error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			companyMock := mocks.NewMockcompanyStorage(mockCtrl)
+			companyMock.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(1)).Return(entities.Company{ID: 12}, tt.mockCompError).Times(1)
+			companyMock.EXPECT().SetDedupWindow(gomock.Any(), int64(12), 600).Return(tt.mockSetError).Times(tt.mockSetTimes)
+
+			u := NewCompanyUsecases(companyMock, nil)
+
+			err := u.SetDedupWindow(context.Background(), 1, 10*time.Minute)
+			if tt.wantErrMessage != "" {
+				assert.EqualError(t, err, tt.wantErrMessage)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
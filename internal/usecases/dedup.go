@@ -0,0 +1,42 @@
+package usecases
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+// dedupKey returns the key duplicates of the message are recognized by: the key set by the caller
+// or the hash of the text with the letter case and the whitespace normalized.
+// Messages without both are never duplicates.
+func dedupKey(msg entities.Message) string {
+	if msg.DedupKey != "" {
+		return msg.DedupKey
+	}
+
+	text := strings.Join(strings.Fields(strings.ToLower(msg.Text)), " ")
+	if text == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(text))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// withSuppressed appends the number of suppressed duplicates to the text of the message.
+func withSuppressed(text string, suppressed int) string {
+	summary := fmt.Sprintf("suppressed %d duplicates", suppressed)
+	if suppressed == 1 {
+		summary = "suppressed 1 duplicate"
+	}
+
+	if text == "" {
+		return summary
+	}
+
+	return text + "\n\n" + summary
+}
@@ -0,0 +1,24 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+)
+
+func Test_dedupKey(t *testing.T) {
+	key := dedupKey(entities.Message{Text: "Login test  failed\n"})
+
+	assert.NotEmpty(t, key)
+	assert.Equal(t, key, dedupKey(entities.Message{Text: "login test failed"}))
+	assert.NotEqual(t, key, dedupKey(entities.Message{Text: "Logout test failed"}))
+	assert.Equal(t, "login-failed", dedupKey(entities.Message{Text: "Login test failed", DedupKey: "login-failed"}))
+	assert.Empty(t, dedupKey(entities.Message{}))
+}
+
+func Test_withSuppressed(t *testing.T) {
+	assert.Equal(t, "text\n\nsuppressed 1 duplicate", withSuppressed("text", 1))
+	assert.Equal(t, "text\n\nsuppressed 3 duplicates", withSuppressed("text", 3))
+	assert.Equal(t, "suppressed 3 duplicates", withSuppressed("", 3))
+}
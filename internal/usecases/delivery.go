@@ -0,0 +1,477 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"golang.org/x/exp/slog"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type deliveryStorage interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entities.Delivery, error)
+	ClaimDigest(ctx context.Context, companyID, chatID int64, lease time.Duration) ([]entities.Delivery, error)
+	MarkDelivered(ctx context.Context, id int64, attempt int, digestID int64, telegramMessageIDs []int, plainText bool) error
+	ScheduleRetry(ctx context.Context, id int64, attempt int, at time.Time, reason string) error
+	Postpone(ctx context.Context, id int64, attempt int, at time.Time) error
+	MarkFailed(ctx context.Context, id int64, attempt int, reason string) error
+	GetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64) (int, error)
+	SetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64, telegramMessageID int) error
+	GetThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64) (int, error)
+	AddThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64, telegramMessageID int, expiresAt time.Time) error
+	MarkSuppressed(ctx context.Context, id int64) error
+	GetDedupState(ctx context.Context, companyID, chatID int64, dedupKey string) (entities.DedupState, error)
+	AddSuppressed(ctx context.Context, companyID, chatID int64) error
+	AddDedupKey(ctx context.Context, companyID, chatID int64, dedupKey string, expiresAt time.Time, reported int) error
+	DeleteFinishedMessages(ctx context.Context, retention time.Duration, limit int) (int64, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type chatStatusStorage interface {
+	MigrateChat(ctx context.Context, from, to int64) error
+	DeactivateChat(ctx context.Context, telegramID int64) ([]int64, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type botSender interface {
+	SendMessage(ctx context.Context, msg entities.Message) []entities.SendResult
+}
+
+const (
+	// recordTimeout limits the time to record the result of a sent delivery.
+	recordTimeout = 5 * time.Second
+	// cleanupInterval is how often finished outbox messages are deleted.
+	cleanupInterval = time.Hour
+	// cleanupBatchSize limits the number of messages deleted at once.
+	cleanupBatchSize = 1000
+)
+
+// DeliveryOptions represents the settings of the outbox delivery workers.
+type DeliveryOptions struct {
+	Workers        int
+	BatchSize      int
+	PollInterval   time.Duration
+	Lease          time.Duration
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// ThreadKeyTTL is how long messages with a thread key are sent as replies to the first message with the key.
+	ThreadKeyTTL time.Duration
+	// Retention is how long messages are kept in the outbox after they are sent, 0 keeps them forever.
+	// It should be longer than the idempotency keys live, repeated requests are answered with the stored deliveries.
+	Retention time.Duration
+}
+
+type deliveryUsecases struct {
+	logger *slog.Logger
+	ds     deliveryStorage
+	cs     chatStatusStorage
+	bs     botSender
+	opts   DeliveryOptions
+	now    func() time.Time
+}
+
+// NewDeliveryUsecases creates a new instance of deliveryUsecases, which drains the outbox and sends queued messages with the bot.
+func NewDeliveryUsecases(logger *slog.Logger, ds deliveryStorage, cs chatStatusStorage, bs botSender, opts DeliveryOptions) *deliveryUsecases {
+	return &deliveryUsecases{
+		logger: logger,
+		ds:     ds,
+		cs:     cs,
+		bs:     bs,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// Run starts the configured number of workers and blocks until the context is cancelled and all workers are stopped.
+// If the retention is set, finished messages older than it are deleted from the outbox every cleanupInterval.
+func (u *deliveryUsecases) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < u.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.work(ctx)
+		}()
+	}
+
+	if u.opts.Retention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.cleanup(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (u *deliveryUsecases) cleanup(ctx context.Context) {
+	for {
+		u.DeleteFinished(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cleanupInterval):
+		}
+	}
+}
+
+// DeleteFinished deletes the messages stored in the outbox longer than the retention whose deliveries are all finished.
+// It returns the number of deleted messages.
+func (u *deliveryUsecases) DeleteFinished(ctx context.Context) int64 {
+	const op = "usecases.DeleteFinished"
+	logger := u.logger.With(slog.String("operation", op))
+
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := u.ds.DeleteFinishedMessages(ctx, u.opts.Retention, cleanupBatchSize)
+		if err != nil {
+			logger.Error("can not delete finished messages", sl.Err(err))
+			break
+		}
+
+		total += deleted
+		if deleted < cleanupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("finished messages deleted", slog.Int64("messages", total))
+	}
+
+	return total
+}
+
+func (u *deliveryUsecases) work(ctx context.Context) {
+	for {
+		if u.ProcessBatch(ctx) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(u.opts.PollInterval):
+		}
+	}
+}
+
+// ProcessBatch claims a batch of due deliveries and sends them.
+// It returns the number of processed deliveries.
+func (u *deliveryUsecases) ProcessBatch(ctx context.Context) int {
+	const op = "usecases.ProcessBatch"
+	logger := u.logger.With(slog.String("operation", op))
+
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	// the lease of the whole batch starts when it is claimed, every delivery must be finished before it expires,
+	// otherwise another worker can claim it again
+	deadline := u.now().Add(u.opts.Lease)
+
+	deliveries, err := u.ds.ClaimDeliveries(ctx, u.opts.BatchSize, u.opts.Lease)
+	if err != nil {
+		logger.Error("can not claim deliveries", sl.Err(err))
+		return 0
+	}
+
+	// the digest deliveries of a chat are due at the same time, so several of them can be claimed in one batch,
+	// they are sent in one digest with the first of them
+	digests := make(map[digestChat][]entities.Delivery)
+	for _, d := range deliveries {
+		if _, ok := d.Message.Digests[d.ChatID]; ok {
+			key := digestChat{companyID: d.Message.CompanyID, chatID: d.ChatID}
+			digests[key] = append(digests[key], d)
+		}
+	}
+
+	for _, d := range deliveries {
+		var claimed []entities.Delivery
+		if _, ok := d.Message.Digests[d.ChatID]; ok {
+			key := digestChat{companyID: d.Message.CompanyID, chatID: d.ChatID}
+			group, ok := digests[key]
+			if !ok {
+				continue
+			}
+			delete(digests, key)
+			claimed = group[1:]
+		}
+
+		u.deliver(ctx, d, deadline, claimed)
+	}
+
+	return len(deliveries)
+}
+
+// digestChat identifies the digest of a chat of a company.
+type digestChat struct {
+	companyID int64
+	chatID    int64
+}
+
+// Deliver sends the delivery to its chat before the deadline and records the result in the outbox.
+// Deliveries with a correlation ID edit the message sent earlier to the chat with the same ID, if there is one.
+// Non-urgent deliveries to chats in quiet hours are postponed until the quiet hours end.
+// Deliveries to chats in digest mode are sent in one message with the rest of the digest.
+// Deliveries with a thread key reply to the first message sent to the chat with the key until it expires.
+// If the company suppresses duplicates, new messages that duplicate a message sent to the chat within the window are not sent,
+// the number of them is appended to the next message sent to the chat. Digests are never suppressed.
+// Deliveries rejected by Telegram for good are marked as failed, other failures are retried with backoff
+// until the attempts are exhausted.
+// When a group is upgraded to a supergroup, its chats are moved to the new ID. When the bot is blocked or removed from a chat,
+// the chat is turned off for all companies and their owners are notified in private messages.
+func (u *deliveryUsecases) Deliver(ctx context.Context, d entities.Delivery, deadline time.Time) entities.SendResult {
+	return u.deliver(ctx, d, deadline, nil)
+}
+
+// deliver sends the delivery like Deliver, the claimed deliveries of the digest of the chat are sent in the digest with it.
+func (u *deliveryUsecases) deliver(ctx context.Context, d entities.Delivery, deadline time.Time, claimed []entities.Delivery) entities.SendResult {
+	const op = "usecases.Deliver"
+	logger := u.logger.With(
+		slog.String("operation", op),
+		slog.Int64("delivery_id", d.ID),
+		slog.Int64("chat_id", d.ChatID),
+		slog.Int("attempt", d.Attempts),
+	)
+
+	msg := d.Message
+	msg.ChatIds = []int64{d.ChatID}
+
+	if q, ok := msg.QuietHours[d.ChatID]; ok && !msg.Urgent {
+		if until, quiet := q.QuietUntil(u.now()); quiet {
+			logger.Debug("quiet hours, delivery postponed", slog.Time("next_attempt_at", until))
+			for _, p := range append([]entities.Delivery{d}, claimed...) {
+				if err := u.ds.Postpone(ctx, p.ID, p.Attempts, until); err != nil {
+					logger.Error("can not postpone delivery", slog.Int64("postponed_delivery_id", p.ID), sl.Err(err))
+				}
+			}
+			return entities.SendResult{ChatID: d.ChatID, Status: entities.SendStatusQueued}
+		}
+	}
+
+	if _, ok := msg.Digests[d.ChatID]; ok {
+		return u.deliverDigest(ctx, logger, d, msg, deadline, claimed)
+	}
+
+	if msg.CorrelationID != "" {
+		id, err := u.ds.GetCorrelatedMessageId(ctx, msg.CompanyID, msg.CorrelationID, d.ChatID)
+		switch {
+		case err == nil:
+			msg.EditMessageID = id
+		case !errors.Is(err, storage.ErrNotFound):
+			logger.Error("can not get correlated message", sl.Err(err))
+		}
+	}
+
+	// edits of correlated messages are never duplicates
+	var dedup entities.DedupState
+	var key string
+	if msg.EditMessageID == 0 {
+		key = dedupKey(msg)
+	}
+	if key != "" {
+		state, err := u.ds.GetDedupState(ctx, msg.CompanyID, d.ChatID, key)
+		switch {
+		case err == nil:
+			dedup = state
+		case !errors.Is(err, storage.ErrNotFound):
+			logger.Error("can not get deduplication state", sl.Err(err))
+		}
+	}
+	if dedup.Window > 0 {
+		if dedup.Duplicate {
+			return u.suppress(ctx, logger, d)
+		}
+		if dedup.Suppressed > 0 {
+			msg.Text = withSuppressed(msg.Text, dedup.Suppressed)
+		}
+	}
+
+	// the first message with the thread key starts the thread, it is stored once it is sent
+	var startsThread bool
+	if msg.ThreadKey != "" {
+		id, err := u.ds.GetThreadMessageId(ctx, msg.CompanyID, msg.ThreadKey, d.ChatID)
+		switch {
+		case err == nil:
+			msg.ReplyToMessageID = id
+		case errors.Is(err, storage.ErrNotFound):
+			startsThread = true
+		default:
+			logger.Error("can not get thread message", sl.Err(err))
+		}
+	}
+
+	result := u.send(ctx, msg, deadline)
+
+	// the message may be sent even if the request is gone, its result must be recorded anyway
+	// or the delivery is claimed and sent again
+	ctx, cancel := recordContext()
+	defer cancel()
+
+	if result.Status == entities.SendStatusSent {
+		// the message of an upgraded group was sent to the supergroup, later messages are sent there too
+		chatID := d.ChatID
+		if result.MigratedTo != 0 {
+			chatID = result.MigratedTo
+		}
+
+		if err := u.ds.MarkDelivered(ctx, d.ID, d.Attempts, 0, result.MessageIDs, result.PlainText); err != nil {
+			logger.Error("can not mark delivery as delivered", sl.Err(err))
+		}
+		if msg.CorrelationID != "" && result.MessageID != msg.EditMessageID {
+			if err := u.ds.SetCorrelatedMessageId(ctx, msg.CompanyID, msg.CorrelationID, chatID, result.MessageID); err != nil {
+				logger.Error("can not save correlated message", sl.Err(err))
+			}
+		}
+		if dedup.Window > 0 {
+			expiresAt := u.now().Add(time.Duration(dedup.Window) * time.Second)
+			if err := u.ds.AddDedupKey(ctx, msg.CompanyID, chatID, key, expiresAt, dedup.Suppressed); err != nil {
+				logger.Error("can not save deduplication key", sl.Err(err))
+			}
+		}
+		if startsThread {
+			expiresAt := u.now().Add(u.opts.ThreadKeyTTL)
+			if err := u.ds.AddThreadMessageId(ctx, msg.CompanyID, msg.ThreadKey, chatID, result.MessageID, expiresAt); err != nil {
+				logger.Error("can not save thread message", sl.Err(err))
+			}
+		}
+		return result
+	}
+
+	u.fail(ctx, logger, d, result)
+
+	return result
+}
+
+// suppress marks the delivery as a suppressed duplicate and counts it for the chat.
+func (u *deliveryUsecases) suppress(ctx context.Context, logger *slog.Logger, d entities.Delivery) entities.SendResult {
+	logger.Debug("duplicate suppressed")
+
+	if err := u.ds.MarkSuppressed(ctx, d.ID); err != nil {
+		logger.Error("can not mark delivery as suppressed", sl.Err(err))
+	}
+	if err := u.ds.AddSuppressed(ctx, d.Message.CompanyID, d.ChatID); err != nil {
+		logger.Error("can not count suppressed duplicate", sl.Err(err))
+	}
+
+	return entities.SendResult{ChatID: d.ChatID, Status: entities.SendStatusSuppressed}
+}
+
+// send sends the message to its only chat before the deadline and returns the result.
+func (u *deliveryUsecases) send(ctx context.Context, msg entities.Message, deadline time.Time) entities.SendResult {
+	sendCtx, cancel := context.WithDeadline(ctx, deadline)
+	results := u.bs.SendMessage(sendCtx, msg)
+	cancel()
+
+	if len(results) == 0 {
+		return entities.SendResult{
+			ChatID: msg.ChatIds[0],
+			Status: entities.SendStatusFailed,
+			Error:  "no result from bot",
+		}
+	}
+
+	recordCtx, recordCancel := recordContext()
+	u.updateChat(recordCtx, results[0])
+	recordCancel()
+
+	return results[0]
+}
+
+// recordContext returns the context the result of a sent delivery is recorded with,
+// it is not canceled with the context of the request the delivery was sent for.
+func recordContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), recordTimeout)
+}
+
+// updateChat moves the chat to the supergroup it was upgraded to
+// and turns the chat off if the bot is not allowed to post to it anymore.
+func (u *deliveryUsecases) updateChat(ctx context.Context, result entities.SendResult) {
+	const op = "usecases.updateChat"
+	logger := u.logger.With(slog.String("operation", op), slog.Int64("chat_id", result.ChatID))
+
+	chatID := result.ChatID
+	if result.MigratedTo != 0 {
+		logger.Info("group upgraded to supergroup", slog.Int64("migrated_to", result.MigratedTo))
+		if err := u.cs.MigrateChat(ctx, result.ChatID, result.MigratedTo); err != nil {
+			logger.Error("can not migrate chat", sl.Err(err))
+		}
+		chatID = result.MigratedTo
+	}
+
+	if result.Status != entities.SendStatusForbidden {
+		return
+	}
+
+	owners, err := u.cs.DeactivateChat(ctx, chatID)
+	if err != nil {
+		logger.Error("can not deactivate chat", sl.Err(err))
+		return
+	}
+	if len(owners) == 0 {
+		return
+	}
+
+	logger.Warn("bot can not post to chat, chat deactivated", slog.String("error", result.Error))
+
+	notice := entities.Message{
+		Text: fmt.Sprintf("The bot can not send messages to the chat %d anymore, it was blocked or removed from the chat (%s). "+
+			"Messages are not sent to the chat until you add the bot back and send /addchat %d again.", chatID, result.Error, chatID),
+		ChatIds: owners,
+	}
+	for _, r := range u.bs.SendMessage(ctx, notice) {
+		if r.Status != entities.SendStatusSent {
+			logger.Error("can not notify company owner", slog.Int64("owner_id", r.ChatID), slog.String("error", r.Error))
+		}
+	}
+}
+
+// fail records the failed attempt of the delivery, the delivery is retried with backoff
+// unless Telegram rejected it for good or the attempts are exhausted.
+func (u *deliveryUsecases) fail(ctx context.Context, logger *slog.Logger, d entities.Delivery, result entities.SendResult) {
+	if !result.Status.Retryable() || d.Attempts >= u.opts.MaxAttempts {
+		logger.Error("delivery failed", slog.String("status", string(result.Status)), slog.String("error", result.Error))
+		if err := u.ds.MarkFailed(ctx, d.ID, d.Attempts, result.Error); err != nil {
+			logger.Error("can not mark delivery as failed", sl.Err(err))
+		}
+		return
+	}
+
+	next := u.now().Add(u.backoff(d.Attempts))
+	logger.Warn("delivery failed, retry scheduled",
+		slog.String("status", string(result.Status)),
+		slog.String("error", result.Error),
+		slog.Time("next_attempt_at", next),
+	)
+	if err := u.ds.ScheduleRetry(ctx, d.ID, d.Attempts, next, result.Error); err != nil {
+		logger.Error("can not schedule retry", sl.Err(err))
+	}
+}
+
+// backoff returns the delay before the next attempt, doubling it after every failed attempt up to RetryMaxDelay.
+func (u *deliveryUsecases) backoff(attempts int) time.Duration {
+	delay := u.opts.RetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= u.opts.RetryMaxDelay {
+			return u.opts.RetryMaxDelay
+		}
+	}
+
+	if delay > u.opts.RetryMaxDelay {
+		return u.opts.RetryMaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,625 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/slogdiscard"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/internal/usecases/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_deliveryUsecases_ProcessBatch(t *testing.T) {
+	opts := DeliveryOptions{
+		Workers:        1,
+		BatchSize:      10,
+		PollInterval:   time.Second,
+		Lease:          time.Minute,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+		ThreadKeyTTL:   time.Hour,
+	}
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	delivery := func(attempts int) entities.Delivery {
+		return entities.Delivery{
+			ID:        1,
+			MessageID: 2,
+			ChatID:    123,
+			Status:    entities.DeliverySending,
+			Attempts:  attempts,
+			Message: entities.Message{
+				Text:      "text",
+				ParseMode: entities.HTML,
+				CompanyID: 12,
+				ChatIds:   []int64{123},
+			},
+		}
+	}
+
+	correlated := func() entities.Delivery {
+		d := delivery(1)
+		d.Message.CorrelationID = "run-1"
+		return d
+	}
+
+	threaded := func() entities.Delivery {
+		d := delivery(1)
+		d.Message.ThreadKey = "run-1"
+		return d
+	}
+
+	quiet := func(urgent bool) entities.Delivery {
+		d := delivery(1)
+		d.Message.QuietHours = map[int64]entities.QuietHours{123: {Start: 600, End: 780, TimeZone: "UTC"}}
+		d.Message.Urgent = urgent
+		return d
+	}
+
+	sent := entities.SendResult{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}}
+	failed := entities.SendResult{ChatID: 123, Status: entities.SendStatusFailed, Error: "telegram error"}
+	forbidden := entities.SendResult{ChatID: 123, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was kicked"}
+
+	tests := []struct {
+		name       string
+		deliveries []entities.Delivery
+		claimError error
+		sendResult entities.SendResult
+		editID     int
+		replyToID  int
+		text       string
+		notSent    bool
+		wantCount  int
+		prepare    func(ds *mocks.MockdeliveryStorage)
+	}{
+		{
+			name:       "delivered",
+			deliveries: []entities.Delivery{delivery(1)},
+			sendResult: sent,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "retry scheduled",
+			deliveries: []entities.Delivery{delivery(2)},
+			sendResult: failed,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().ScheduleRetry(gomock.Any(), int64(1), 2, now.Add(2*time.Second), "telegram error").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "no attempts left",
+			deliveries: []entities.Delivery{delivery(3)},
+			sendResult: failed,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 3, "telegram error").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "not retryable",
+			deliveries: []entities.Delivery{delivery(1)},
+			sendResult: forbidden,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was kicked").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "first correlated message",
+			deliveries: []entities.Delivery{correlated()},
+			sendResult: sent,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(0, storage.ErrNotFound).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().SetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123), 55).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "correlated message edited",
+			deliveries: []entities.Delivery{correlated()},
+			sendResult: sent,
+			editID:     55,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(55, nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "correlated message replaced",
+			deliveries: []entities.Delivery{correlated()},
+			sendResult: sent,
+			editID:     40,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(40, nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().SetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123), 55).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "first message with thread key",
+			deliveries: []entities.Delivery{threaded()},
+			sendResult: sent,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetThreadMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(0, storage.ErrNotFound).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().AddThreadMessageId(gomock.Any(), int64(12), "run-1", int64(123), 55, now.Add(time.Hour)).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "reply to thread",
+			deliveries: []entities.Delivery{threaded()},
+			sendResult: sent,
+			replyToID:  40,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetThreadMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(40, nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "failed first message with thread key",
+			deliveries: []entities.Delivery{threaded()},
+			sendResult: forbidden,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetThreadMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(0, storage.ErrNotFound).Times(1)
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was kicked").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "postponed in quiet hours",
+			deliveries: []entities.Delivery{quiet(false)},
+			notSent:    true,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().Postpone(gomock.Any(), int64(1), 1, time.Date(2023, 9, 1, 13, 0, 0, 0, time.UTC)).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "urgent in quiet hours",
+			deliveries: []entities.Delivery{quiet(true)},
+			sendResult: sent,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "duplicate suppressed",
+			deliveries: []entities.Delivery{delivery(1)},
+			notSent:    true,
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(123), dedupKey(delivery(1).Message)).
+					Return(entities.DedupState{Window: 600, Duplicate: true}, nil).Times(1)
+				ds.EXPECT().MarkSuppressed(gomock.Any(), int64(1)).Return(nil).Times(1)
+				ds.EXPECT().AddSuppressed(gomock.Any(), int64(12), int64(123)).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "suppressed duplicates reported",
+			deliveries: []entities.Delivery{delivery(1)},
+			sendResult: sent,
+			text:       "text\n\nsuppressed 2 duplicates",
+			wantCount:  1,
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(123), dedupKey(delivery(1).Message)).
+					Return(entities.DedupState{Window: 600, Suppressed: 2}, nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().AddDedupKey(gomock.Any(), int64(12), int64(123), dedupKey(delivery(1).Message), now.Add(10*time.Minute), 2).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "claim error",
+			claimError: errors.New("db error"),
+			wantCount:  0,
+			prepare:    func(ds *mocks.MockdeliveryStorage) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ds := mocks.NewMockdeliveryStorage(ctrl)
+			ds.EXPECT().ClaimDeliveries(gomock.Any(), opts.BatchSize, opts.Lease).Return(tt.deliveries, tt.claimError).Times(1)
+			tt.prepare(ds)
+			ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(123), gomock.Any()).Return(entities.DedupState{}, nil).AnyTimes()
+
+			cs := mocks.NewMockchatStatusStorage(ctrl)
+			cs.EXPECT().DeactivateChat(gomock.Any(), int64(123)).Return([]int64{}, nil).AnyTimes()
+
+			bs := mocks.NewMockbotSender(ctrl)
+			for _, d := range tt.deliveries {
+				if tt.notSent {
+					continue
+				}
+				msg := d.Message
+				msg.EditMessageID = tt.editID
+				if tt.replyToID != 0 {
+					msg.ReplyToMessageID = tt.replyToID
+				}
+				if tt.text != "" {
+					msg.Text = tt.text
+				}
+				bs.EXPECT().SendMessage(gomock.Any(), msg).Return([]entities.SendResult{tt.sendResult}).Times(1)
+			}
+
+			u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, cs, bs, opts)
+			u.now = func() time.Time { return now }
+
+			assert.Equal(t, tt.wantCount, u.ProcessBatch(context.Background()))
+		})
+	}
+}
+
+func Test_deliveryUsecases_ProcessBatch_Digest(t *testing.T) {
+	opts := DeliveryOptions{
+		Workers:        1,
+		BatchSize:      10,
+		PollInterval:   time.Second,
+		Lease:          time.Minute,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+	}
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	delivery := func(id int64, text string, parseMode entities.ParseMode) entities.Delivery {
+		return entities.Delivery{
+			ID:        id,
+			MessageID: id + 10,
+			ChatID:    123,
+			Status:    entities.DeliverySending,
+			Attempts:  1,
+			Message: entities.Message{
+				Text:      text,
+				ParseMode: parseMode,
+				CompanyID: 12,
+				ChatIds:   []int64{123},
+				Digests:   map[int64]time.Duration{123: 5 * time.Minute},
+			},
+		}
+	}
+	first := delivery(1, "first", entities.HTML)
+	second := delivery(2, "second", entities.HTML)
+	markdown := delivery(3, "*third*", entities.MarkdownV2)
+
+	digest := first.Message
+	digest.Text = "2 messages\n\nfirst\n\nsecond"
+
+	tests := []struct {
+		name       string
+		batch      []entities.Delivery
+		others     []entities.Delivery
+		sendResult entities.SendResult
+		prepare    func(ds *mocks.MockdeliveryStorage)
+	}{
+		{
+			name:       "delivered",
+			batch:      []entities.Delivery{first},
+			others:     []entities.Delivery{second, markdown},
+			sendResult: entities.SendResult{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}},
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(1), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(2), 1, int64(1), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "digest claimed in batch",
+			batch:      []entities.Delivery{first, second, markdown},
+			others:     []entities.Delivery{},
+			sendResult: entities.SendResult{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}},
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(1), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(2), 1, int64(1), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "retry scheduled",
+			batch:      []entities.Delivery{first},
+			others:     []entities.Delivery{second, markdown},
+			sendResult: entities.SendResult{ChatID: 123, Status: entities.SendStatusFailed, Error: "telegram error"},
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().ScheduleRetry(gomock.Any(), int64(1), 1, now.Add(time.Second), "telegram error").Return(nil).Times(1)
+				ds.EXPECT().ScheduleRetry(gomock.Any(), int64(2), 1, now.Add(time.Second), "telegram error").Return(nil).Times(1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ds := mocks.NewMockdeliveryStorage(ctrl)
+			ds.EXPECT().ClaimDeliveries(gomock.Any(), opts.BatchSize, opts.Lease).Return(tt.batch, nil).Times(1)
+			ds.EXPECT().ClaimDigest(gomock.Any(), int64(12), int64(123), opts.Lease).Return(tt.others, nil).Times(1)
+			ds.EXPECT().Postpone(gomock.Any(), int64(3), 1, now).Return(nil).Times(1)
+			tt.prepare(ds)
+
+			bs := mocks.NewMockbotSender(ctrl)
+			bs.EXPECT().SendMessage(gomock.Any(), digest).Return([]entities.SendResult{tt.sendResult}).Times(1)
+
+			u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, mocks.NewMockchatStatusStorage(ctrl), bs, opts)
+			u.now = func() time.Time { return now }
+
+			assert.Equal(t, len(tt.batch), u.ProcessBatch(context.Background()))
+		})
+	}
+}
+
+func Test_deliveryUsecases_Deliver_ChatStatus(t *testing.T) {
+	opts := DeliveryOptions{
+		Lease:          time.Minute,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+	}
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	d := entities.Delivery{
+		ID:        1,
+		MessageID: 2,
+		ChatID:    -123,
+		Status:    entities.DeliverySending,
+		Attempts:  1,
+		Message: entities.Message{
+			Text:      "text",
+			CompanyID: 12,
+			ChatIds:   []int64{-123},
+		},
+	}
+	notice := func(chatID int64, reason string) entities.Message {
+		return entities.Message{
+			Text: fmt.Sprintf("The bot can not send messages to the chat %d anymore, it was blocked or removed from the chat (%s). "+
+				"Messages are not sent to the chat until you add the bot back and send /addchat %d again.", chatID, reason, chatID),
+			ChatIds: []int64{368414991},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		threadKey  string
+		sendResult entities.SendResult
+		prepare    func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender)
+	}{
+		{
+			name:       "group upgraded to supergroup",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}, MigratedTo: -100123},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				cs.EXPECT().MigrateChat(gomock.Any(), int64(-123), int64(-100123)).Return(nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "thread started in upgraded group",
+			threadKey:  "run-1",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}, MigratedTo: -100123},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				ds.EXPECT().GetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(-123)).Return(0, storage.ErrNotFound).Times(1)
+				ds.EXPECT().GetThreadMessageId(gomock.Any(), int64(12), "run-1", int64(-123)).Return(0, storage.ErrNotFound).Times(1)
+				cs.EXPECT().MigrateChat(gomock.Any(), int64(-123), int64(-100123)).Return(nil).Times(1)
+				ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).Return(nil).Times(1)
+				ds.EXPECT().SetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(-100123), 55).Return(nil).Times(1)
+				ds.EXPECT().AddThreadMessageId(gomock.Any(), int64(12), "run-1", int64(-100123), 55, now).Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "bot kicked",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was kicked from the group chat"},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				cs.EXPECT().DeactivateChat(gomock.Any(), int64(-123)).Return([]int64{368414991}, nil).Times(1)
+				bs.EXPECT().SendMessage(gomock.Any(), notice(-123, "Forbidden: bot was kicked from the group chat")).
+					Return([]entities.SendResult{{ChatID: 368414991, Status: entities.SendStatusSent}}).Times(1)
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was kicked from the group chat").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "bot kicked from deactivated chat",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was kicked from the group chat"},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				cs.EXPECT().DeactivateChat(gomock.Any(), int64(-123)).Return([]int64{}, nil).Times(1)
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was kicked from the group chat").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "bot kicked from upgraded group",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was kicked from the supergroup chat", MigratedTo: -100123},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				cs.EXPECT().MigrateChat(gomock.Any(), int64(-123), int64(-100123)).Return(nil).Times(1)
+				cs.EXPECT().DeactivateChat(gomock.Any(), int64(-100123)).Return([]int64{368414991}, nil).Times(1)
+				bs.EXPECT().SendMessage(gomock.Any(), notice(-100123, "Forbidden: bot was kicked from the supergroup chat")).
+					Return([]entities.SendResult{{ChatID: 368414991, Status: entities.SendStatusForbidden}}).Times(1)
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was kicked from the supergroup chat").Return(nil).Times(1)
+			},
+		},
+		{
+			name:       "deactivate error",
+			sendResult: entities.SendResult{ChatID: -123, Status: entities.SendStatusForbidden, Error: "Forbidden: bot was blocked by the user"},
+			prepare: func(ds *mocks.MockdeliveryStorage, cs *mocks.MockchatStatusStorage, bs *mocks.MockbotSender) {
+				cs.EXPECT().DeactivateChat(gomock.Any(), int64(-123)).Return(nil, errors.New("db error")).Times(1)
+				ds.EXPECT().MarkFailed(gomock.Any(), int64(1), 1, "Forbidden: bot was blocked by the user").Return(nil).Times(1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ds := mocks.NewMockdeliveryStorage(ctrl)
+			ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(-123), gomock.Any()).Return(entities.DedupState{}, storage.ErrNotFound).AnyTimes()
+			cs := mocks.NewMockchatStatusStorage(ctrl)
+			bs := mocks.NewMockbotSender(ctrl)
+			d := d
+			d.Message.CorrelationID = tt.threadKey
+			d.Message.ThreadKey = tt.threadKey
+			bs.EXPECT().SendMessage(gomock.Any(), d.Message).Return([]entities.SendResult{tt.sendResult}).Times(1)
+			tt.prepare(ds, cs, bs)
+
+			u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, cs, bs, opts)
+			u.now = func() time.Time { return now }
+
+			assert.Equal(t, tt.sendResult, u.Deliver(context.Background(), d, now.Add(time.Minute)))
+		})
+	}
+}
+
+func Test_deliveryUsecases_Deliver_RequestCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	d := entities.Delivery{
+		ID:        1,
+		MessageID: 2,
+		ChatID:    123,
+		Status:    entities.DeliverySending,
+		Attempts:  1,
+		Message: entities.Message{
+			Text:          "text",
+			CompanyID:     12,
+			ChatIds:       []int64{123},
+			CorrelationID: "run-1",
+		},
+	}
+	sent := entities.SendResult{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := mocks.NewMockdeliveryStorage(ctrl)
+	ds.EXPECT().GetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123)).Return(0, storage.ErrNotFound).Times(1)
+	ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(123), gomock.Any()).Return(entities.DedupState{}, storage.ErrNotFound).Times(1)
+	// the request is gone once the message is sent, the result is recorded anyway
+	ds.EXPECT().MarkDelivered(gomock.Any(), int64(1), 1, int64(0), []int{55}, false).DoAndReturn(
+		func(ctx context.Context, _ int64, _ int, _ int64, _ []int, _ bool) error {
+			assert.NoError(t, ctx.Err())
+			return nil
+		}).Times(1)
+	ds.EXPECT().SetCorrelatedMessageId(gomock.Any(), int64(12), "run-1", int64(123), 55).DoAndReturn(
+		func(ctx context.Context, _ int64, _ string, _ int64, _ int) error {
+			assert.NoError(t, ctx.Err())
+			return nil
+		}).Times(1)
+
+	bs := mocks.NewMockbotSender(ctrl)
+	bs.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, entities.Message) []entities.SendResult {
+			cancel()
+			return []entities.SendResult{sent}
+		}).Times(1)
+
+	u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, mocks.NewMockchatStatusStorage(ctrl), bs, DeliveryOptions{})
+	u.now = func() time.Time { return now }
+
+	assert.Equal(t, sent, u.Deliver(ctx, d, now.Add(time.Minute)))
+}
+
+func Test_deliveryUsecases_ProcessBatch_Lease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	opts := DeliveryOptions{BatchSize: 10, Lease: time.Minute}
+	start := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	delivery := func(id int64) entities.Delivery {
+		return entities.Delivery{
+			ID:        id,
+			MessageID: 2,
+			ChatID:    123,
+			Status:    entities.DeliverySending,
+			Message:   entities.Message{Text: "text", CompanyID: 12, ChatIds: []int64{123}},
+		}
+	}
+
+	ds := mocks.NewMockdeliveryStorage(ctrl)
+	ds.EXPECT().ClaimDeliveries(gomock.Any(), opts.BatchSize, opts.Lease).Return([]entities.Delivery{delivery(1), delivery(2)}, nil).Times(1)
+	ds.EXPECT().GetDedupState(gomock.Any(), int64(12), int64(123), gomock.Any()).Return(entities.DedupState{}, storage.ErrNotFound).Times(2)
+	ds.EXPECT().MarkDelivered(gomock.Any(), gomock.Any(), 0, int64(0), []int{55}, false).Return(nil).Times(2)
+
+	bs := mocks.NewMockbotSender(ctrl)
+	bs.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ entities.Message) []entities.SendResult {
+			// the deadline of every delivery is the end of the lease of the batch
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.Equal(t, start.Add(opts.Lease), deadline)
+			now = now.Add(40 * time.Second)
+			return []entities.SendResult{{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55, MessageIDs: []int{55}}}
+		}).Times(2)
+
+	u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, mocks.NewMockchatStatusStorage(ctrl), bs, opts)
+	u.now = func() time.Time { return now }
+
+	assert.Equal(t, 2, u.ProcessBatch(context.Background()))
+}
+
+func Test_deliveryUsecases_backoff(t *testing.T) {
+	u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), nil, nil, nil, DeliveryOptions{
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  10 * time.Second,
+	})
+
+	assert.Equal(t, time.Second, u.backoff(1))
+	assert.Equal(t, 2*time.Second, u.backoff(2))
+	assert.Equal(t, 8*time.Second, u.backoff(4))
+	assert.Equal(t, 10*time.Second, u.backoff(5))
+	assert.Equal(t, 10*time.Second, u.backoff(50))
+}
+
+func Test_deliveryUsecases_DeleteFinished(t *testing.T) {
+	opts := DeliveryOptions{Retention: 24 * time.Hour}
+
+	tests := []struct {
+		name    string
+		prepare func(ds *mocks.MockdeliveryStorage)
+		want    int64
+	}{
+		{
+			name: "in batches",
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				gomock.InOrder(
+					ds.EXPECT().DeleteFinishedMessages(gomock.Any(), opts.Retention, cleanupBatchSize).Return(int64(cleanupBatchSize), nil).Times(1),
+					ds.EXPECT().DeleteFinishedMessages(gomock.Any(), opts.Retention, cleanupBatchSize).Return(int64(3), nil).Times(1),
+				)
+			},
+			want: cleanupBatchSize + 3,
+		},
+		{
+			name: "with error",
+			prepare: func(ds *mocks.MockdeliveryStorage) {
+				ds.EXPECT().DeleteFinishedMessages(gomock.Any(), opts.Retention, cleanupBatchSize).Return(int64(0), errors.New("test error")).Times(1)
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ds := mocks.NewMockdeliveryStorage(ctrl)
+			tt.prepare(ds)
+
+			u := NewDeliveryUsecases(slogdiscard.NewDiscardLogger(), ds, nil, nil, opts)
+
+			assert.Equal(t, tt.want, u.DeleteFinished(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/logger/sl"
+	"golang.org/x/exp/slog"
+)
+
+// deliverDigest sends the delivery together with the other deliveries collected into the digest of its chat
+// as one message listing them, the bot splits the message if it is too long.
+// Deliveries in another parse mode can not be listed in the message, they are returned to the queue to make a digest of their own.
+// The deliveries of the digest claimed with the delivery are sent in it too.
+// The result of the message is recorded for every delivery of the digest,
+// the deliveries share the Telegram messages, so they are marked with the ID of the delivery as the ID of the digest.
+func (u *deliveryUsecases) deliverDigest(ctx context.Context, logger *slog.Logger, d entities.Delivery, msg entities.Message, deadline time.Time, claimed []entities.Delivery) entities.SendResult {
+	others, err := u.ds.ClaimDigest(ctx, msg.CompanyID, d.ChatID, u.opts.Lease)
+	if err != nil {
+		logger.Error("can not claim digest", sl.Err(err))
+	}
+	others = append(claimed, others...)
+
+	digest := []entities.Delivery{d}
+	for _, o := range others {
+		if o.Message.ParseMode != msg.ParseMode {
+			if err := u.ds.Postpone(ctx, o.ID, o.Attempts, u.now()); err != nil {
+				logger.Error("can not return delivery to the queue", slog.Int64("digest_delivery_id", o.ID), sl.Err(err))
+			}
+			continue
+		}
+		digest = append(digest, o)
+	}
+
+	msg.Text = digestText(digest)
+	result := u.send(ctx, msg, deadline)
+
+	// the result is recorded even if the request is gone, see Deliver
+	ctx, cancel := recordContext()
+	defer cancel()
+
+	var digestID int64
+	if len(digest) > 1 {
+		digestID = d.ID
+	}
+
+	for _, dd := range digest {
+		if result.Status != entities.SendStatusSent {
+			u.fail(ctx, logger.With(slog.Int64("digest_delivery_id", dd.ID)), dd, result)
+			continue
+		}
+		if err := u.ds.MarkDelivered(ctx, dd.ID, dd.Attempts, digestID, result.MessageIDs, result.PlainText); err != nil {
+			logger.Error("can not mark delivery as delivered", slog.Int64("digest_delivery_id", dd.ID), sl.Err(err))
+		}
+	}
+
+	logger.Debug("digest sent", slog.Int("messages", len(digest)), slog.String("status", string(result.Status)))
+
+	return result
+}
+
+// digestText lists the texts of the deliveries separated by blank lines under the number of messages.
+// A single delivery is sent as it is.
+func digestText(deliveries []entities.Delivery) string {
+	if len(deliveries) == 1 {
+		return deliveries[0].Message.Text
+	}
+
+	texts := make([]string, 0, len(deliveries)+1)
+	texts = append(texts, fmt.Sprintf("%d messages", len(deliveries)))
+	for _, d := range deliveries {
+		texts = append(texts, d.Message.Text)
+	}
+
+	return strings.Join(texts, "\n\n")
+}
@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type deliveryGeter interface {
+	GetDeliveries(ctx context.Context, messageID int64, token string) ([]entities.Delivery, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type deliveryDeleter interface {
+	GetDeliveriesByFilter(ctx context.Context, token string, filter entities.MessageFilter, limit int) ([]entities.Delivery, error)
+	MarkDeleted(ctx context.Context, id int64) error
+	CountDigestDelivered(ctx context.Context, digestID, exceptID int64) (int, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type botDeleter interface {
+	DeleteMessages(ctx context.Context, chatID int64, messageIDs []int) error
+}
+
+// MaxDeletedMessages is the maximum number of messages deleted by one call of DeleteMessages.
+const MaxDeletedMessages = 100
+
+type messageUsecases struct {
+	dg      deliveryGeter
+	dd      deliveryDeleter
+	bd      botDeleter
+	timeout time.Duration
+}
+
+var (
+	// ErrMessageNotFound is returned when a message is not found.
+	ErrMessageNotFound = errors.New("message not found")
+)
+
+// NewMessageUsecases creates a new instance of messageUsecases, which provides use cases for the messages stored in the outbox.
+// Telegram messages are deleted until the timeout, 0 means no timeout.
+func NewMessageUsecases(dg deliveryGeter, dd deliveryDeleter, bd botDeleter, timeout time.Duration) *messageUsecases {
+	return &messageUsecases{
+		dg:      dg,
+		dd:      dd,
+		bd:      bd,
+		timeout: timeout,
+	}
+}
+
+// GetDeliveries returns the state of the message delivery to each of its chats.
+// It returns ErrMessageNotFound if the message does not exist or belongs to another company.
+func (u *messageUsecases) GetDeliveries(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error) {
+	const op = "usecases.GetDeliveries"
+
+	deliveries, err := u.dg.GetDeliveries(ctx, messageID, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrMessageNotFound)
+		}
+		return nil, fmt.Errorf("%s: get deliveries: %w", op, err)
+	}
+
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrMessageNotFound)
+	}
+
+	return deliveries, nil
+}
+
+// DeleteMessage retracts the message: it deletes the Telegram messages from the chats the message was delivered to
+// and cancels the deliveries that are still queued. It returns the deliveries in their new state,
+// a delivery Telegram refused to delete stays delivered with the reason in LastError.
+// A digest lists several messages in the same Telegram messages, they are deleted only with the last message of the digest.
+// It returns ErrMessageNotFound if the message does not exist or belongs to another company.
+func (u *messageUsecases) DeleteMessage(ctx context.Context, token string, messageID int64) ([]entities.Delivery, error) {
+	const op = "usecases.DeleteMessage"
+
+	deliveries, err := u.GetDeliveries(ctx, token, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := u.delete(ctx, deliveries); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// DeleteMessages retracts the messages of the company that match the filter like DeleteMessage.
+// At most MaxDeletedMessages messages are deleted at once, the oldest first, so the call is repeated to delete the rest.
+// Messages that are not deleted from Telegram before the timeout stay delivered and are deleted by the next call.
+func (u *messageUsecases) DeleteMessages(ctx context.Context, token string, filter entities.MessageFilter) ([]entities.Delivery, error) {
+	const op = "usecases.DeleteMessages"
+
+	deliveries, err := u.dd.GetDeliveriesByFilter(ctx, token, filter, MaxDeletedMessages)
+	if err != nil {
+		return nil, fmt.Errorf("%s: get deliveries: %w", op, err)
+	}
+
+	if err := u.delete(ctx, deliveries); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// delete deletes the deliveries and updates their state in place.
+// Telegram messages are deleted until the timeout, so the request does not outlive the write timeout of the server.
+func (u *messageUsecases) delete(ctx context.Context, deliveries []entities.Delivery) error {
+	deleteCtx, cancel := ctx, context.CancelFunc(func() {})
+	if u.timeout > 0 {
+		deleteCtx, cancel = context.WithTimeout(ctx, u.timeout)
+	}
+	defer cancel()
+
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		switch d.Status {
+		case entities.DeliveryQueued:
+		case entities.DeliveryDelivered:
+			if d.DigestID != 0 {
+				left, err := u.dd.CountDigestDelivered(ctx, d.DigestID, d.ID)
+				if err != nil {
+					return fmt.Errorf("count delivered messages of digest %d: %w", d.DigestID, err)
+				}
+				// the other messages of the digest are still shown in its Telegram messages
+				if left > 0 {
+					break
+				}
+			}
+
+			ids := d.TelegramMessageIDs
+			// deliveries sent before all IDs were stored have only the first one
+			if len(ids) == 0 && d.TelegramMessageID != 0 {
+				ids = []int{d.TelegramMessageID}
+			}
+
+			if err := deleteCtx.Err(); err != nil {
+				d.LastError = fmt.Sprintf("not deleted in time, repeat the request: %s", err)
+				continue
+			}
+
+			if err := u.bd.DeleteMessages(deleteCtx, d.ChatID, ids); err != nil {
+				d.LastError = err.Error()
+				continue
+			}
+		default:
+			continue
+		}
+
+		err := u.dd.MarkDeleted(ctx, d.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			// the delivery is claimed by a worker
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("mark delivery %d as deleted: %w", d.ID, err)
+		}
+
+		d.Status = entities.DeliveryDeleted
+		d.LastError = ""
+	}
+
+	return nil
+}
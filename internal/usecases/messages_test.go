@@ -0,0 +1,279 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/internal/usecases/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_messageUsecases_GetDeliveries(t *testing.T) {
+	deliveries := []entities.Delivery{
+		{
+			ID:                1,
+			MessageID:         7,
+			ChatID:            123,
+			Status:            entities.DeliveryDelivered,
+			Attempts:          1,
+			TelegramMessageID: 55,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		mockDeliveries []entities.Delivery
+		mockError      error
+		want           []entities.Delivery
+		wantErr        error
+	}{
+		{
+			name:           "success",
+			mockDeliveries: deliveries,
+			want:           deliveries,
+		},
+		{
+			name:           "without deliveries",
+			mockDeliveries: []entities.Delivery{},
+			wantErr:        ErrMessageNotFound,
+		},
+		{
+			name:      "not found",
+			mockError: storage.ErrNotFound,
+			wantErr:   ErrMessageNotFound,
+		},
+		{
+			name:      "storage error",
+			mockError: errors.New("test error"),
+			wantErr:   errors.New("usecases.GetDeliveries: get deliveries: test error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dg := mocks.NewMockdeliveryGeter(ctrl)
+			dg.EXPECT().GetDeliveries(gomock.Any(), int64(7), "token").Return(tt.mockDeliveries, tt.mockError).Times(1)
+
+			u := NewMessageUsecases(dg, nil, nil, 0)
+
+			got, err := u.GetDeliveries(context.Background(), "token", 7)
+			if tt.wantErr != nil {
+				if errors.Is(tt.wantErr, ErrMessageNotFound) {
+					assert.ErrorIs(t, err, ErrMessageNotFound)
+				} else {
+					assert.EqualError(t, err, tt.wantErr.Error())
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_messageUsecases_DeleteMessage(t *testing.T) {
+	deliveries := func() []entities.Delivery {
+		return []entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55, 56}},
+			{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryQueued, LastError: "rate limited"},
+			{ID: 3, MessageID: 7, ChatID: 789, Status: entities.DeliveryFailed, LastError: "chat not found"},
+			{ID: 4, MessageID: 7, ChatID: 321, Status: entities.DeliveryDelivered, TelegramMessageID: 40},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		deliveries []entities.Delivery
+		prepare    func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter)
+		want       []entities.Delivery
+		wantErr    string
+	}{
+		{
+			name: "success",
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55, 56}).Return(nil).Times(1)
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(321), []int{40}).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(1)).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(2)).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(4)).Return(nil).Times(1)
+			},
+			want: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, TelegramMessageID: 55, TelegramMessageIDs: []int{55, 56}},
+				{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryDeleted},
+				{ID: 3, MessageID: 7, ChatID: 789, Status: entities.DeliveryFailed, LastError: "chat not found"},
+				{ID: 4, MessageID: 7, ChatID: 321, Status: entities.DeliveryDeleted, TelegramMessageID: 40},
+			},
+		},
+		{
+			name: "telegram error and claimed delivery",
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55, 56}).Return(errors.New("message can't be deleted")).Times(1)
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(321), []int{40}).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(2)).Return(storage.ErrNotFound).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(4)).Return(nil).Times(1)
+			},
+			want: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55, 56}, LastError: "message can't be deleted"},
+				{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryQueued, LastError: "rate limited"},
+				{ID: 3, MessageID: 7, ChatID: 789, Status: entities.DeliveryFailed, LastError: "chat not found"},
+				{ID: 4, MessageID: 7, ChatID: 321, Status: entities.DeliveryDeleted, TelegramMessageID: 40},
+			},
+		},
+		{
+			name: "storage error",
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55, 56}).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(1)).Return(errors.New("test error")).Times(1)
+			},
+			wantErr: "usecases.DeleteMessage: mark delivery 1 as deleted: test error",
+		},
+		{
+			name: "digest with other delivered messages",
+			deliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, DigestID: 5},
+			},
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				dd.EXPECT().CountDigestDelivered(gomock.Any(), int64(5), int64(1)).Return(1, nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(1)).Return(nil).Times(1)
+			},
+			want: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, DigestID: 5},
+			},
+		},
+		{
+			name: "last message of digest",
+			deliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, DigestID: 5},
+			},
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				dd.EXPECT().CountDigestDelivered(gomock.Any(), int64(5), int64(1)).Return(0, nil).Times(1)
+				bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55}).Return(nil).Times(1)
+				dd.EXPECT().MarkDeleted(gomock.Any(), int64(1)).Return(nil).Times(1)
+			},
+			want: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, DigestID: 5},
+			},
+		},
+		{
+			name: "digest storage error",
+			deliveries: []entities.Delivery{
+				{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, DigestID: 5},
+			},
+			prepare: func(dd *mocks.MockdeliveryDeleter, bd *mocks.MockbotDeleter) {
+				dd.EXPECT().CountDigestDelivered(gomock.Any(), int64(5), int64(1)).Return(0, errors.New("test error")).Times(1)
+			},
+			wantErr: "usecases.DeleteMessage: count delivered messages of digest 5: test error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			if tt.deliveries == nil {
+				tt.deliveries = deliveries()
+			}
+
+			dg := mocks.NewMockdeliveryGeter(ctrl)
+			dg.EXPECT().GetDeliveries(gomock.Any(), int64(7), "token").Return(tt.deliveries, nil).Times(1)
+			dd := mocks.NewMockdeliveryDeleter(ctrl)
+			bd := mocks.NewMockbotDeleter(ctrl)
+			tt.prepare(dd, bd)
+
+			u := NewMessageUsecases(dg, dd, bd, 0)
+
+			got, err := u.DeleteMessage(context.Background(), "token", 7)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_messageUsecases_DeleteMessages(t *testing.T) {
+	filter := entities.MessageFilter{CorrelationID: "run-1"}
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dd := mocks.NewMockdeliveryDeleter(ctrl)
+		dd.EXPECT().GetDeliveriesByFilter(gomock.Any(), "token", filter, MaxDeletedMessages).Return([]entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}},
+			{ID: 2, MessageID: 8, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 60, TelegramMessageIDs: []int{60}},
+		}, nil).Times(1)
+		dd.EXPECT().MarkDeleted(gomock.Any(), int64(1)).Return(nil).Times(1)
+		dd.EXPECT().MarkDeleted(gomock.Any(), int64(2)).Return(nil).Times(1)
+
+		bd := mocks.NewMockbotDeleter(ctrl)
+		bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55}).Return(nil).Times(1)
+		bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{60}).Return(nil).Times(1)
+
+		u := NewMessageUsecases(nil, dd, bd, 0)
+
+		got, err := u.DeleteMessages(context.Background(), "token", filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDeleted, TelegramMessageID: 55, TelegramMessageIDs: []int{55}},
+			{ID: 2, MessageID: 8, ChatID: 123, Status: entities.DeliveryDeleted, TelegramMessageID: 60, TelegramMessageIDs: []int{60}},
+		}, got)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dd := mocks.NewMockdeliveryDeleter(ctrl)
+		dd.EXPECT().GetDeliveriesByFilter(gomock.Any(), "token", filter, MaxDeletedMessages).Return([]entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}},
+			{ID: 2, MessageID: 8, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 60, TelegramMessageIDs: []int{60}},
+			{ID: 3, MessageID: 9, ChatID: 123, Status: entities.DeliveryQueued},
+		}, nil).Times(1)
+		dd.EXPECT().MarkDeleted(gomock.Any(), int64(3)).Return(nil).Times(1)
+
+		bd := mocks.NewMockbotDeleter(ctrl)
+		bd.EXPECT().DeleteMessages(gomock.Any(), int64(123), []int{55}).DoAndReturn(
+			func(ctx context.Context, _ int64, _ []int) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}).Times(1)
+
+		u := NewMessageUsecases(nil, dd, bd, 10*time.Millisecond)
+
+		got, err := u.DeleteMessages(context.Background(), "token", filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55, TelegramMessageIDs: []int{55}, LastError: "context deadline exceeded"},
+			{ID: 2, MessageID: 8, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 60, TelegramMessageIDs: []int{60}, LastError: "not deleted in time, repeat the request: context deadline exceeded"},
+			{ID: 3, MessageID: 9, ChatID: 123, Status: entities.DeliveryDeleted},
+		}, got)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dd := mocks.NewMockdeliveryDeleter(ctrl)
+		dd.EXPECT().GetDeliveriesByFilter(gomock.Any(), "token", filter, MaxDeletedMessages).Return(nil, errors.New("test error")).Times(1)
+
+		u := NewMessageUsecases(nil, dd, nil, 0)
+
+		_, err := u.DeleteMessages(context.Background(), "token", filter)
+
+		assert.EqualError(t, err, "usecases.DeleteMessages: get deliveries: test error")
+	})
+}
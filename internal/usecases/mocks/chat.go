@@ -35,6 +35,20 @@ func (m *MockchatsStorage) EXPECT() *MockchatsStorageMockRecorder {
 	return m.recorder
 }
 
+// ActivateChat mocks base method.
+func (m *MockchatsStorage) ActivateChat(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateChat", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ActivateChat indicates an expected call of ActivateChat.
+func (mr *MockchatsStorageMockRecorder) ActivateChat(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateChat", reflect.TypeOf((*MockchatsStorage)(nil).ActivateChat), ctx, id)
+}
+
 // AddChat mocks base method.
 func (m *MockchatsStorage) AddChat(ctx context.Context, chat entities.Chat) (entities.Chat, error) {
 	m.ctrl.T.Helper()
@@ -78,3 +92,45 @@ func (mr *MockchatsStorageMockRecorder) GetChatsByCompanyId(ctx, id interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChatsByCompanyId", reflect.TypeOf((*MockchatsStorage)(nil).GetChatsByCompanyId), ctx, id)
 }
+
+// SetAlias mocks base method.
+func (m *MockchatsStorage) SetAlias(ctx context.Context, id int64, alias string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAlias", ctx, id, alias)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAlias indicates an expected call of SetAlias.
+func (mr *MockchatsStorageMockRecorder) SetAlias(ctx, id, alias interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAlias", reflect.TypeOf((*MockchatsStorage)(nil).SetAlias), ctx, id, alias)
+}
+
+// SetDigestWindow mocks base method.
+func (m *MockchatsStorage) SetDigestWindow(ctx context.Context, id int64, seconds int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDigestWindow", ctx, id, seconds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDigestWindow indicates an expected call of SetDigestWindow.
+func (mr *MockchatsStorageMockRecorder) SetDigestWindow(ctx, id, seconds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDigestWindow", reflect.TypeOf((*MockchatsStorage)(nil).SetDigestWindow), ctx, id, seconds)
+}
+
+// SetQuietHours mocks base method.
+func (m *MockchatsStorage) SetQuietHours(ctx context.Context, id int64, q entities.QuietHours) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetQuietHours", ctx, id, q)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetQuietHours indicates an expected call of SetQuietHours.
+func (mr *MockchatsStorageMockRecorder) SetQuietHours(ctx, id, q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetQuietHours", reflect.TypeOf((*MockchatsStorage)(nil).SetQuietHours), ctx, id, q)
+}
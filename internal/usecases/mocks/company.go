@@ -64,6 +64,20 @@ func (mr *MockcompanyStorageMockRecorder) GetCompanyByOwnerTelegramId(ctx, owner
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompanyByOwnerTelegramId", reflect.TypeOf((*MockcompanyStorage)(nil).GetCompanyByOwnerTelegramId), ctx, ownerId)
 }
 
+// SetDedupWindow mocks base method.
+func (m *MockcompanyStorage) SetDedupWindow(ctx context.Context, companyId int64, seconds int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetDedupWindow", ctx, companyId, seconds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetDedupWindow indicates an expected call of SetDedupWindow.
+func (mr *MockcompanyStorageMockRecorder) SetDedupWindow(ctx, companyId, seconds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDedupWindow", reflect.TypeOf((*MockcompanyStorage)(nil).SetDedupWindow), ctx, companyId, seconds)
+}
+
 // UpdateToken mocks base method.
 func (m *MockcompanyStorage) UpdateToken(ctx context.Context, companyId int64, token string) error {
 	m.ctrl.T.Helper()
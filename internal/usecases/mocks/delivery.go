@@ -0,0 +1,342 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: delivery.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	entities "github.com/testit-tms/webhook-bot/internal/entities"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockdeliveryStorage is a mock of deliveryStorage interface.
+type MockdeliveryStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockdeliveryStorageMockRecorder
+}
+
+// MockdeliveryStorageMockRecorder is the mock recorder for MockdeliveryStorage.
+type MockdeliveryStorageMockRecorder struct {
+	mock *MockdeliveryStorage
+}
+
+// NewMockdeliveryStorage creates a new mock instance.
+func NewMockdeliveryStorage(ctrl *gomock.Controller) *MockdeliveryStorage {
+	mock := &MockdeliveryStorage{ctrl: ctrl}
+	mock.recorder = &MockdeliveryStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockdeliveryStorage) EXPECT() *MockdeliveryStorageMockRecorder {
+	return m.recorder
+}
+
+// AddDedupKey mocks base method.
+func (m *MockdeliveryStorage) AddDedupKey(ctx context.Context, companyID, chatID int64, dedupKey string, expiresAt time.Time, reported int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDedupKey", ctx, companyID, chatID, dedupKey, expiresAt, reported)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDedupKey indicates an expected call of AddDedupKey.
+func (mr *MockdeliveryStorageMockRecorder) AddDedupKey(ctx, companyID, chatID, dedupKey, expiresAt, reported interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDedupKey", reflect.TypeOf((*MockdeliveryStorage)(nil).AddDedupKey), ctx, companyID, chatID, dedupKey, expiresAt, reported)
+}
+
+// AddSuppressed mocks base method.
+func (m *MockdeliveryStorage) AddSuppressed(ctx context.Context, companyID, chatID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSuppressed", ctx, companyID, chatID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddSuppressed indicates an expected call of AddSuppressed.
+func (mr *MockdeliveryStorageMockRecorder) AddSuppressed(ctx, companyID, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSuppressed", reflect.TypeOf((*MockdeliveryStorage)(nil).AddSuppressed), ctx, companyID, chatID)
+}
+
+// AddThreadMessageId mocks base method.
+func (m *MockdeliveryStorage) AddThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64, telegramMessageID int, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddThreadMessageId", ctx, companyID, threadKey, chatID, telegramMessageID, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddThreadMessageId indicates an expected call of AddThreadMessageId.
+func (mr *MockdeliveryStorageMockRecorder) AddThreadMessageId(ctx, companyID, threadKey, chatID, telegramMessageID, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddThreadMessageId", reflect.TypeOf((*MockdeliveryStorage)(nil).AddThreadMessageId), ctx, companyID, threadKey, chatID, telegramMessageID, expiresAt)
+}
+
+// ClaimDeliveries mocks base method.
+func (m *MockdeliveryStorage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", ctx, limit, lease)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockdeliveryStorageMockRecorder) ClaimDeliveries(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockdeliveryStorage)(nil).ClaimDeliveries), ctx, limit, lease)
+}
+
+// ClaimDigest mocks base method.
+func (m *MockdeliveryStorage) ClaimDigest(ctx context.Context, companyID, chatID int64, lease time.Duration) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDigest", ctx, companyID, chatID, lease)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDigest indicates an expected call of ClaimDigest.
+func (mr *MockdeliveryStorageMockRecorder) ClaimDigest(ctx, companyID, chatID, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDigest", reflect.TypeOf((*MockdeliveryStorage)(nil).ClaimDigest), ctx, companyID, chatID, lease)
+}
+
+// DeleteFinishedMessages mocks base method.
+func (m *MockdeliveryStorage) DeleteFinishedMessages(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFinishedMessages", ctx, retention, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFinishedMessages indicates an expected call of DeleteFinishedMessages.
+func (mr *MockdeliveryStorageMockRecorder) DeleteFinishedMessages(ctx, retention, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFinishedMessages", reflect.TypeOf((*MockdeliveryStorage)(nil).DeleteFinishedMessages), ctx, retention, limit)
+}
+
+// GetCorrelatedMessageId mocks base method.
+func (m *MockdeliveryStorage) GetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCorrelatedMessageId", ctx, companyID, correlationID, chatID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCorrelatedMessageId indicates an expected call of GetCorrelatedMessageId.
+func (mr *MockdeliveryStorageMockRecorder) GetCorrelatedMessageId(ctx, companyID, correlationID, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCorrelatedMessageId", reflect.TypeOf((*MockdeliveryStorage)(nil).GetCorrelatedMessageId), ctx, companyID, correlationID, chatID)
+}
+
+// GetDedupState mocks base method.
+func (m *MockdeliveryStorage) GetDedupState(ctx context.Context, companyID, chatID int64, dedupKey string) (entities.DedupState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDedupState", ctx, companyID, chatID, dedupKey)
+	ret0, _ := ret[0].(entities.DedupState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDedupState indicates an expected call of GetDedupState.
+func (mr *MockdeliveryStorageMockRecorder) GetDedupState(ctx, companyID, chatID, dedupKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDedupState", reflect.TypeOf((*MockdeliveryStorage)(nil).GetDedupState), ctx, companyID, chatID, dedupKey)
+}
+
+// GetThreadMessageId mocks base method.
+func (m *MockdeliveryStorage) GetThreadMessageId(ctx context.Context, companyID int64, threadKey string, chatID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreadMessageId", ctx, companyID, threadKey, chatID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreadMessageId indicates an expected call of GetThreadMessageId.
+func (mr *MockdeliveryStorageMockRecorder) GetThreadMessageId(ctx, companyID, threadKey, chatID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreadMessageId", reflect.TypeOf((*MockdeliveryStorage)(nil).GetThreadMessageId), ctx, companyID, threadKey, chatID)
+}
+
+// MarkDelivered mocks base method.
+func (m *MockdeliveryStorage) MarkDelivered(ctx context.Context, id int64, attempt int, digestID int64, telegramMessageIDs []int, plainText bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDelivered", ctx, id, attempt, digestID, telegramMessageIDs, plainText)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDelivered indicates an expected call of MarkDelivered.
+func (mr *MockdeliveryStorageMockRecorder) MarkDelivered(ctx, id, attempt, digestID, telegramMessageIDs, plainText interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDelivered", reflect.TypeOf((*MockdeliveryStorage)(nil).MarkDelivered), ctx, id, attempt, digestID, telegramMessageIDs, plainText)
+}
+
+// MarkFailed mocks base method.
+func (m *MockdeliveryStorage) MarkFailed(ctx context.Context, id int64, attempt int, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkFailed", ctx, id, attempt, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkFailed indicates an expected call of MarkFailed.
+func (mr *MockdeliveryStorageMockRecorder) MarkFailed(ctx, id, attempt, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockdeliveryStorage)(nil).MarkFailed), ctx, id, attempt, reason)
+}
+
+// MarkSuppressed mocks base method.
+func (m *MockdeliveryStorage) MarkSuppressed(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSuppressed", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkSuppressed indicates an expected call of MarkSuppressed.
+func (mr *MockdeliveryStorageMockRecorder) MarkSuppressed(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSuppressed", reflect.TypeOf((*MockdeliveryStorage)(nil).MarkSuppressed), ctx, id)
+}
+
+// Postpone mocks base method.
+func (m *MockdeliveryStorage) Postpone(ctx context.Context, id int64, attempt int, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Postpone", ctx, id, attempt, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Postpone indicates an expected call of Postpone.
+func (mr *MockdeliveryStorageMockRecorder) Postpone(ctx, id, attempt, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Postpone", reflect.TypeOf((*MockdeliveryStorage)(nil).Postpone), ctx, id, attempt, at)
+}
+
+// ScheduleRetry mocks base method.
+func (m *MockdeliveryStorage) ScheduleRetry(ctx context.Context, id int64, attempt int, at time.Time, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleRetry", ctx, id, attempt, at, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleRetry indicates an expected call of ScheduleRetry.
+func (mr *MockdeliveryStorageMockRecorder) ScheduleRetry(ctx, id, attempt, at, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleRetry", reflect.TypeOf((*MockdeliveryStorage)(nil).ScheduleRetry), ctx, id, attempt, at, reason)
+}
+
+// SetCorrelatedMessageId mocks base method.
+func (m *MockdeliveryStorage) SetCorrelatedMessageId(ctx context.Context, companyID int64, correlationID string, chatID int64, telegramMessageID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCorrelatedMessageId", ctx, companyID, correlationID, chatID, telegramMessageID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCorrelatedMessageId indicates an expected call of SetCorrelatedMessageId.
+func (mr *MockdeliveryStorageMockRecorder) SetCorrelatedMessageId(ctx, companyID, correlationID, chatID, telegramMessageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCorrelatedMessageId", reflect.TypeOf((*MockdeliveryStorage)(nil).SetCorrelatedMessageId), ctx, companyID, correlationID, chatID, telegramMessageID)
+}
+
+// MockchatStatusStorage is a mock of chatStatusStorage interface.
+type MockchatStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockchatStatusStorageMockRecorder
+}
+
+// MockchatStatusStorageMockRecorder is the mock recorder for MockchatStatusStorage.
+type MockchatStatusStorageMockRecorder struct {
+	mock *MockchatStatusStorage
+}
+
+// NewMockchatStatusStorage creates a new mock instance.
+func NewMockchatStatusStorage(ctrl *gomock.Controller) *MockchatStatusStorage {
+	mock := &MockchatStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockchatStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockchatStatusStorage) EXPECT() *MockchatStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// DeactivateChat mocks base method.
+func (m *MockchatStatusStorage) DeactivateChat(ctx context.Context, telegramID int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateChat", ctx, telegramID)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeactivateChat indicates an expected call of DeactivateChat.
+func (mr *MockchatStatusStorageMockRecorder) DeactivateChat(ctx, telegramID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateChat", reflect.TypeOf((*MockchatStatusStorage)(nil).DeactivateChat), ctx, telegramID)
+}
+
+// MigrateChat mocks base method.
+func (m *MockchatStatusStorage) MigrateChat(ctx context.Context, from, to int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateChat", ctx, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateChat indicates an expected call of MigrateChat.
+func (mr *MockchatStatusStorageMockRecorder) MigrateChat(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateChat", reflect.TypeOf((*MockchatStatusStorage)(nil).MigrateChat), ctx, from, to)
+}
+
+// MockbotSender is a mock of botSender interface.
+type MockbotSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockbotSenderMockRecorder
+}
+
+// MockbotSenderMockRecorder is the mock recorder for MockbotSender.
+type MockbotSenderMockRecorder struct {
+	mock *MockbotSender
+}
+
+// NewMockbotSender creates a new mock instance.
+func NewMockbotSender(ctrl *gomock.Controller) *MockbotSender {
+	mock := &MockbotSender{ctrl: ctrl}
+	mock.recorder = &MockbotSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockbotSender) EXPECT() *MockbotSenderMockRecorder {
+	return m.recorder
+}
+
+// SendMessage mocks base method.
+func (m *MockbotSender) SendMessage(ctx context.Context, msg entities.Message) []entities.SendResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessage", ctx, msg)
+	ret0, _ := ret[0].([]entities.SendResult)
+	return ret0
+}
+
+// SendMessage indicates an expected call of SendMessage.
+func (mr *MockbotSenderMockRecorder) SendMessage(ctx, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockbotSender)(nil).SendMessage), ctx, msg)
+}
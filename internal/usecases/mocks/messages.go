@@ -0,0 +1,155 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: messages.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/testit-tms/webhook-bot/internal/entities"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockdeliveryGeter is a mock of deliveryGeter interface.
+type MockdeliveryGeter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdeliveryGeterMockRecorder
+}
+
+// MockdeliveryGeterMockRecorder is the mock recorder for MockdeliveryGeter.
+type MockdeliveryGeterMockRecorder struct {
+	mock *MockdeliveryGeter
+}
+
+// NewMockdeliveryGeter creates a new mock instance.
+func NewMockdeliveryGeter(ctrl *gomock.Controller) *MockdeliveryGeter {
+	mock := &MockdeliveryGeter{ctrl: ctrl}
+	mock.recorder = &MockdeliveryGeterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockdeliveryGeter) EXPECT() *MockdeliveryGeterMockRecorder {
+	return m.recorder
+}
+
+// GetDeliveries mocks base method.
+func (m *MockdeliveryGeter) GetDeliveries(ctx context.Context, messageID int64, token string) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, messageID, token)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockdeliveryGeterMockRecorder) GetDeliveries(ctx, messageID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockdeliveryGeter)(nil).GetDeliveries), ctx, messageID, token)
+}
+
+// MockdeliveryDeleter is a mock of deliveryDeleter interface.
+type MockdeliveryDeleter struct {
+	ctrl     *gomock.Controller
+	recorder *MockdeliveryDeleterMockRecorder
+}
+
+// MockdeliveryDeleterMockRecorder is the mock recorder for MockdeliveryDeleter.
+type MockdeliveryDeleterMockRecorder struct {
+	mock *MockdeliveryDeleter
+}
+
+// NewMockdeliveryDeleter creates a new mock instance.
+func NewMockdeliveryDeleter(ctrl *gomock.Controller) *MockdeliveryDeleter {
+	mock := &MockdeliveryDeleter{ctrl: ctrl}
+	mock.recorder = &MockdeliveryDeleterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockdeliveryDeleter) EXPECT() *MockdeliveryDeleterMockRecorder {
+	return m.recorder
+}
+
+// CountDigestDelivered mocks base method.
+func (m *MockdeliveryDeleter) CountDigestDelivered(ctx context.Context, digestID, exceptID int64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountDigestDelivered", ctx, digestID, exceptID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountDigestDelivered indicates an expected call of CountDigestDelivered.
+func (mr *MockdeliveryDeleterMockRecorder) CountDigestDelivered(ctx, digestID, exceptID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountDigestDelivered", reflect.TypeOf((*MockdeliveryDeleter)(nil).CountDigestDelivered), ctx, digestID, exceptID)
+}
+
+// GetDeliveriesByFilter mocks base method.
+func (m *MockdeliveryDeleter) GetDeliveriesByFilter(ctx context.Context, token string, filter entities.MessageFilter, limit int) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveriesByFilter", ctx, token, filter, limit)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveriesByFilter indicates an expected call of GetDeliveriesByFilter.
+func (mr *MockdeliveryDeleterMockRecorder) GetDeliveriesByFilter(ctx, token, filter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveriesByFilter", reflect.TypeOf((*MockdeliveryDeleter)(nil).GetDeliveriesByFilter), ctx, token, filter, limit)
+}
+
+// MarkDeleted mocks base method.
+func (m *MockdeliveryDeleter) MarkDeleted(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDeleted", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDeleted indicates an expected call of MarkDeleted.
+func (mr *MockdeliveryDeleterMockRecorder) MarkDeleted(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDeleted", reflect.TypeOf((*MockdeliveryDeleter)(nil).MarkDeleted), ctx, id)
+}
+
+// MockbotDeleter is a mock of botDeleter interface.
+type MockbotDeleter struct {
+	ctrl     *gomock.Controller
+	recorder *MockbotDeleterMockRecorder
+}
+
+// MockbotDeleterMockRecorder is the mock recorder for MockbotDeleter.
+type MockbotDeleterMockRecorder struct {
+	mock *MockbotDeleter
+}
+
+// NewMockbotDeleter creates a new mock instance.
+func NewMockbotDeleter(ctrl *gomock.Controller) *MockbotDeleter {
+	mock := &MockbotDeleter{ctrl: ctrl}
+	mock.recorder = &MockbotDeleterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockbotDeleter) EXPECT() *MockbotDeleterMockRecorder {
+	return m.recorder
+}
+
+// DeleteMessages mocks base method.
+func (m *MockbotDeleter) DeleteMessages(ctx context.Context, chatID int64, messageIDs []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessages", ctx, chatID, messageIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMessages indicates an expected call of DeleteMessages.
+func (mr *MockbotDeleterMockRecorder) DeleteMessages(ctx, chatID, messageIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessages", reflect.TypeOf((*MockbotDeleter)(nil).DeleteMessages), ctx, chatID, messageIDs)
+}
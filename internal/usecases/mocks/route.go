@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: route.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/testit-tms/webhook-bot/internal/entities"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockrouteStorage is a mock of routeStorage interface.
+type MockrouteStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockrouteStorageMockRecorder
+}
+
+// MockrouteStorageMockRecorder is the mock recorder for MockrouteStorage.
+type MockrouteStorageMockRecorder struct {
+	mock *MockrouteStorage
+}
+
+// NewMockrouteStorage creates a new mock instance.
+func NewMockrouteStorage(ctrl *gomock.Controller) *MockrouteStorage {
+	mock := &MockrouteStorage{ctrl: ctrl}
+	mock.recorder = &MockrouteStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrouteStorage) EXPECT() *MockrouteStorageMockRecorder {
+	return m.recorder
+}
+
+// DeleteRoute mocks base method.
+func (m *MockrouteStorage) DeleteRoute(ctx context.Context, companyID int64, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRoute", ctx, companyID, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRoute indicates an expected call of DeleteRoute.
+func (mr *MockrouteStorageMockRecorder) DeleteRoute(ctx, companyID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoute", reflect.TypeOf((*MockrouteStorage)(nil).DeleteRoute), ctx, companyID, name)
+}
+
+// GetRoutesByCompanyId mocks base method.
+func (m *MockrouteStorage) GetRoutesByCompanyId(ctx context.Context, companyID int64) ([]entities.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoutesByCompanyId", ctx, companyID)
+	ret0, _ := ret[0].([]entities.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoutesByCompanyId indicates an expected call of GetRoutesByCompanyId.
+func (mr *MockrouteStorageMockRecorder) GetRoutesByCompanyId(ctx, companyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoutesByCompanyId", reflect.TypeOf((*MockrouteStorage)(nil).GetRoutesByCompanyId), ctx, companyID)
+}
+
+// SetRoute mocks base method.
+func (m *MockrouteStorage) SetRoute(ctx context.Context, r entities.Route) (entities.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRoute", ctx, r)
+	ret0, _ := ret[0].(entities.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRoute indicates an expected call of SetRoute.
+func (mr *MockrouteStorageMockRecorder) SetRoute(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRoute", reflect.TypeOf((*MockrouteStorage)(nil).SetRoute), ctx, r)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	entities "github.com/testit-tms/webhook-bot/internal/entities"
 	gomock "go.uber.org/mock/gomock"
@@ -50,39 +51,184 @@ func (mr *MockchatGeterMockRecorder) GetChatsByCompanyToken(ctx, t interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChatsByCompanyToken", reflect.TypeOf((*MockchatGeter)(nil).GetChatsByCompanyToken), ctx, t)
 }
 
-// MockbotSender is a mock of botSender interface.
-type MockbotSender struct {
+// MocktemplateGeter is a mock of templateGeter interface.
+type MocktemplateGeter struct {
 	ctrl     *gomock.Controller
-	recorder *MockbotSenderMockRecorder
+	recorder *MocktemplateGeterMockRecorder
 }
 
-// MockbotSenderMockRecorder is the mock recorder for MockbotSender.
-type MockbotSenderMockRecorder struct {
-	mock *MockbotSender
+// MocktemplateGeterMockRecorder is the mock recorder for MocktemplateGeter.
+type MocktemplateGeterMockRecorder struct {
+	mock *MocktemplateGeter
 }
 
-// NewMockbotSender creates a new mock instance.
-func NewMockbotSender(ctrl *gomock.Controller) *MockbotSender {
-	mock := &MockbotSender{ctrl: ctrl}
-	mock.recorder = &MockbotSenderMockRecorder{mock}
+// NewMocktemplateGeter creates a new mock instance.
+func NewMocktemplateGeter(ctrl *gomock.Controller) *MocktemplateGeter {
+	mock := &MocktemplateGeter{ctrl: ctrl}
+	mock.recorder = &MocktemplateGeterMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockbotSender) EXPECT() *MockbotSenderMockRecorder {
+func (m *MocktemplateGeter) EXPECT() *MocktemplateGeterMockRecorder {
 	return m.recorder
 }
 
-// SendMessage mocks base method.
-func (m *MockbotSender) SendMessage(ctx context.Context, msg entities.Message) error {
+// GetTemplate mocks base method.
+func (m *MocktemplateGeter) GetTemplate(ctx context.Context, companyID int64, name string) (entities.Template, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendMessage", ctx, msg)
-	ret0, _ := ret[0].(error)
+	ret := m.ctrl.Call(m, "GetTemplate", ctx, companyID, name)
+	ret0, _ := ret[0].(entities.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplate indicates an expected call of GetTemplate.
+func (mr *MocktemplateGeterMockRecorder) GetTemplate(ctx, companyID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplate", reflect.TypeOf((*MocktemplateGeter)(nil).GetTemplate), ctx, companyID, name)
+}
+
+// MockrouteGeter is a mock of routeGeter interface.
+type MockrouteGeter struct {
+	ctrl     *gomock.Controller
+	recorder *MockrouteGeterMockRecorder
+}
+
+// MockrouteGeterMockRecorder is the mock recorder for MockrouteGeter.
+type MockrouteGeterMockRecorder struct {
+	mock *MockrouteGeter
+}
+
+// NewMockrouteGeter creates a new mock instance.
+func NewMockrouteGeter(ctrl *gomock.Controller) *MockrouteGeter {
+	mock := &MockrouteGeter{ctrl: ctrl}
+	mock.recorder = &MockrouteGeterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrouteGeter) EXPECT() *MockrouteGeterMockRecorder {
+	return m.recorder
+}
+
+// GetRoutesByCompanyId mocks base method.
+func (m *MockrouteGeter) GetRoutesByCompanyId(ctx context.Context, companyID int64) ([]entities.Route, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoutesByCompanyId", ctx, companyID)
+	ret0, _ := ret[0].([]entities.Route)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoutesByCompanyId indicates an expected call of GetRoutesByCompanyId.
+func (mr *MockrouteGeterMockRecorder) GetRoutesByCompanyId(ctx, companyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoutesByCompanyId", reflect.TypeOf((*MockrouteGeter)(nil).GetRoutesByCompanyId), ctx, companyID)
+}
+
+// MockmessageQueue is a mock of messageQueue interface.
+type MockmessageQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockmessageQueueMockRecorder
+}
+
+// MockmessageQueueMockRecorder is the mock recorder for MockmessageQueue.
+type MockmessageQueueMockRecorder struct {
+	mock *MockmessageQueue
+}
+
+// NewMockmessageQueue creates a new mock instance.
+func NewMockmessageQueue(ctrl *gomock.Controller) *MockmessageQueue {
+	mock := &MockmessageQueue{ctrl: ctrl}
+	mock.recorder = &MockmessageQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockmessageQueue) EXPECT() *MockmessageQueueMockRecorder {
+	return m.recorder
+}
+
+// AddMessage mocks base method.
+func (m *MockmessageQueue) AddMessage(ctx context.Context, msg entities.Message, claimUntil time.Time) (int64, []entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMessage", ctx, msg, claimUntil)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].([]entities.Delivery)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddMessage indicates an expected call of AddMessage.
+func (mr *MockmessageQueueMockRecorder) AddMessage(ctx, msg, claimUntil interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMessage", reflect.TypeOf((*MockmessageQueue)(nil).AddMessage), ctx, msg, claimUntil)
+}
+
+// GetDeliveries mocks base method.
+func (m *MockmessageQueue) GetDeliveries(ctx context.Context, messageID int64, token string) ([]entities.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, messageID, token)
+	ret0, _ := ret[0].([]entities.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockmessageQueueMockRecorder) GetDeliveries(ctx, messageID, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockmessageQueue)(nil).GetDeliveries), ctx, messageID, token)
+}
+
+// GetMessageIdByIdempotencyKey mocks base method.
+func (m *MockmessageQueue) GetMessageIdByIdempotencyKey(ctx context.Context, companyID int64, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMessageIdByIdempotencyKey", ctx, companyID, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMessageIdByIdempotencyKey indicates an expected call of GetMessageIdByIdempotencyKey.
+func (mr *MockmessageQueueMockRecorder) GetMessageIdByIdempotencyKey(ctx, companyID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMessageIdByIdempotencyKey", reflect.TypeOf((*MockmessageQueue)(nil).GetMessageIdByIdempotencyKey), ctx, companyID, key)
+}
+
+// Mockdeliverer is a mock of deliverer interface.
+type Mockdeliverer struct {
+	ctrl     *gomock.Controller
+	recorder *MockdelivererMockRecorder
+}
+
+// MockdelivererMockRecorder is the mock recorder for Mockdeliverer.
+type MockdelivererMockRecorder struct {
+	mock *Mockdeliverer
+}
+
+// NewMockdeliverer creates a new mock instance.
+func NewMockdeliverer(ctrl *gomock.Controller) *Mockdeliverer {
+	mock := &Mockdeliverer{ctrl: ctrl}
+	mock.recorder = &MockdelivererMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockdeliverer) EXPECT() *MockdelivererMockRecorder {
+	return m.recorder
+}
+
+// Deliver mocks base method.
+func (m *Mockdeliverer) Deliver(ctx context.Context, d entities.Delivery, deadline time.Time) entities.SendResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliver", ctx, d, deadline)
+	ret0, _ := ret[0].(entities.SendResult)
 	return ret0
 }
 
-// SendMessage indicates an expected call of SendMessage.
-func (mr *MockbotSenderMockRecorder) SendMessage(ctx, msg interface{}) *gomock.Call {
+// Deliver indicates an expected call of Deliver.
+func (mr *MockdelivererMockRecorder) Deliver(ctx, d, deadline interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockbotSender)(nil).SendMessage), ctx, msg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliver", reflect.TypeOf((*Mockdeliverer)(nil).Deliver), ctx, d, deadline)
 }
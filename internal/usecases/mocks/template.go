@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: template.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/testit-tms/webhook-bot/internal/entities"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MocktemplateStorage is a mock of templateStorage interface.
+type MocktemplateStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MocktemplateStorageMockRecorder
+}
+
+// MocktemplateStorageMockRecorder is the mock recorder for MocktemplateStorage.
+type MocktemplateStorageMockRecorder struct {
+	mock *MocktemplateStorage
+}
+
+// NewMocktemplateStorage creates a new mock instance.
+func NewMocktemplateStorage(ctrl *gomock.Controller) *MocktemplateStorage {
+	mock := &MocktemplateStorage{ctrl: ctrl}
+	mock.recorder = &MocktemplateStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocktemplateStorage) EXPECT() *MocktemplateStorageMockRecorder {
+	return m.recorder
+}
+
+// DeleteTemplate mocks base method.
+func (m *MocktemplateStorage) DeleteTemplate(ctx context.Context, companyID int64, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTemplate", ctx, companyID, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTemplate indicates an expected call of DeleteTemplate.
+func (mr *MocktemplateStorageMockRecorder) DeleteTemplate(ctx, companyID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTemplate", reflect.TypeOf((*MocktemplateStorage)(nil).DeleteTemplate), ctx, companyID, name)
+}
+
+// GetTemplatesByCompanyId mocks base method.
+func (m *MocktemplateStorage) GetTemplatesByCompanyId(ctx context.Context, companyID int64) ([]entities.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplatesByCompanyId", ctx, companyID)
+	ret0, _ := ret[0].([]entities.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplatesByCompanyId indicates an expected call of GetTemplatesByCompanyId.
+func (mr *MocktemplateStorageMockRecorder) GetTemplatesByCompanyId(ctx, companyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplatesByCompanyId", reflect.TypeOf((*MocktemplateStorage)(nil).GetTemplatesByCompanyId), ctx, companyID)
+}
+
+// SetTemplate mocks base method.
+func (m *MocktemplateStorage) SetTemplate(ctx context.Context, t entities.Template) (entities.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTemplate", ctx, t)
+	ret0, _ := ret[0].(entities.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTemplate indicates an expected call of SetTemplate.
+func (mr *MocktemplateStorageMockRecorder) SetTemplate(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTemplate", reflect.TypeOf((*MocktemplateStorage)(nil).SetTemplate), ctx, t)
+}
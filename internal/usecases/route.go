@@ -0,0 +1,146 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type routeStorage interface {
+	SetRoute(ctx context.Context, r entities.Route) (entities.Route, error)
+	GetRoutesByCompanyId(ctx context.Context, companyID int64) ([]entities.Route, error)
+	DeleteRoute(ctx context.Context, companyID int64, name string) error
+}
+
+type routeUsecases struct {
+	rs   routeStorage
+	cs   chatsStorage
+	coms companyStorage
+}
+
+var (
+	// ErrRouteNotFound is returned when a routing rule is not found.
+	ErrRouteNotFound = errors.New("route not found")
+	// ErrRouteInvalid is returned when a routing rule has an invalid name, conditions or chats.
+	ErrRouteInvalid = errors.New("route is invalid")
+
+	routeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,99}$`)
+)
+
+// NewRouteUsecases returns a new instance of routeUsecases, which provides use cases for managing routing rules.
+func NewRouteUsecases(rs routeStorage, cs chatsStorage, coms companyStorage) *routeUsecases {
+	return &routeUsecases{
+		rs:   rs,
+		cs:   cs,
+		coms: coms,
+	}
+}
+
+// SetRoute adds the routing rule to the company of the owner or replaces the rule with the same name.
+// It returns ErrRouteInvalid if the name or the conditions of the rule are not valid or its chats are not chats of the company
+// and ErrCompanyNotFound if the owner has no company.
+func (u *routeUsecases) SetRoute(ctx context.Context, ownerId int64, r entities.Route) (entities.Route, error) {
+	const op = "usecases.SetRoute"
+
+	if !routeName.MatchString(r.Name) {
+		return entities.Route{}, fmt.Errorf("%s: %w: name must contain only letters, digits, '_', '.' and '-'", op, ErrRouteInvalid)
+	}
+
+	if len(r.Conditions) == 0 {
+		return entities.Route{}, fmt.Errorf("%s: %w: at least one condition is required", op, ErrRouteInvalid)
+	}
+
+	for _, c := range r.Conditions {
+		if !c.ValidField() {
+			return entities.Route{}, fmt.Errorf("%s: %w: unknown field %s", op, ErrRouteInvalid, c.Field)
+		}
+	}
+
+	if len(r.ChatIds) == 0 {
+		return entities.Route{}, fmt.Errorf("%s: %w: at least one chat is required", op, ErrRouteInvalid)
+	}
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return entities.Route{}, fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return entities.Route{}, fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	chats, err := u.cs.GetChatsByCompanyId(ctx, company.ID)
+	if err != nil {
+		return entities.Route{}, fmt.Errorf("%s: get chats by company id: %w", op, err)
+	}
+
+	for _, id := range r.ChatIds {
+		found := false
+		for _, c := range chats {
+			if c.TelegramID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return entities.Route{}, fmt.Errorf("%s: %w: chat %d is not a chat of the company", op, ErrRouteInvalid, id)
+		}
+	}
+
+	r.CompanyID = company.ID
+
+	r, err = u.rs.SetRoute(ctx, r)
+	if err != nil {
+		return entities.Route{}, fmt.Errorf("%s: set route: %w", op, err)
+	}
+
+	return r, nil
+}
+
+// GetRoutes returns the routing rules of the company of the owner.
+// It returns ErrCompanyNotFound if the owner has no company.
+func (u *routeUsecases) GetRoutes(ctx context.Context, ownerId int64) ([]entities.Route, error) {
+	const op = "usecases.GetRoutes"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return nil, fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	routes, err := u.rs.GetRoutesByCompanyId(ctx, company.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: get routes by company id: %w", op, err)
+	}
+
+	return routes, nil
+}
+
+// DeleteRoute deletes the routing rule with the given name from the company of the owner.
+// It returns ErrRouteNotFound if the company has no such rule and ErrCompanyNotFound if the owner has no company.
+func (u *routeUsecases) DeleteRoute(ctx context.Context, ownerId int64, name string) error {
+	const op = "usecases.DeleteRoute"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	if err := u.rs.DeleteRoute(ctx, company.ID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrRouteNotFound)
+		}
+		return fmt.Errorf("%s: delete route: %w", op, err)
+	}
+
+	return nil
+}
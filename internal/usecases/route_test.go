@@ -0,0 +1,202 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/internal/usecases/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_routeUsecases_SetRoute(t *testing.T) {
+	company := entities.Company{ID: 12, OwnerID: 21}
+	chats := []entities.Chat{{Id: 1, CompanyID: 12, TelegramID: 123}, {Id: 2, CompanyID: 12, TelegramID: 456}}
+	conditions := []entities.RouteCondition{{Field: "project", Value: "Backend"}, {Field: "data.testRun.name", Value: "nightly*"}}
+
+	tests := []struct {
+		name             string
+		route            entities.Route
+		mockCompanyError error
+		mockCompanyTimes int
+		mockChatsError   error
+		mockChatsTimes   int
+		mockRouteError   error
+		mockRouteTimes   int
+		wantErr          error
+	}{
+		{
+			name:             "success",
+			route:            entities.Route{Name: "backend", Conditions: conditions, ChatIds: []int64{456}},
+			mockCompanyTimes: 1,
+			mockChatsTimes:   1,
+			mockRouteTimes:   1,
+		},
+		{
+			name:    "invalid name",
+			route:   entities.Route{Name: "back end", Conditions: conditions, ChatIds: []int64{456}},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name:    "without conditions",
+			route:   entities.Route{Name: "backend", ChatIds: []int64{456}},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name:    "unknown field",
+			route:   entities.Route{Name: "backend", Conditions: []entities.RouteCondition{{Field: "owner", Value: "me"}}, ChatIds: []int64{456}},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name:    "empty data path",
+			route:   entities.Route{Name: "backend", Conditions: []entities.RouteCondition{{Field: "data.testRun.", Value: "x"}}, ChatIds: []int64{456}},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name:    "without chats",
+			route:   entities.Route{Name: "backend", Conditions: conditions},
+			wantErr: ErrRouteInvalid,
+		},
+		{
+			name:             "company not found",
+			route:            entities.Route{Name: "backend", Conditions: conditions, ChatIds: []int64{456}},
+			mockCompanyError: storage.ErrNotFound,
+			mockCompanyTimes: 1,
+			wantErr:          ErrCompanyNotFound,
+		},
+		{
+			name:             "chat of other company",
+			route:            entities.Route{Name: "backend", Conditions: conditions, ChatIds: []int64{456, 789}},
+			mockCompanyTimes: 1,
+			mockChatsTimes:   1,
+			wantErr:          ErrRouteInvalid,
+		},
+		{
+			name:             "get chats error",
+			route:            entities.Route{Name: "backend", Conditions: conditions, ChatIds: []int64{456}},
+			mockCompanyTimes: 1,
+			mockChatsError:   errors.New("error"),
+			mockChatsTimes:   1,
+			wantErr:          errors.New("usecases.SetRoute: get chats by company id: error"),
+		},
+		{
+			name:             "storage error",
+			route:            entities.Route{Name: "backend", Conditions: conditions, ChatIds: []int64{456}},
+			mockCompanyTimes: 1,
+			mockChatsTimes:   1,
+			mockRouteError:   errors.New("error"),
+			mockRouteTimes:   1,
+			wantErr:          errors.New("usecases.SetRoute: set route: error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCompany := mocks.NewMockcompanyStorage(ctrl)
+			mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(company, tt.mockCompanyError).Times(tt.mockCompanyTimes)
+
+			mockChats := mocks.NewMockchatsStorage(ctrl)
+			mockChats.EXPECT().GetChatsByCompanyId(gomock.Any(), int64(12)).Return(chats, tt.mockChatsError).Times(tt.mockChatsTimes)
+
+			stored := tt.route
+			stored.CompanyID = 12
+			mockRoute := mocks.NewMockrouteStorage(ctrl)
+			mockRoute.EXPECT().SetRoute(gomock.Any(), stored).Return(stored, tt.mockRouteError).Times(tt.mockRouteTimes)
+
+			u := NewRouteUsecases(mockRoute, mockChats, mockCompany)
+
+			got, err := u.SetRoute(context.Background(), 21, tt.route)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, stored, got)
+				return
+			}
+
+			if errors.Is(tt.wantErr, ErrRouteInvalid) || errors.Is(tt.wantErr, ErrCompanyNotFound) {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			}
+		})
+	}
+}
+
+func Test_routeUsecases_GetRoutes(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		routes := []entities.Route{{ID: 1, CompanyID: 12, Name: "backend"}}
+
+		mockCompany := mocks.NewMockcompanyStorage(ctrl)
+		mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{ID: 12}, nil).Times(1)
+
+		mockRoute := mocks.NewMockrouteStorage(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(routes, nil).Times(1)
+
+		u := NewRouteUsecases(mockRoute, mocks.NewMockchatsStorage(ctrl), mockCompany)
+
+		got, err := u.GetRoutes(context.Background(), 21)
+
+		assert.NoError(t, err)
+		assert.Equal(t, routes, got)
+	})
+
+	t.Run("company not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockCompany := mocks.NewMockcompanyStorage(ctrl)
+		mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{}, storage.ErrNotFound).Times(1)
+
+		u := NewRouteUsecases(mocks.NewMockrouteStorage(ctrl), mocks.NewMockchatsStorage(ctrl), mockCompany)
+
+		_, err := u.GetRoutes(context.Background(), 21)
+
+		assert.ErrorIs(t, err, ErrCompanyNotFound)
+	})
+}
+
+func Test_routeUsecases_DeleteRoute(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockRouteError error
+		wantErr        error
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:           "route not found",
+			mockRouteError: storage.ErrNotFound,
+			wantErr:        ErrRouteNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCompany := mocks.NewMockcompanyStorage(ctrl)
+			mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{ID: 12}, nil).Times(1)
+
+			mockRoute := mocks.NewMockrouteStorage(ctrl)
+			mockRoute.EXPECT().DeleteRoute(gomock.Any(), int64(12), "backend").Return(tt.mockRouteError).Times(1)
+
+			u := NewRouteUsecases(mockRoute, mocks.NewMockchatsStorage(ctrl), mockCompany)
+
+			err := u.DeleteRoute(context.Background(), 21, "backend")
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
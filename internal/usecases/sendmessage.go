@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/tmpl"
 	"github.com/testit-tms/webhook-bot/internal/storage"
 	"golang.org/x/exp/slog"
 )
@@ -16,66 +20,230 @@ type chatGeter interface {
 }
 
 //go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
-type botSender interface {
-	SendMessage(ctx context.Context, msg entities.Message) error
+type templateGeter interface {
+	GetTemplate(ctx context.Context, companyID int64, name string) (entities.Template, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type routeGeter interface {
+	GetRoutesByCompanyId(ctx context.Context, companyID int64) ([]entities.Route, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type messageQueue interface {
+	AddMessage(ctx context.Context, msg entities.Message, claimUntil time.Time) (int64, []entities.Delivery, error)
+	GetMessageIdByIdempotencyKey(ctx context.Context, companyID int64, key string) (int64, error)
+	GetDeliveries(ctx context.Context, messageID int64, token string) ([]entities.Delivery, error)
+}
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type deliverer interface {
+	Deliver(ctx context.Context, d entities.Delivery, deadline time.Time) entities.SendResult
+}
+
+// SendOptions represents the settings of sending webhook messages.
+type SendOptions struct {
+	// Timeout limits how long SendMessage tries to deliver the message before leaving it to the delivery workers.
+	Timeout time.Duration
+	// IdempotencyTTL is how long an idempotency key is kept, repeated requests with the key are not sent again during it.
+	IdempotencyTTL time.Duration
+	// Workers limits the number of chats SendMessage delivers the message to at the same time, the chats are sent one by one if it is not positive.
+	Workers int
 }
 
 type sendMessageUsacases struct {
 	logger *slog.Logger
 	cg     chatGeter
-	bs     botSender
+	tg     templateGeter
+	rg     routeGeter
+	mq     messageQueue
+	dl     deliverer
+	opts   SendOptions
+	now    func() time.Time
 }
 
 var (
 	// ErrChatsNotFound is returned when chats are not found.
 	ErrChatsNotFound = errors.New("chats not found")
+	// ErrChatAliasNotFound is returned when the company has no chat with an alias of a message.
+	ErrChatAliasNotFound = errors.New("chat alias not found")
 	// ErrChatsNotAllow is returned when chats are not allowed.
 	ErrChatsNotAllow = errors.New("chats not allowed")
 	// ErrCanNotSend is returned when a message cannot be sent.
 	ErrCanNotSend    = errors.New("can not send message")
+	// ErrCanNotRender is returned when the template of a message cannot be rendered with its data.
+	ErrCanNotRender = errors.New("can not render template")
 )
 
 // NewSendMessageUsecases creates a new instance of sendMessageUsacases with the provided dependencies.
-func NewSendMessageUsecases(logger *slog.Logger, cg chatGeter, bs botSender) *sendMessageUsacases {
+func NewSendMessageUsecases(logger *slog.Logger, cg chatGeter, tg templateGeter, rg routeGeter, mq messageQueue, dl deliverer, opts SendOptions) *sendMessageUsacases {
 	return &sendMessageUsacases{
 		logger: logger,
 		cg:     cg,
-		bs:     bs,
+		tg:     tg,
+		rg:     rg,
+		mq:     mq,
+		dl:     dl,
+		opts:   opts,
+		now:    time.Now,
 	}
 }
 
-// SendMessage sends a message to the specified chats. If no chat IDs are provided, the message is sent to all chats associated with the company token.
+// SendMessage sends a message to the specified chats. If no chat IDs are provided, the message is sent to the chats picked by the routing rules
+// of the company, or to all chats associated with the company token if no rule matches the message.
 // If chat IDs are provided, the message is only sent to the chats that are associated with the company token and have a matching chat ID.
-// Returns an error if the chats are not found, not allowed, or if the message cannot be sent.
-func (u *sendMessageUsacases) SendMessage(ctx context.Context, msg entities.Message) error {
+// Chats can also be given by their aliases, an unknown alias fails the message with ErrChatAliasNotFound.
+// The message is stored in the outbox first and then delivered to every chat, failures in one chat do not stop the others.
+// Deliveries that failed temporarily are retried later by the delivery workers.
+// If the message has an idempotency key that was already used, the message is not sent again and the report of the earlier message is returned.
+// If the message refers to a template of the company, its text is rendered from the template and the data of the message.
+// A message with SendAt in the future is only stored, it is delivered by the delivery workers at that time.
+// Chats in digest mode get the message later in a digest, their results are queued.
+// The message is delivered to up to Workers chats at the same time. If the context is done before the message is delivered to a chat,
+// the delivery is left to the delivery workers and its result is queued.
+// Returns a report with the result for every chat, or an error if the chats are not found, not allowed, or if the message cannot be stored.
+func (u *sendMessageUsacases) SendMessage(ctx context.Context, msg entities.Message) (entities.SendReport, error) {
 	const op = "usecases.SendMessage"
 	logger := u.logger.With(slog.String("operation", op))
 
+	msg, err := u.resolveChats(ctx, logger, msg)
+	if err != nil {
+		return entities.SendReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, err = u.render(ctx, logger, msg)
+	if err != nil {
+		return entities.SendReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u.send(ctx, msg)
+}
+
+// QueueMessage stores a message in the outbox for the same chats as SendMessage and returns its ID without waiting for the delivery.
+// The message is delivered by the delivery workers, its progress can be checked by the returned ID.
+// If the message has an idempotency key that was already used, the ID of the earlier message is returned.
+func (u *sendMessageUsacases) QueueMessage(ctx context.Context, msg entities.Message) (int64, error) {
+	const op = "usecases.QueueMessage"
+	logger := u.logger.With(slog.String("operation", op))
+
+	msg, err := u.resolveChats(ctx, logger, msg)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, err = u.render(ctx, logger, msg)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg = u.withKeyExpiration(msg)
+
+	id, _, err := u.mq.AddMessage(ctx, msg, time.Time{})
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		id, err = u.mq.GetMessageIdByIdempotencyKey(ctx, msg.CompanyID, msg.Idempotency.Key)
+		if err != nil {
+			logger.Error("can not get message by idempotency key", "error", err)
+			return 0, fmt.Errorf("%s: can not queue message: %w", op, ErrCanNotSend)
+		}
+
+		logger.Debug("message already queued", slog.Int64("message_id", id))
+		return id, nil
+	}
+	if err != nil {
+		logger.Error("can not store message", "error", err)
+		return 0, fmt.Errorf("%s: can not queue message: %w", op, ErrCanNotSend)
+	}
+
+	logger.Debug("message queued", slog.Int64("message_id", id))
+
+	return id, nil
+}
+
+// resolveChats sets the company and the chats of the message from the chats associated with the company token.
+// Chats registered with a forum topic get the message in that topic, chats with quiet hours hold it during them
+// and chats in digest mode collect it into a digest if it can be listed there.
+func (u *sendMessageUsacases) resolveChats(ctx context.Context, logger *slog.Logger, msg entities.Message) (entities.Message, error) {
 	chats, err := u.cg.GetChatsByCompanyToken(ctx, msg.Token)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			logger.Debug("chats not found")
-			return fmt.Errorf("%s: chats not found: %w", op, ErrChatsNotFound)
+			return msg, fmt.Errorf("chats not found: %w", ErrChatsNotFound)
 		}
 		logger.Error("get chats by company token", "error", err)
-		return fmt.Errorf("%s: get chats by company token: %w", op, ErrChatsNotFound)
+		return msg, fmt.Errorf("get chats by company token: %w", ErrChatsNotFound)
+	}
+
+	if len(chats) == 0 {
+		logger.Debug("chats not found")
+		return msg, fmt.Errorf("chats not found: %w", ErrChatsNotFound)
+	}
+
+	msg.CompanyID = chats[0].CompanyID
+
+	for _, c := range chats {
+		if c.DigestWindow > 0 && msg.Digestible() {
+			if msg.Digests == nil {
+				msg.Digests = make(map[int64]time.Duration)
+			}
+			msg.Digests[c.TelegramID] = time.Duration(c.DigestWindow) * time.Second
+		}
+
+		if c.IsQuiet() {
+			if msg.QuietHours == nil {
+				msg.QuietHours = make(map[int64]entities.QuietHours)
+			}
+			msg.QuietHours[c.TelegramID] = c.QuietHours
+		}
+
+		if c.MessageThreadID == 0 {
+			continue
+		}
+		if msg.ThreadIDs == nil {
+			msg.ThreadIDs = make(map[int64]int)
+		}
+		msg.ThreadIDs[c.TelegramID] = c.MessageThreadID
+	}
+
+	for _, alias := range msg.ChatAliases {
+		id, ok := aliasChat(chats, alias)
+		if !ok {
+			logger.Debug("chat alias not found", slog.String("alias", alias))
+			return msg, fmt.Errorf("chat %s not found: %w", alias, ErrChatAliasNotFound)
+		}
+		msg.ChatIds = append(msg.ChatIds, id)
 	}
 
 	if len(msg.ChatIds) == 0 {
+		routed, err := u.route(ctx, msg)
+		if err != nil {
+			logger.Error("get routes by company id", "error", err)
+			return msg, fmt.Errorf("get routes by company id: %w", ErrCanNotSend)
+		}
+
 		for _, c := range chats {
-			msg.ChatIds = append(msg.ChatIds, c.TelegramID)
+			if len(routed) == 0 || routed[c.TelegramID] {
+				msg.ChatIds = append(msg.ChatIds, c.TelegramID)
+			}
 		}
-		err := u.bs.SendMessage(ctx, msg)
-		if err != nil {
-			logger.Error("can not send message", "error", err)
-			return fmt.Errorf("%s: can not send message: %w", op, ErrCanNotSend)
+
+		// the chats of the matching rules may have been removed from the company since
+		if len(msg.ChatIds) == 0 {
+			for _, c := range chats {
+				msg.ChatIds = append(msg.ChatIds, c.TelegramID)
+			}
 		}
 
-		return nil
+		return msg, nil
 	}
 
 	allowedChats := make([]int64, 0, len(msg.ChatIds))
+	// a chat may be given both by its ID and its alias
+	seen := make(map[int64]bool, len(msg.ChatIds))
 	for _, chat := range msg.ChatIds {
+		if seen[chat] {
+			continue
+		}
+		seen[chat] = true
 		for _, c := range chats {
 			if c.TelegramID == chat {
 				allowedChats = append(allowedChats, c.TelegramID)
@@ -85,15 +253,218 @@ func (u *sendMessageUsacases) SendMessage(ctx context.Context, msg entities.Mess
 
 	if len(allowedChats) == 0 {
 		logger.Debug("chats not allowed")
-		return fmt.Errorf("%s: chats not allowed: %w", op, ErrChatsNotAllow)
+		return msg, fmt.Errorf("chats not allowed: %w", ErrChatsNotAllow)
 	}
 
 	msg.ChatIds = allowedChats
-	err = u.bs.SendMessage(ctx, msg)
+
+	return msg, nil
+}
+
+// aliasChat returns the Telegram ID of the chat with the alias, aliases are case-insensitive.
+func aliasChat(chats []entities.Chat, alias string) (int64, bool) {
+	for _, c := range chats {
+		if c.Alias != "" && strings.EqualFold(c.Alias, alias) {
+			return c.TelegramID, true
+		}
+	}
+
+	return 0, false
+}
+
+// route returns the chats of the routing rules of the company the message matches.
+// Messages without metadata and data are not matched against the rules.
+func (u *sendMessageUsacases) route(ctx context.Context, msg entities.Message) (map[int64]bool, error) {
+	if msg.Metadata.IsZero() && len(msg.Data) == 0 {
+		return nil, nil
+	}
+
+	routes, err := u.rg.GetRoutesByCompanyId(ctx, msg.CompanyID)
 	if err != nil {
-		logger.Error("can not send message", "error", err)
-		return fmt.Errorf("%s: can not send message: %w", op, ErrCanNotSend)
+		return nil, err
+	}
+
+	routed := make(map[int64]bool)
+	for _, r := range routes {
+		if !r.Match(msg) {
+			continue
+		}
+		for _, id := range r.ChatIds {
+			routed[id] = true
+		}
+	}
+
+	return routed, nil
+}
+
+// render sets the text and the parse mode of the message from the company template the message refers to.
+func (u *sendMessageUsacases) render(ctx context.Context, logger *slog.Logger, msg entities.Message) (entities.Message, error) {
+	if msg.Template == "" {
+		return msg, nil
+	}
+
+	t, err := u.tg.GetTemplate(ctx, msg.CompanyID, msg.Template)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			logger.Debug("template not found", slog.String("template", msg.Template))
+			return msg, fmt.Errorf("template %s not found: %w", msg.Template, ErrTemplateNotFound)
+		}
+		logger.Error("get template", "error", err)
+		return msg, fmt.Errorf("get template: %w", ErrCanNotSend)
+	}
+
+	text, err := tmpl.Render(t.Name, t.Body, t.ParseMode, msg.Data)
+	if err != nil {
+		logger.Debug("can not render template", slog.String("template", t.Name), "error", err)
+		return msg, fmt.Errorf("%s: %w", err.Error(), ErrCanNotRender)
+	}
+
+	msg.Text = text
+	msg.ParseMode = t.ParseMode
+
+	return msg, nil
+}
+
+func (u *sendMessageUsacases) send(ctx context.Context, msg entities.Message) (entities.SendReport, error) {
+	const op = "usecases.SendMessage"
+	logger := u.logger.With(slog.String("operation", op))
+
+	msg = u.withKeyExpiration(msg)
+	deadline := u.now().Add(u.opts.Timeout)
+
+	// the deliveries stay claimed a bit longer than the deadline to record the results before workers can pick them up,
+	// scheduled messages and digests are left to the workers
+	claimUntil := deadline.Add(u.opts.Timeout)
+	scheduled := msg.SendAt.After(u.now())
+	if scheduled {
+		claimUntil = time.Time{}
+	}
+
+	id, deliveries, err := u.mq.AddMessage(ctx, msg, claimUntil)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		return u.replay(ctx, logger, msg)
+	}
+	if err != nil {
+		logger.Error("can not store message", "error", err)
+		return entities.SendReport{}, fmt.Errorf("%s: can not send message: %w", op, ErrCanNotSend)
+	}
+
+	report := entities.SendReport{
+		MessageID: id,
+		Results:   make([]entities.SendResult, 0, len(deliveries)),
+	}
+
+	report.Results = u.deliver(ctx, msg, deliveries, deadline, scheduled)
+
+	logger.Debug("message sent", slog.Int64("message_id", id))
+
+	return report, nil
+}
+
+// deliver delivers the message to its chats concurrently with up to Workers deliveries at a time
+// and returns the results in the order of the deliveries.
+// Deliveries that are scheduled, collected into digests or not started before the context is done are queued.
+func (u *sendMessageUsacases) deliver(ctx context.Context, msg entities.Message, deliveries []entities.Delivery, deadline time.Time, scheduled bool) []entities.SendResult {
+	workers := u.opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]entities.SendResult, len(deliveries))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, d := range deliveries {
+		results[i] = entities.SendResult{ChatID: d.ChatID, Status: entities.SendStatusQueued}
+
+		if _, digest := msg.Digests[d.ChatID]; scheduled || digest {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, d entities.Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = u.dl.Deliver(ctx, d, deadline)
+		}(i, d)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// withKeyExpiration sets the expiration of the idempotency key of the message.
+func (u *sendMessageUsacases) withKeyExpiration(msg entities.Message) entities.Message {
+	if msg.Idempotency.Key != "" {
+		msg.Idempotency.ExpiresAt = u.now().Add(u.opts.IdempotencyTTL)
+	}
+
+	return msg
+}
+
+// replay returns the report of the message stored earlier with the idempotency key of the message.
+// The report reflects the current state of the deliveries, the message is not sent again.
+func (u *sendMessageUsacases) replay(ctx context.Context, logger *slog.Logger, msg entities.Message) (entities.SendReport, error) {
+	const op = "usecases.SendMessage"
+
+	id, err := u.mq.GetMessageIdByIdempotencyKey(ctx, msg.CompanyID, msg.Idempotency.Key)
+	if err != nil {
+		logger.Error("can not get message by idempotency key", "error", err)
+		return entities.SendReport{}, fmt.Errorf("%s: can not send message: %w", op, ErrCanNotSend)
+	}
+
+	deliveries, err := u.mq.GetDeliveries(ctx, id, msg.Token)
+	if err != nil {
+		logger.Error("can not get deliveries", "error", err)
+		return entities.SendReport{}, fmt.Errorf("%s: can not send message: %w", op, ErrCanNotSend)
+	}
+
+	report := entities.SendReport{
+		MessageID: id,
+		Results:   make([]entities.SendResult, 0, len(deliveries)),
+		Replayed:  true,
+	}
+
+	for _, d := range deliveries {
+		report.Results = append(report.Results, deliveryResult(d))
+	}
+
+	logger.Debug("message already sent", slog.Int64("message_id", id))
+
+	return report, nil
+}
+
+// deliveryResult converts the stored state of the delivery to the result of sending.
+func deliveryResult(d entities.Delivery) entities.SendResult {
+	res := entities.SendResult{
+		ChatID: d.ChatID,
+		Error:  d.LastError,
+	}
+
+	switch d.Status {
+	case entities.DeliveryDelivered:
+		res.Status = entities.SendStatusSent
+		res.MessageID = d.TelegramMessageID
+		res.MessageIDs = d.TelegramMessageIDs
+		res.PlainText = d.PlainText
+	case entities.DeliveryFailed:
+		res.Status = entities.SendStatusFailed
+	case entities.DeliverySuppressed:
+		res.Status = entities.SendStatusSuppressed
+	default:
+		res.Status = entities.SendStatusQueued
 	}
 
-	return nil
+	return res
 }
@@ -3,7 +3,9 @@ package usecases
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/testit-tms/webhook-bot/internal/entities"
@@ -20,9 +22,9 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 		mockChatEntities []entities.Chat
 		mockChatError    error
 		mockChatTimes    int
-		mockBotEntity    entities.Message
-		mockBotError     error
-		mockBotTimes     int
+		mockQueueEntity  entities.Message
+		mockQueueError   error
+		mockQueueTimes   int
 		wantErr          bool
 		wantErrMessage   string
 	}{
@@ -43,15 +45,84 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError: nil,
-			mockBotTimes: 1,
-			wantErr:      false,
+			mockQueueError: nil,
+			mockQueueTimes: 1,
+			wantErr:        false,
+		},
+		{
+			name: "success with forum topic",
+			msg: entities.Message{
+				Text:      "text",
+				ParseMode: entities.MarkdownV2,
+				Token:     "token",
+			},
+			mockChatEntities: []entities.Chat{
+				{
+					Id:              1,
+					TelegramID:      123,
+					CompanyID:       12,
+					MessageThreadID: 7,
+				},
+				{
+					Id:         2,
+					TelegramID: 321,
+					CompanyID:  12,
+				},
+			},
+			mockChatError: nil,
+			mockChatTimes: 1,
+			mockQueueEntity: entities.Message{
+				Text:      "text",
+				ParseMode: entities.MarkdownV2,
+				ChatIds:   []int64{123, 321},
+				ThreadIDs: map[int64]int{123: 7},
+				Token:     "token",
+				CompanyID: 12,
+			},
+			mockQueueError: nil,
+			mockQueueTimes: 1,
+			wantErr:        false,
+		},
+		{
+			name: "success with quiet hours",
+			msg: entities.Message{
+				Text:      "text",
+				ParseMode: entities.MarkdownV2,
+				Token:     "token",
+			},
+			mockChatEntities: []entities.Chat{
+				{
+					Id:         1,
+					TelegramID: 123,
+					CompanyID:  12,
+					QuietHours: entities.QuietHours{Start: 1320, End: 480, TimeZone: "Europe/Berlin"},
+				},
+				{
+					Id:         2,
+					TelegramID: 321,
+					CompanyID:  12,
+				},
+			},
+			mockChatError: nil,
+			mockChatTimes: 1,
+			mockQueueEntity: entities.Message{
+				Text:       "text",
+				ParseMode:  entities.MarkdownV2,
+				ChatIds:    []int64{123, 321},
+				QuietHours: map[int64]entities.QuietHours{123: {Start: 1320, End: 480, TimeZone: "Europe/Berlin"}},
+				Token:      "token",
+				CompanyID:  12,
+			},
+			mockQueueError: nil,
+			mockQueueTimes: 1,
+			wantErr:        false,
 		},
 		{
 			name: "get chats error sql not found",
@@ -64,14 +135,15 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			mockChatEntities: []entities.Chat{},
 			mockChatError:    storage.ErrNotFound,
 			mockChatTimes:    1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError:   nil,
-			mockBotTimes:   0,
+			mockQueueError: nil,
+			mockQueueTimes: 0,
 			wantErr:        true,
 			wantErrMessage: "usecases.SendMessage: chats not found: chats not found",
 		},
@@ -86,17 +158,33 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			mockChatEntities: []entities.Chat{},
 			mockChatError:    errors.New("error"),
 			mockChatTimes:    1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError:   nil,
-			mockBotTimes:   0,
+			mockQueueError: nil,
+			mockQueueTimes: 0,
 			wantErr:        true,
 			wantErrMessage: "usecases.SendMessage: get chats by company token: chats not found",
 		},
+		{
+			name: "company without chats",
+			msg: entities.Message{
+				Text:      "text",
+				ParseMode: entities.MarkdownV2,
+				Token:     "token",
+				ChatIds:   []int64{},
+			},
+			mockChatEntities: []entities.Chat{},
+			mockChatError:    nil,
+			mockChatTimes:    1,
+			mockQueueTimes:   0,
+			wantErr:          true,
+			wantErrMessage:   "usecases.SendMessage: chats not found: chats not found",
+		},
 		{
 			name: "success without chat ids",
 			msg: entities.Message{
@@ -114,15 +202,16 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError: nil,
-			mockBotTimes: 1,
-			wantErr:      false,
+			mockQueueError: nil,
+			mockQueueTimes: 1,
+			wantErr:        false,
 		},
 		{
 			name: "without chat ids error",
@@ -141,14 +230,15 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError:   errors.New("error"),
-			mockBotTimes:   1,
+			mockQueueError: errors.New("error"),
+			mockQueueTimes: 1,
 			wantErr:        true,
 			wantErrMessage: "usecases.SendMessage: can not send message: can not send message",
 		},
@@ -169,14 +259,15 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError:   errors.New("error"),
-			mockBotTimes:   0,
+			mockQueueError: errors.New("error"),
+			mockQueueTimes: 0,
 			wantErr:        true,
 			wantErrMessage: "usecases.SendMessage: chats not allowed: chats not allowed",
 		},
@@ -197,14 +288,15 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			},
 			mockChatError: nil,
 			mockChatTimes: 1,
-			mockBotEntity: entities.Message{
+			mockQueueEntity: entities.Message{
 				Text:      "text",
 				ParseMode: entities.MarkdownV2,
 				ChatIds:   []int64{123},
 				Token:     "token",
+				CompanyID: 12,
 			},
-			mockBotError:   errors.New("error"),
-			mockBotTimes:   1,
+			mockQueueError: errors.New("error"),
+			mockQueueTimes: 1,
 			wantErr:        true,
 			wantErrMessage: "usecases.SendMessage: can not send message: can not send message",
 		},
@@ -217,14 +309,37 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 			mockChat := mocks.NewMockchatGeter(ctrl)
 			mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), tt.msg.Token).Return(tt.mockChatEntities, tt.mockChatError).Times(tt.mockChatTimes)
 
-			mockBot := mocks.NewMockbotSender(ctrl)
-			if tt.mockBotTimes != 0 {
-				mockBot.EXPECT().SendMessage(gomock.Any(), tt.mockBotEntity).Return(tt.mockBotError).Times(tt.mockBotTimes)
+			now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+			deadline := now.Add(3 * time.Second)
+
+			var deliveries []entities.Delivery
+			wantReport := entities.SendReport{MessageID: 7, Results: []entities.SendResult{}}
+			for i, chatID := range tt.mockQueueEntity.ChatIds {
+				m := tt.mockQueueEntity
+				m.ChatIds = []int64{chatID}
+				deliveries = append(deliveries, entities.Delivery{ID: int64(i + 1), MessageID: 7, ChatID: chatID, Message: m})
+				wantReport.Results = append(wantReport.Results, entities.SendResult{ChatID: chatID, Status: entities.SendStatusSent, MessageID: 55})
+			}
+
+			mockQueue := mocks.NewMockmessageQueue(ctrl)
+			mockDeliverer := mocks.NewMockdeliverer(ctrl)
+			if tt.mockQueueTimes != 0 {
+				mockQueue.EXPECT().AddMessage(gomock.Any(), tt.mockQueueEntity, deadline.Add(3*time.Second)).Return(int64(7), deliveries, tt.mockQueueError).Times(tt.mockQueueTimes)
+				if tt.mockQueueError == nil {
+					for _, d := range deliveries {
+						mockDeliverer.EXPECT().Deliver(gomock.Any(), d, deadline).Return(entities.SendResult{ChatID: d.ChatID, Status: entities.SendStatusSent, MessageID: 55}).Times(1)
+					}
+				}
 			}
 
-			u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mockBot)
+			u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mockDeliverer, SendOptions{Timeout: 3 * time.Second})
+			u.now = func() time.Time { return now }
 
-			if err := u.SendMessage(context.Background(), tt.msg); err != nil {
+			report, err := u.SendMessage(context.Background(), tt.msg)
+			if err == nil {
+				assert.False(t, tt.wantErr)
+				assert.Equal(t, wantReport, report)
+			} else {
 				if !tt.wantErr {
 					t.Errorf("sendMessageUsacases.SendMessage() error = %v, wantErr %v", err, tt.wantErr)
 				}
@@ -234,3 +349,723 @@ func Test_sendMessageUsacases_SendMessage(t *testing.T) {
 		})
 	}
 }
+
+func Test_sendMessageUsacases_SendMessage_Scheduled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	msg := entities.Message{
+		Text:   "text",
+		Token:  "token",
+		SendAt: now.Add(time.Hour),
+	}
+	queued := entities.Message{
+		Text:      "text",
+		Token:     "token",
+		CompanyID: 12,
+		ChatIds:   []int64{123},
+		SendAt:    now.Add(time.Hour),
+	}
+
+	mockChat := mocks.NewMockchatGeter(ctrl)
+	mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return([]entities.Chat{{Id: 1, TelegramID: 123, CompanyID: 12}}, nil).Times(1)
+
+	mockQueue := mocks.NewMockmessageQueue(ctrl)
+	mockQueue.EXPECT().AddMessage(gomock.Any(), queued, time.Time{}).Return(int64(7), []entities.Delivery{{ID: 1, MessageID: 7, ChatID: 123, Message: queued}}, nil).Times(1)
+
+	u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: 3 * time.Second})
+	u.now = func() time.Time { return now }
+
+	report, err := u.SendMessage(context.Background(), msg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entities.SendReport{
+		MessageID: 7,
+		Results:   []entities.SendResult{{ChatID: 123, Status: entities.SendStatusQueued}},
+	}, report)
+}
+
+func Test_sendMessageUsacases_SendMessage_Digest(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	deadline := now.Add(3 * time.Second)
+	chats := []entities.Chat{
+		{Id: 1, TelegramID: 123, CompanyID: 12, DigestWindow: 300},
+		{Id: 2, TelegramID: 321, CompanyID: 12},
+	}
+
+	t.Run("collected into digest", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		queued := entities.Message{
+			Text:      "text",
+			Token:     "token",
+			CompanyID: 12,
+			ChatIds:   []int64{123, 321},
+			Digests:   map[int64]time.Duration{123: 5 * time.Minute},
+		}
+		deliveries := []entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Message: queued},
+			{ID: 2, MessageID: 7, ChatID: 321, Message: queued},
+		}
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, deadline.Add(3*time.Second)).Return(int64(7), deliveries, nil).Times(1)
+
+		mockDeliverer := mocks.NewMockdeliverer(ctrl)
+		mockDeliverer.EXPECT().Deliver(gomock.Any(), deliveries[1], deadline).Return(entities.SendResult{ChatID: 321, Status: entities.SendStatusSent, MessageID: 55}).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mockDeliverer, SendOptions{Timeout: 3 * time.Second})
+		u.now = func() time.Time { return now }
+
+		report, err := u.SendMessage(context.Background(), entities.Message{Text: "text", Token: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, entities.SendReport{
+			MessageID: 7,
+			Results: []entities.SendResult{
+				{ChatID: 123, Status: entities.SendStatusQueued},
+				{ChatID: 321, Status: entities.SendStatusSent, MessageID: 55},
+			},
+		}, report)
+	})
+
+	t.Run("urgent message is sent on its own", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		queued := entities.Message{
+			Text:      "text",
+			Token:     "token",
+			CompanyID: 12,
+			ChatIds:   []int64{123},
+			Urgent:    true,
+		}
+		deliveries := []entities.Delivery{{ID: 1, MessageID: 7, ChatID: 123, Message: queued}}
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, deadline.Add(3*time.Second)).Return(int64(7), deliveries, nil).Times(1)
+
+		mockDeliverer := mocks.NewMockdeliverer(ctrl)
+		mockDeliverer.EXPECT().Deliver(gomock.Any(), deliveries[0], deadline).Return(entities.SendResult{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55}).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mockDeliverer, SendOptions{Timeout: 3 * time.Second})
+		u.now = func() time.Time { return now }
+
+		_, err := u.SendMessage(context.Background(), entities.Message{Text: "text", Token: "token", ChatIds: []int64{123}, Urgent: true})
+
+		assert.NoError(t, err)
+	})
+}
+
+func Test_sendMessageUsacases_SendMessage_Concurrent(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	deadline := now.Add(3 * time.Second)
+	chats := []entities.Chat{
+		{Id: 1, TelegramID: 123, CompanyID: 12},
+		{Id: 2, TelegramID: 321, CompanyID: 12},
+		{Id: 3, TelegramID: 456, CompanyID: 12},
+	}
+	queued := entities.Message{
+		Text:      "text",
+		Token:     "token",
+		CompanyID: 12,
+		ChatIds:   []int64{123, 321, 456},
+	}
+	deliveries := []entities.Delivery{
+		{ID: 1, MessageID: 7, ChatID: 123, Message: queued},
+		{ID: 2, MessageID: 7, ChatID: 321, Message: queued},
+		{ID: 3, MessageID: 7, ChatID: 456, Message: queued},
+	}
+
+	t.Run("delivered to chats at the same time", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, deadline.Add(3*time.Second)).Return(int64(7), deliveries, nil).Times(1)
+
+		// every delivery waits until all of them are started, so they are not delivered one by one
+		var started sync.WaitGroup
+		started.Add(len(deliveries))
+		mockDeliverer := mocks.NewMockdeliverer(ctrl)
+		mockDeliverer.EXPECT().Deliver(gomock.Any(), gomock.Any(), deadline).DoAndReturn(
+			func(_ context.Context, d entities.Delivery, _ time.Time) entities.SendResult {
+				started.Done()
+				started.Wait()
+				return entities.SendResult{ChatID: d.ChatID, Status: entities.SendStatusSent, MessageID: int(d.ChatID)}
+			}).Times(3)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mockDeliverer, SendOptions{Timeout: 3 * time.Second, Workers: 3})
+		u.now = func() time.Time { return now }
+
+		report, err := u.SendMessage(context.Background(), entities.Message{Text: "text", Token: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, entities.SendReport{
+			MessageID: 7,
+			Results: []entities.SendResult{
+				{ChatID: 123, Status: entities.SendStatusSent, MessageID: 123},
+				{ChatID: 321, Status: entities.SendStatusSent, MessageID: 321},
+				{ChatID: 456, Status: entities.SendStatusSent, MessageID: 456},
+			},
+		}, report)
+	})
+
+	t.Run("context is done", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, deadline.Add(3*time.Second)).Return(int64(7), deliveries, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: 3 * time.Second, Workers: 3})
+		u.now = func() time.Time { return now }
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		report, err := u.SendMessage(ctx, entities.Message{Text: "text", Token: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, entities.SendReport{
+			MessageID: 7,
+			Results: []entities.SendResult{
+				{ChatID: 123, Status: entities.SendStatusQueued},
+				{ChatID: 321, Status: entities.SendStatusQueued},
+				{ChatID: 456, Status: entities.SendStatusQueued},
+			},
+		}, report)
+	})
+}
+
+func Test_sendMessageUsacases_QueueMessage(t *testing.T) {
+	msg := entities.Message{
+		Text:      "text",
+		ParseMode: entities.HTML,
+		Token:     "token",
+	}
+	chats := []entities.Chat{
+		{
+			Id:         1,
+			TelegramID: 123,
+			CompanyID:  12,
+		},
+	}
+	queued := entities.Message{
+		Text:      "text",
+		ParseMode: entities.HTML,
+		Token:     "token",
+		CompanyID: 12,
+		ChatIds:   []int64{123},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, time.Time{}).Return(int64(7), []entities.Delivery{}, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		id, err := u.QueueMessage(context.Background(), msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+	})
+
+	t.Run("chats not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(nil, storage.ErrNotFound).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mocks.NewMockmessageQueue(ctrl), mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		id, err := u.QueueMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrChatsNotFound)
+		assert.Equal(t, "usecases.QueueMessage: chats not found: chats not found", err.Error())
+		assert.Equal(t, int64(0), id)
+	})
+
+	t.Run("queue error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, time.Time{}).Return(int64(0), nil, errors.New("error")).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.QueueMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrCanNotSend)
+	})
+}
+
+func Test_sendMessageUsacases_Idempotency(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	msg := entities.Message{
+		Text:        "text",
+		ParseMode:   entities.HTML,
+		Token:       "token",
+		Idempotency: entities.Idempotency{Key: "key"},
+	}
+	chats := []entities.Chat{
+		{
+			Id:         1,
+			TelegramID: 123,
+			CompanyID:  12,
+		},
+		{
+			Id:         2,
+			TelegramID: 456,
+			CompanyID:  12,
+		},
+	}
+	stored := entities.Message{
+		Text:        "text",
+		ParseMode:   entities.HTML,
+		Token:       "token",
+		CompanyID:   12,
+		ChatIds:     []int64{123, 456},
+		Idempotency: entities.Idempotency{Key: "key", ExpiresAt: now.Add(24 * time.Hour)},
+	}
+	opts := SendOptions{Timeout: 3 * time.Second, IdempotencyTTL: 24 * time.Hour}
+
+	t.Run("send replayed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), stored, now.Add(6*time.Second)).Return(int64(0), nil, storage.ErrAlreadyExists).Times(1)
+		mockQueue.EXPECT().GetMessageIdByIdempotencyKey(gomock.Any(), int64(12), "key").Return(int64(7), nil).Times(1)
+		mockQueue.EXPECT().GetDeliveries(gomock.Any(), int64(7), "token").Return([]entities.Delivery{
+			{ID: 1, MessageID: 7, ChatID: 123, Status: entities.DeliveryDelivered, TelegramMessageID: 55},
+			{ID: 2, MessageID: 7, ChatID: 456, Status: entities.DeliveryQueued, LastError: "Too Many Requests"},
+		}, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), opts)
+		u.now = func() time.Time { return now }
+
+		report, err := u.SendMessage(context.Background(), msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, entities.SendReport{
+			MessageID: 7,
+			Results: []entities.SendResult{
+				{ChatID: 123, Status: entities.SendStatusSent, MessageID: 55},
+				{ChatID: 456, Status: entities.SendStatusQueued, Error: "Too Many Requests"},
+			},
+			Replayed: true,
+		}, report)
+	})
+
+	t.Run("send replayed get message error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), stored, gomock.Any()).Return(int64(0), nil, storage.ErrAlreadyExists).Times(1)
+		mockQueue.EXPECT().GetMessageIdByIdempotencyKey(gomock.Any(), int64(12), "key").Return(int64(0), storage.ErrNotFound).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), opts)
+		u.now = func() time.Time { return now }
+
+		_, err := u.SendMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrCanNotSend)
+	})
+
+	t.Run("queue replayed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), stored, time.Time{}).Return(int64(0), nil, storage.ErrAlreadyExists).Times(1)
+		mockQueue.EXPECT().GetMessageIdByIdempotencyKey(gomock.Any(), int64(12), "key").Return(int64(7), nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), opts)
+		u.now = func() time.Time { return now }
+
+		id, err := u.QueueMessage(context.Background(), msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+	})
+}
+
+func Test_sendMessageUsacases_Template(t *testing.T) {
+	chats := []entities.Chat{
+		{
+			Id:         1,
+			TelegramID: 123,
+			CompanyID:  12,
+		},
+	}
+	msg := entities.Message{
+		Token:    "token",
+		Template: "run-finished",
+		Data:     map[string]interface{}{"project": "<Web>"},
+	}
+	template := entities.Template{
+		ID:        1,
+		CompanyID: 12,
+		Name:      "run-finished",
+		ParseMode: entities.HTML,
+		Body:      "<b>{{.project}}</b> finished",
+	}
+
+	t.Run("rendered", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockTemplate := mocks.NewMocktemplateGeter(ctrl)
+		mockTemplate.EXPECT().GetTemplate(gomock.Any(), int64(12), "run-finished").Return(template, nil).Times(1)
+
+		mockRoute := mocks.NewMockrouteGeter(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(nil, nil).Times(1)
+
+		queued := msg
+		queued.Text = "<b>&lt;Web&gt;</b> finished"
+		queued.ParseMode = entities.HTML
+		queued.CompanyID = 12
+		queued.ChatIds = []int64{123}
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), queued, time.Time{}).Return(int64(7), []entities.Delivery{}, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mockTemplate, mockRoute, mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		id, err := u.QueueMessage(context.Background(), msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), id)
+	})
+
+	t.Run("template not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockTemplate := mocks.NewMocktemplateGeter(ctrl)
+		mockTemplate.EXPECT().GetTemplate(gomock.Any(), int64(12), "run-finished").Return(entities.Template{}, storage.ErrNotFound).Times(1)
+
+		mockRoute := mocks.NewMockrouteGeter(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(nil, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mockTemplate, mockRoute, mocks.NewMockmessageQueue(ctrl), mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.SendMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrTemplateNotFound)
+		assert.Equal(t, "usecases.SendMessage: template run-finished not found: template not found", err.Error())
+	})
+
+	t.Run("can not render", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		broken := template
+		broken.Body = "{{index .project 5}}"
+
+		mockTemplate := mocks.NewMocktemplateGeter(ctrl)
+		mockTemplate.EXPECT().GetTemplate(gomock.Any(), int64(12), "run-finished").Return(broken, nil).Times(1)
+
+		mockRoute := mocks.NewMockrouteGeter(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(nil, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mockTemplate, mockRoute, mocks.NewMockmessageQueue(ctrl), mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.SendMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrCanNotRender)
+	})
+
+	t.Run("get template error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockTemplate := mocks.NewMocktemplateGeter(ctrl)
+		mockTemplate.EXPECT().GetTemplate(gomock.Any(), int64(12), "run-finished").Return(entities.Template{}, errors.New("error")).Times(1)
+
+		mockRoute := mocks.NewMockrouteGeter(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(nil, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mockTemplate, mockRoute, mocks.NewMockmessageQueue(ctrl), mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.SendMessage(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrCanNotSend)
+	})
+}
+
+func Test_sendMessageUsacases_Routes(t *testing.T) {
+	chats := []entities.Chat{
+		{Id: 1, TelegramID: 123, CompanyID: 12},
+		{Id: 2, TelegramID: 456, CompanyID: 12},
+		{Id: 3, TelegramID: 789, CompanyID: 12},
+	}
+	routes := []entities.Route{
+		{
+			ID:        1,
+			CompanyID: 12,
+			Name:      "backend-failures",
+			Conditions: []entities.RouteCondition{
+				{Field: "project", Value: "backend"},
+				{Field: "severity", Value: "crit*"},
+			},
+			ChatIds: []int64{456},
+		},
+		{
+			ID:         2,
+			CompanyID:  12,
+			Name:       "nightly",
+			Conditions: []entities.RouteCondition{{Field: "data.testRun.name", Value: "*nightly*"}},
+			ChatIds:    []int64{789},
+		},
+		{
+			ID:         3,
+			CompanyID:  12,
+			Name:       "smoke",
+			Conditions: []entities.RouteCondition{{Field: "tag", Value: "smoke"}, {Field: "data.testRun.attempt", Value: "2"}},
+			ChatIds:    []int64{123, 789, 1000},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		msg       entities.Message
+		routes    []entities.Route
+		wantChats []int64
+	}{
+		{
+			name: "matches metadata",
+			msg: entities.Message{
+				Text:     "text",
+				Metadata: entities.Metadata{Project: "Backend", Severity: "Critical"},
+			},
+			routes:    routes,
+			wantChats: []int64{456},
+		},
+		{
+			name: "matches data field",
+			msg: entities.Message{
+				Text: "text",
+				Data: map[string]interface{}{"testRun": map[string]interface{}{"name": "Nightly regression"}},
+			},
+			routes:    routes,
+			wantChats: []int64{789},
+		},
+		{
+			name: "union of matching routes",
+			msg: entities.Message{
+				Text:     "text",
+				Metadata: entities.Metadata{Project: "Backend", Severity: "critical", Tags: []string{"api", "smoke"}},
+				Data:     map[string]interface{}{"testRun": map[string]interface{}{"name": "Nightly", "attempt": float64(2)}},
+			},
+			routes:    routes,
+			wantChats: []int64{123, 456, 789},
+		},
+		{
+			name: "not all conditions match",
+			msg: entities.Message{
+				Text:     "text",
+				Metadata: entities.Metadata{Project: "Backend", Severity: "minor"},
+			},
+			routes:    routes,
+			wantChats: []int64{123, 456, 789},
+		},
+		{
+			name: "chats of matching route removed",
+			msg: entities.Message{
+				Text:     "text",
+				Metadata: entities.Metadata{Project: "Backend", Severity: "critical"},
+			},
+			routes:    []entities.Route{{Conditions: []entities.RouteCondition{{Field: "project", Value: "backend"}}, ChatIds: []int64{1000}}},
+			wantChats: []int64{123, 456, 789},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockChat := mocks.NewMockchatGeter(ctrl)
+			mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+			mockRoute := mocks.NewMockrouteGeter(ctrl)
+			mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(tt.routes, nil).Times(1)
+
+			var queued entities.Message
+			mockQueue := mocks.NewMockmessageQueue(ctrl)
+			mockQueue.EXPECT().AddMessage(gomock.Any(), gomock.Any(), time.Time{}).
+				DoAndReturn(func(_ context.Context, msg entities.Message, _ time.Time) (int64, []entities.Delivery, error) {
+					queued = msg
+					return 7, nil, nil
+				}).Times(1)
+
+			u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mockRoute, mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+			msg := tt.msg
+			msg.Token = "token"
+			_, err := u.QueueMessage(context.Background(), msg)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantChats, queued.ChatIds)
+		})
+	}
+
+	t.Run("explicit chats are not routed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockQueue := mocks.NewMockmessageQueue(ctrl)
+		mockQueue.EXPECT().AddMessage(gomock.Any(), gomock.Any(), time.Time{}).Return(int64(7), nil, nil).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.QueueMessage(context.Background(), entities.Message{
+			Text:     "text",
+			Token:    "token",
+			ChatIds:  []int64{123},
+			Metadata: entities.Metadata{Project: "Backend"},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("get routes error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockChat := mocks.NewMockchatGeter(ctrl)
+		mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+		mockRoute := mocks.NewMockrouteGeter(ctrl)
+		mockRoute.EXPECT().GetRoutesByCompanyId(gomock.Any(), int64(12)).Return(nil, errors.New("error")).Times(1)
+
+		u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mockRoute, mocks.NewMockmessageQueue(ctrl), mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+		_, err := u.SendMessage(context.Background(), entities.Message{
+			Text:     "text",
+			Token:    "token",
+			Metadata: entities.Metadata{Project: "Backend"},
+		})
+
+		assert.ErrorIs(t, err, ErrCanNotSend)
+	})
+}
+
+func Test_sendMessageUsacases_ChatAliases(t *testing.T) {
+	chats := []entities.Chat{
+		{Id: 1, TelegramID: 123, CompanyID: 12, Alias: "qa-team"},
+		{Id: 2, TelegramID: 456, CompanyID: 12, Alias: "release"},
+		{Id: 3, TelegramID: 789, CompanyID: 12},
+	}
+
+	tests := []struct {
+		name      string
+		chatIds   []int64
+		aliases   []string
+		wantChats []int64
+		wantErr   error
+	}{
+		{
+			name:      "aliases",
+			aliases:   []string{"Release", "qa-team"},
+			wantChats: []int64{456, 123},
+		},
+		{
+			name:      "ids and aliases",
+			chatIds:   []int64{789, 123},
+			aliases:   []string{"qa-team"},
+			wantChats: []int64{789, 123},
+		},
+		{
+			name:    "unknown alias",
+			aliases: []string{"qa-team", "dev"},
+			wantErr: ErrChatAliasNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockChat := mocks.NewMockchatGeter(ctrl)
+			mockChat.EXPECT().GetChatsByCompanyToken(gomock.Any(), "token").Return(chats, nil).Times(1)
+
+			var queued entities.Message
+			mockQueue := mocks.NewMockmessageQueue(ctrl)
+			if tt.wantErr == nil {
+				mockQueue.EXPECT().AddMessage(gomock.Any(), gomock.Any(), time.Time{}).
+					DoAndReturn(func(_ context.Context, msg entities.Message, _ time.Time) (int64, []entities.Delivery, error) {
+						queued = msg
+						return 7, nil, nil
+					}).Times(1)
+			}
+
+			u := NewSendMessageUsecases(slogdiscard.NewDiscardLogger(), mockChat, mocks.NewMocktemplateGeter(ctrl), mocks.NewMockrouteGeter(ctrl), mockQueue, mocks.NewMockdeliverer(ctrl), SendOptions{Timeout: time.Second})
+
+			_, err := u.QueueMessage(context.Background(), entities.Message{
+				Text:        "text",
+				Token:       "token",
+				ChatIds:     tt.chatIds,
+				ChatAliases: tt.aliases,
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantChats, queued.ChatIds)
+		})
+	}
+}
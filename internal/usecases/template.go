@@ -0,0 +1,117 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/lib/tmpl"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=$PWD/mocks/${GOFILE} -package=mocks
+type templateStorage interface {
+	SetTemplate(ctx context.Context, t entities.Template) (entities.Template, error)
+	GetTemplatesByCompanyId(ctx context.Context, companyID int64) ([]entities.Template, error)
+	DeleteTemplate(ctx context.Context, companyID int64, name string) error
+}
+
+type templateUsecases struct {
+	ts   templateStorage
+	coms companyStorage
+}
+
+var (
+	// ErrTemplateNotFound is returned when a template is not found.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrTemplateInvalid is returned when a template has an invalid name or body.
+	ErrTemplateInvalid = errors.New("template is invalid")
+
+	templateName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,99}$`)
+)
+
+// NewTemplateUsecases returns a new instance of templateUsecases, which provides use cases for managing message templates.
+func NewTemplateUsecases(ts templateStorage, coms companyStorage) *templateUsecases {
+	return &templateUsecases{
+		ts:   ts,
+		coms: coms,
+	}
+}
+
+// SetTemplate adds the template to the company of the owner or replaces the template with the same name.
+// It returns ErrTemplateInvalid if the name or the body of the template is not valid
+// and ErrCompanyNotFound if the owner has no company.
+func (u *templateUsecases) SetTemplate(ctx context.Context, ownerId int64, t entities.Template) (entities.Template, error) {
+	const op = "usecases.SetTemplate"
+
+	if !templateName.MatchString(t.Name) {
+		return entities.Template{}, fmt.Errorf("%s: %w: name must contain only letters, digits, '_', '.' and '-'", op, ErrTemplateInvalid)
+	}
+
+	if _, err := tmpl.Parse(t.Name, t.Body, t.ParseMode); err != nil {
+		return entities.Template{}, fmt.Errorf("%s: %w: %s", op, ErrTemplateInvalid, err.Error())
+	}
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return entities.Template{}, fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return entities.Template{}, fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	t.CompanyID = company.ID
+
+	t, err = u.ts.SetTemplate(ctx, t)
+	if err != nil {
+		return entities.Template{}, fmt.Errorf("%s: set template: %w", op, err)
+	}
+
+	return t, nil
+}
+
+// GetTemplates returns the templates of the company of the owner.
+// It returns ErrCompanyNotFound if the owner has no company.
+func (u *templateUsecases) GetTemplates(ctx context.Context, ownerId int64) ([]entities.Template, error) {
+	const op = "usecases.GetTemplates"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return nil, fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	templates, err := u.ts.GetTemplatesByCompanyId(ctx, company.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: get templates by company id: %w", op, err)
+	}
+
+	return templates, nil
+}
+
+// DeleteTemplate deletes the template with the given name from the company of the owner.
+// It returns ErrTemplateNotFound if the company has no such template and ErrCompanyNotFound if the owner has no company.
+func (u *templateUsecases) DeleteTemplate(ctx context.Context, ownerId int64, name string) error {
+	const op = "usecases.DeleteTemplate"
+
+	company, err := u.coms.GetCompanyByOwnerTelegramId(ctx, ownerId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrCompanyNotFound)
+		}
+		return fmt.Errorf("%s: get company by owner id: %w", op, err)
+	}
+
+	if err := u.ts.DeleteTemplate(ctx, company.ID, name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrTemplateNotFound)
+		}
+		return fmt.Errorf("%s: delete template: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testit-tms/webhook-bot/internal/entities"
+	"github.com/testit-tms/webhook-bot/internal/storage"
+	"github.com/testit-tms/webhook-bot/internal/usecases/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_templateUsecases_SetTemplate(t *testing.T) {
+	company := entities.Company{ID: 12, OwnerID: 21}
+
+	tests := []struct {
+		name              string
+		template          entities.Template
+		mockCompanyError  error
+		mockCompanyTimes  int
+		mockTemplateError error
+		mockTemplateTimes int
+		wantErr           error
+	}{
+		{
+			name:              "success",
+			template:          entities.Template{Name: "run-finished", ParseMode: entities.HTML, Body: "<b>{{.project}}</b>"},
+			mockCompanyTimes:  1,
+			mockTemplateTimes: 1,
+		},
+		{
+			name:     "invalid name",
+			template: entities.Template{Name: "run finished", ParseMode: entities.HTML, Body: "text"},
+			wantErr:  ErrTemplateInvalid,
+		},
+		{
+			name:     "invalid body",
+			template: entities.Template{Name: "run-finished", ParseMode: entities.HTML, Body: "{{.project"},
+			wantErr:  ErrTemplateInvalid,
+		},
+		{
+			name:             "company not found",
+			template:         entities.Template{Name: "run-finished", ParseMode: entities.HTML, Body: "text"},
+			mockCompanyError: storage.ErrNotFound,
+			mockCompanyTimes: 1,
+			wantErr:          ErrCompanyNotFound,
+		},
+		{
+			name:              "storage error",
+			template:          entities.Template{Name: "run-finished", ParseMode: entities.HTML, Body: "text"},
+			mockCompanyTimes:  1,
+			mockTemplateError: errors.New("error"),
+			mockTemplateTimes: 1,
+			wantErr:           errors.New("usecases.SetTemplate: set template: error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCompany := mocks.NewMockcompanyStorage(ctrl)
+			mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(company, tt.mockCompanyError).Times(tt.mockCompanyTimes)
+
+			stored := tt.template
+			stored.CompanyID = 12
+			mockTemplate := mocks.NewMocktemplateStorage(ctrl)
+			mockTemplate.EXPECT().SetTemplate(gomock.Any(), stored).Return(stored, tt.mockTemplateError).Times(tt.mockTemplateTimes)
+
+			u := NewTemplateUsecases(mockTemplate, mockCompany)
+
+			got, err := u.SetTemplate(context.Background(), 21, tt.template)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, stored, got)
+				return
+			}
+
+			if errors.Is(tt.wantErr, ErrTemplateInvalid) || errors.Is(tt.wantErr, ErrCompanyNotFound) {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			}
+		})
+	}
+}
+
+func Test_templateUsecases_GetTemplates(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		templates := []entities.Template{{ID: 1, CompanyID: 12, Name: "run-finished"}}
+
+		mockCompany := mocks.NewMockcompanyStorage(ctrl)
+		mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{ID: 12}, nil).Times(1)
+
+		mockTemplate := mocks.NewMocktemplateStorage(ctrl)
+		mockTemplate.EXPECT().GetTemplatesByCompanyId(gomock.Any(), int64(12)).Return(templates, nil).Times(1)
+
+		u := NewTemplateUsecases(mockTemplate, mockCompany)
+
+		got, err := u.GetTemplates(context.Background(), 21)
+
+		assert.NoError(t, err)
+		assert.Equal(t, templates, got)
+	})
+
+	t.Run("company not found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockCompany := mocks.NewMockcompanyStorage(ctrl)
+		mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{}, storage.ErrNotFound).Times(1)
+
+		u := NewTemplateUsecases(mocks.NewMocktemplateStorage(ctrl), mockCompany)
+
+		_, err := u.GetTemplates(context.Background(), 21)
+
+		assert.ErrorIs(t, err, ErrCompanyNotFound)
+	})
+}
+
+func Test_templateUsecases_DeleteTemplate(t *testing.T) {
+	tests := []struct {
+		name              string
+		mockTemplateError error
+		wantErr           error
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:              "template not found",
+			mockTemplateError: storage.ErrNotFound,
+			wantErr:           ErrTemplateNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCompany := mocks.NewMockcompanyStorage(ctrl)
+			mockCompany.EXPECT().GetCompanyByOwnerTelegramId(gomock.Any(), int64(21)).Return(entities.Company{ID: 12}, nil).Times(1)
+
+			mockTemplate := mocks.NewMocktemplateStorage(ctrl)
+			mockTemplate.EXPECT().DeleteTemplate(gomock.Any(), int64(12), "run-finished").Return(tt.mockTemplateError).Times(1)
+
+			u := NewTemplateUsecases(mockTemplate, mockCompany)
+
+			err := u.DeleteTemplate(context.Background(), 21, "run-finished")
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
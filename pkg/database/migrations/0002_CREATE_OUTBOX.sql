@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    company_id INT NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    message_id bigint NOT NULL,
+    chat_id bigint NOT NULL,
+    status varchar (20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error text NOT NULL DEFAULT '',
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_message FOREIGN KEY(message_id) REFERENCES outbox_messages(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_delivery_message ON outbox_deliveries (message_id);
+CREATE INDEX IF NOT EXISTS index_delivery_pending ON outbox_deliveries (next_attempt_at) WHERE status IN ('queued', 'sending');
+
+-- +goose Down
+DROP INDEX IF EXISTS index_delivery_pending;
+DROP INDEX IF EXISTS index_delivery_message;
+DROP TABLE IF EXISTS outbox_deliveries;
+DROP TABLE IF EXISTS outbox_messages;
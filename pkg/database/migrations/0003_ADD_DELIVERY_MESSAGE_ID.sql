@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE outbox_deliveries ADD COLUMN IF NOT EXISTS telegram_message_id bigint NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE outbox_deliveries DROP COLUMN IF EXISTS telegram_message_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    company_id INT NOT NULL,
+    idempotency_key varchar (255) NOT NULL,
+    message_id bigint NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (company_id, idempotency_key),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_message FOREIGN KEY(message_id) REFERENCES outbox_messages(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_idempotency_expires ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS index_idempotency_expires;
+DROP TABLE IF EXISTS idempotency_keys;
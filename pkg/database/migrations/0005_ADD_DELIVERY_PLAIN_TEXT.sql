@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE outbox_deliveries ADD COLUMN IF NOT EXISTS plain_text boolean NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE outbox_deliveries DROP COLUMN IF EXISTS plain_text;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS message_thread_id INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS message_thread_id;
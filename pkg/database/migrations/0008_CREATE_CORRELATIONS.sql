@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS correlations (
+    company_id INT NOT NULL,
+    correlation_id varchar (255) NOT NULL,
+    chat_id bigint NOT NULL,
+    telegram_message_id INT NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (company_id, correlation_id, chat_id),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS correlations;
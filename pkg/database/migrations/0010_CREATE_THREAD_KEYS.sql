@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS thread_keys (
+    company_id INT NOT NULL,
+    thread_key varchar (255) NOT NULL,
+    chat_id bigint NOT NULL,
+    telegram_message_id INT NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (company_id, thread_key, chat_id),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_thread_keys_expires ON thread_keys (expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS index_thread_keys_expires;
+DROP TABLE IF EXISTS thread_keys;
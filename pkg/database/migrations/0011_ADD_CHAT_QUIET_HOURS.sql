@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS quiet_start INT NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS quiet_end INT NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS time_zone varchar (64) NOT NULL DEFAULT 'UTC';
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS time_zone;
+ALTER TABLE chats DROP COLUMN IF EXISTS quiet_end;
+ALTER TABLE chats DROP COLUMN IF EXISTS quiet_start;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS digest_window INT NOT NULL DEFAULT 0;
+ALTER TABLE outbox_deliveries ADD COLUMN IF NOT EXISTS digest boolean NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE outbox_deliveries DROP COLUMN IF EXISTS digest;
+ALTER TABLE chats DROP COLUMN IF EXISTS digest_window;
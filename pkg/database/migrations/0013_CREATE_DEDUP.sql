@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS dedup_window INT NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS dedup_keys (
+    company_id INT NOT NULL,
+    chat_id bigint NOT NULL,
+    dedup_key varchar (255) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (company_id, chat_id, dedup_key),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_dedup_keys_expires ON dedup_keys (expires_at);
+CREATE TABLE IF NOT EXISTS dedup_suppressed (
+    company_id INT NOT NULL,
+    chat_id bigint NOT NULL,
+    suppressed INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (company_id, chat_id),
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS dedup_suppressed;
+DROP INDEX IF EXISTS index_dedup_keys_expires;
+DROP TABLE IF EXISTS dedup_keys;
+ALTER TABLE companies DROP COLUMN IF EXISTS dedup_window;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS routes (
+    id SERIAL PRIMARY KEY NOT NULL,
+    company_id INT NOT NULL,
+    name varchar (100) NOT NULL,
+    conditions jsonb NOT NULL,
+    chat_ids bigint[] NOT NULL,
+    CONSTRAINT fk_company FOREIGN KEY(company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    CONSTRAINT unique_route_name UNIQUE (company_id, name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS routes;
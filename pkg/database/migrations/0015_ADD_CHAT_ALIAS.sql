@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS alias varchar (64) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS unique_chat_alias ON chats (company_id, alias) WHERE alias <> '';
+
+-- +goose Down
+DROP INDEX IF EXISTS unique_chat_alias;
+ALTER TABLE chats DROP COLUMN IF EXISTS alias;
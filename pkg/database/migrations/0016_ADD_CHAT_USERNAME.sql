@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS username varchar (32) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN IF EXISTS username;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS active boolean NOT NULL DEFAULT true;
+CREATE INDEX IF NOT EXISTS index_chat_telegram_id ON chats (telegram_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS index_chat_telegram_id;
+ALTER TABLE chats DROP COLUMN IF EXISTS active;
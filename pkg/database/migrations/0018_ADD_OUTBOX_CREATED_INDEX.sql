@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS index_outbox_message_created ON outbox_messages (created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS index_outbox_message_created;
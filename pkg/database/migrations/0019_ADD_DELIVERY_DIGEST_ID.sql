@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE outbox_deliveries ADD COLUMN IF NOT EXISTS digest_id bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS index_outbox_delivery_digest_id ON outbox_deliveries (digest_id) WHERE digest_id<>0;
+
+-- +goose Down
+DROP INDEX IF EXISTS index_outbox_delivery_digest_id;
+ALTER TABLE outbox_deliveries DROP COLUMN IF EXISTS digest_id;